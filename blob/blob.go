@@ -0,0 +1,24 @@
+package blob
+
+import (
+	"errors"
+)
+
+const (
+	BACKEND_LOCAL = "local"
+)
+
+/* Returned when no blob is stored under the requested key. */
+var ErrNotFound = errors.New("Blob not found.")
+
+/*
+ * Stores blobs under slash separated keys such as "profile/12/a8Kx.jpg". An
+ * S3 compatible backend only has to map the keys to object names.
+ */
+type IStore interface {
+	Put(key string, data []byte) error
+	Get(key string) ([]byte, error)
+
+	/* Deleting a blob that doesn't exist isn't an error. */
+	Delete(key string) error
+}
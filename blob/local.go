@@ -0,0 +1,110 @@
+package blob
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	cDirMode  = 0755
+	cFileMode = 0644
+)
+
+var errBadKey = errors.New("Invalid blob key.")
+
+type sLocalStore struct {
+	dir string
+}
+
+/* Creates a store that keeps the blobs as files below the given directory. */
+func NewLocalStore(dir string) (IStore, error) {
+	err := os.MkdirAll(dir, cDirMode)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sLocalStore{dir}, nil
+}
+
+func (store *sLocalStore) Put(key string, data []byte) error {
+	path, err := store.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), cDirMode)
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first, so readers never see half a blob
+	file, err := ioutil.TempFile(filepath.Dir(path), ".upload")
+	if err != nil {
+		return err
+	}
+
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(file.Name(), cFileMode)
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+	}
+
+	return err
+}
+
+func (store *sLocalStore) Get(key string) ([]byte, error) {
+	path, err := store.path(key)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+
+	return data, err
+}
+
+func (store *sLocalStore) Delete(key string) error {
+	path, err := store.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+/*
+ * Maps a key to its file. Keys with parts starting with a dot are refused, so
+ * they can't point outside the directory or at temporary files.
+ */
+func (store *sLocalStore) path(key string) (string, error) {
+	if len(key) == 0 || strings.HasPrefix(key, "/") {
+		return "", errBadKey
+	}
+
+	for _, part := range strings.Split(key, "/") {
+		if len(part) == 0 || strings.HasPrefix(part, ".") {
+			return "", errBadKey
+		}
+	}
+
+	return filepath.Join(store.dir, filepath.FromSlash(key)), nil
+}
@@ -0,0 +1,197 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	cNumFields = 5
+
+	// give up looking for a next run after this many years
+	cMaxSearchYears = 5
+)
+
+type sField struct {
+	name string
+	min  int
+	max  int
+}
+
+var vFields = [cNumFields]sField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 6},
+}
+
+/*
+ * A parsed cron rule of the form "minute hour day-of-month month day-of-week".
+ * Every field accepts "*", single values, ranges ("1-5"), lists ("1,3,5") and
+ * steps ("*\/15" or "8-18/2"). Sunday is day 0 of the week, 7 is accepted as
+ * well.
+ */
+type Schedule struct {
+	minutes  uint64
+	hours    uint64
+	days     uint64
+	months   uint64
+	weekdays uint64
+
+	// when both days are restricted, matching either one is enough
+	anyDay     bool
+	anyWeekday bool
+}
+
+func Parse(rule string) (*Schedule, error) {
+	fields := strings.Fields(rule)
+	if len(fields) != cNumFields {
+		return nil, fmt.Errorf("Expected %d fields in cron rule, got %d.",
+			cNumFields, len(fields))
+	}
+
+	var bits [cNumFields]uint64
+	for idx, field := range fields {
+		var err error
+		bits[idx], err = parseField(field, vFields[idx])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	schedule := Schedule{}
+	schedule.minutes = bits[0]
+	schedule.hours = bits[1]
+	schedule.days = bits[2]
+	schedule.months = bits[3]
+	schedule.weekdays = bits[4]
+	schedule.anyDay = fields[2] == "*"
+	schedule.anyWeekday = fields[4] == "*"
+
+	// sunday may be written as 7
+	if schedule.weekdays&(1<<7) != 0 {
+		schedule.weekdays |= 1
+	}
+
+	return &schedule, nil
+}
+
+/*
+ * Returns the first time after the given time that matches the schedule, in
+ * the location of the given time. Returns the zero time if the rule never
+ * matches, e.g. for "0 0 31 2 *".
+ */
+func (schedule *Schedule) Next(after time.Time) time.Time {
+	location := after.Location()
+	next := after.Truncate(time.Minute).Add(time.Minute)
+	limit := next.AddDate(cMaxSearchYears, 0, 0)
+
+	for next.Before(limit) {
+		if !hasBit(schedule.months, int(next.Month())) {
+			next = time.Date(next.Year(), next.Month()+1, 1, 0, 0, 0, 0,
+				location)
+			continue
+		}
+
+		if !schedule.matchesDay(next) {
+			next = time.Date(next.Year(), next.Month(), next.Day()+1, 0, 0, 0,
+				0, location)
+			continue
+		}
+
+		if !hasBit(schedule.hours, next.Hour()) {
+			// the wall clock may repeat an hour when daylight saving ends
+			nextHour := time.Date(next.Year(), next.Month(), next.Day(),
+				next.Hour()+1, 0, 0, 0, location)
+			if !nextHour.After(next) {
+				nextHour = next.Add(time.Hour)
+			}
+
+			next = nextHour
+			continue
+		}
+
+		if !hasBit(schedule.minutes, next.Minute()) {
+			next = next.Add(time.Minute)
+			continue
+		}
+
+		return next
+	}
+
+	return time.Time{}
+}
+
+func (schedule *Schedule) matchesDay(date time.Time) bool {
+	dayMatches := hasBit(schedule.days, date.Day())
+	weekdayMatches := hasBit(schedule.weekdays, int(date.Weekday()))
+
+	if schedule.anyDay || schedule.anyWeekday {
+		return dayMatches && weekdayMatches
+	}
+
+	return dayMatches || weekdayMatches
+}
+
+/* Converts a single comma separated field to a set of bits. */
+func parseField(field string, bounds sField) (uint64, error) {
+	var bits uint64
+
+	// sunday may be written as 7
+	max := bounds.max
+	if bounds.name == vFields[4].name {
+		max = 7
+	}
+
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if slash := strings.Index(part, "/"); slash >= 0 {
+			var err error
+			rangePart = part[:slash]
+			step, err = strconv.Atoi(part[slash+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("Bad step in %s field: %s.", bounds.name,
+					part)
+			}
+		}
+
+		first, last := bounds.min, max
+		if rangePart != "*" {
+			var err error
+			if dash := strings.Index(rangePart, "-"); dash >= 0 {
+				first, err = strconv.Atoi(rangePart[:dash])
+				if err == nil {
+					last, err = strconv.Atoi(rangePart[dash+1:])
+				}
+			} else {
+				first, err = strconv.Atoi(rangePart)
+				last = first
+
+				// "5/15" means every 15 starting at 5
+				if step > 1 {
+					last = max
+				}
+			}
+
+			if err != nil || first < bounds.min || last > max ||
+				first > last {
+
+				return 0, fmt.Errorf("Bad value in %s field: %s.", bounds.name,
+					part)
+			}
+		}
+
+		for value := first; value <= last; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+
+	return bits, nil
+}
+
+func hasBit(bits uint64, value int) bool {
+	return bits&(1<<uint(value)) != 0
+}
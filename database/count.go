@@ -6,14 +6,61 @@ import (
 	_ "github.com/lib/pq"
 )
 
-func (db *Database) CountPollsForUser(userID int64) int64 {
+/* Counts the polls of the user that pass the filter. */
+func (db *Database) CountPollsForUser(userID int64, filter *PollFilter) int64 {
 
+	conditions, args := filter.conditions()
 	count, err := db.mapping.SelectInt(fmt.Sprintf(
-		"select count(*) from %s where %s=$1;", cParticipantTableName, cUserID),
-		userID)
+		"select count(*) from %s, %s where %s.%s=%s.%s and %s.%s=$1%s;",
+		cParticipantTableName, cPollTableName, cParticipantTableName, cPollID,
+		cPollTableName, cID, cParticipantTableName, cUserID, conditions),
+		append([]interface{}{userID}, args...)...)
 	if err != nil {
 		return 0 // TODO when is error nil?
 	}
 
 	return count
 }
+
+func (db *Database) CountCommentsForPoll(pollID int64) (int64, error) {
+	return db.mapping.SelectInt(fmt.Sprintf(
+		"select count(*) from %s where %s=$1;", cCommentTableName, cPollID),
+		pollID)
+}
+
+/* Counts the polls of the user with events the user hasn't seen yet. */
+func (db *Database) CountUnreadPollsForUser(userID int64) (int64, error) {
+	return db.mapping.SelectInt(fmt.Sprintf(
+		"select count(*) from %s, %s where %s.%s=%s.%s and %s.%s=$1 and "+
+			"%s.%s>%s.%s;", cParticipantTableName, cPollTableName,
+		cParticipantTableName, cPollID, cPollTableName, cID,
+		cParticipantTableName, cUserID, cPollTableName, cSequenceNumber,
+		cParticipantTableName, cLastSeen), userID)
+}
+
+/*
+ * Returns the column with the badge count of each user in a query on the
+ * users table: the polls updated since the user last reset the badge that
+ * the user hasn't seen yet, along with the polls still open for the user's
+ * vote no matter when they were updated.
+ */
+func badgeColumn() string {
+	return fmt.Sprintf("(select count(*) from %s badge_participants, %s "+
+		"where badge_participants.%s=%s.%s and badge_participants.%s=%s.%s "+
+		"and ((%s.%s>%s.%s and %s.%s>badge_participants.%s) or (not %s.%s "+
+		"and %s.%s>extract(epoch from now())*1000 and not exists (select 1 "+
+		"from %s where %s.%s=%s.%s and %s.%s=%s.%s)))) as badge",
+		cParticipantTableName, cPollTableName,
+		cPollID, cPollTableName, cID, cUserID, cUserTableName, cID,
+		cPollTableName, cLastUpdated, cUserTableName, cBadgeReset,
+		cPollTableName, cSequenceNumber, cLastSeen, cPollTableName, cClosed,
+		cPollTableName, cClosingDate,
+		cVoteTableName, cVoteTableName, cPollID, cPollTableName, cID,
+		cVoteTableName, cUserID, cUserTableName, cID)
+}
+
+/* Returns the badge count of the user, see badgeColumn. */
+func (db *Database) CountBadgeForUser(userID int64) (int64, error) {
+	return db.mapping.SelectInt(fmt.Sprintf("select %s from %s where %s=$1;",
+		badgeColumn(), cUserTableName, cID), userID)
+}
@@ -54,3 +54,57 @@ func AddParticipantTX(participant *polly.Participant,
 
     return tx.Insert(participant)
 }
+
+func AddGroupTX(group *polly.Group, tx *gorp.Transaction) error {
+    return tx.Insert(group)
+}
+
+func AddGroupMemberTX(member *polly.GroupMember, tx *gorp.Transaction) error {
+    return tx.Insert(member)
+}
+
+func (db *Database) AddInviteLink(inviteLink *polly.InviteLink) error {
+    return db.mapping.Insert(inviteLink)
+}
+
+func (db *Database) AddWebSession(session *polly.WebSession) error {
+    return db.mapping.Insert(session)
+}
+
+func AddPollTemplateTX(template *polly.PollTemplate,
+    tx *gorp.Transaction) error {
+
+    return tx.Insert(template)
+}
+
+func AddTemplateOptionTX(option *polly.TemplateOption,
+    tx *gorp.Transaction) error {
+
+    return tx.Insert(option)
+}
+
+func AddTemplateParticipantTX(participant *polly.TemplateParticipant,
+    tx *gorp.Transaction) error {
+
+    return tx.Insert(participant)
+}
+
+func (db *Database) AddJob(job *polly.Job) error {
+    return db.mapping.Insert(job)
+}
+
+func AddJobTX(job *polly.Job, tx *gorp.Transaction) error {
+    return tx.Insert(job)
+}
+
+func (db *Database) AddImage(image *polly.Image) error {
+    return db.mapping.Insert(image)
+}
+
+func AddCommentTX(comment *polly.Comment, tx *gorp.Transaction) error {
+    return tx.Insert(comment)
+}
+
+func AddReactionTX(reaction *polly.Reaction, tx *gorp.Transaction) error {
+    return tx.Insert(reaction)
+}
@@ -49,6 +49,31 @@ func NewDatabase(config *Config) (*Database, error) {
 		SetKeys(true, cPK)
 	db.mapping.AddTableWithName(polly.Participant{}, cParticipantTableName).
 		SetKeys(true, cPK)
+	db.mapping.AddTableWithName(polly.Group{}, cGroupTableName).
+		SetKeys(true, cPK)
+	db.mapping.AddTableWithName(polly.GroupMember{}, cGroupMemberTableName).
+		SetKeys(true, cPK)
+	db.mapping.AddTableWithName(polly.InviteLink{}, cInviteLinkTableName).
+		SetKeys(true, cPK)
+	db.mapping.AddTableWithName(polly.WebSession{}, cWebSessionTableName).
+		SetKeys(true, cPK)
+	db.mapping.AddTableWithName(polly.PollTemplate{}, cPollTemplateTableName).
+		SetKeys(true, cPK)
+	db.mapping.AddTableWithName(polly.TemplateOption{},
+		cTemplateOptionTableName).SetKeys(true, cPK)
+	db.mapping.AddTableWithName(polly.TemplateParticipant{},
+		cTemplateParticipantTableName).SetKeys(true, cPK)
+	db.mapping.AddTableWithName(polly.Job{}, cJobTableName).
+		SetKeys(true, cPK)
+	db.mapping.AddTableWithName(polly.Image{}, cImageTableName).
+		SetKeys(true, cPK)
+	db.mapping.AddTableWithName(polly.Comment{}, cCommentTableName).
+		SetKeys(true, cPK)
+	db.mapping.AddTableWithName(polly.Reaction{}, cReactionTableName).
+		SetKeys(true, cPK).SetUniqueTogether(cUserID, cOptionID, cCommentID,
+		cEmoji)
+	db.mapping.AddTableWithName(sMigration{}, cMigrationTableName).
+		SetKeys(false, "Version")
 
 	return &db, nil
 }
@@ -58,6 +83,11 @@ func (db *Database) CreateTablesIfNotExists() error {
 }
 
 func (db *Database) DropTablesIfExists() error {
+	err := dropMigratedObjects(db)
+	if err != nil {
+		return err
+	}
+
 	return db.mapping.DropTablesIfExists()
 }
 
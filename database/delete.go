@@ -3,6 +3,7 @@ package database
 import (
 	"fmt"
 
+	"github.com/roxot/polly"
 	"gopkg.in/gorp.v1"
 )
 
@@ -12,6 +13,18 @@ func DeleteVotesForUserTX(userID, pollID int64, tx *gorp.Transaction) error {
 	return err
 }
 
+func DeleteVotesForOptionTX(optionID int64, tx *gorp.Transaction) error {
+	_, err := tx.Exec(fmt.Sprintf("delete from %s where %s=$1;",
+		cVoteTableName, cOptionID), optionID)
+	return err
+}
+
+func DeleteOptionTX(optionID int64, tx *gorp.Transaction) error {
+	_, err := tx.Exec(fmt.Sprintf("delete from %s where %s=$1;",
+		cOptionTableName, cID), optionID)
+	return err
+}
+
 func (db *Database) DeleteVoteByIDForUser(voteID, userID int64) error {
 	_, err := db.mapping.Exec(fmt.Sprintf(
 		"delete from %s where %s=$1 and %s=$2;", cVoteTableName, cID,
@@ -25,3 +38,142 @@ func (db *Database) DeleteParticipant(userID, pollID int64) error {
 		cPollID), userID, pollID)
 	return err
 }
+
+func DeleteGroupMembersTX(groupID int64, tx *gorp.Transaction) error {
+	_, err := tx.Exec(fmt.Sprintf("delete from %s where %s=$1;",
+		cGroupMemberTableName, cGroupID), groupID)
+	return err
+}
+
+func DeleteGroupTX(groupID int64, tx *gorp.Transaction) error {
+	_, err := tx.Exec(fmt.Sprintf("delete from %s where %s=$1;",
+		cGroupTableName, cID), groupID)
+	return err
+}
+
+func DeleteParticipantTX(userID, pollID int64, tx *gorp.Transaction) error {
+	_, err := tx.Exec(fmt.Sprintf("delete from %s where %s=$1 and %s=$2;",
+		cParticipantTableName, cUserID, cPollID), userID, pollID)
+	return err
+}
+
+func DeleteWebSessionsForUserTX(userID int64, tx *gorp.Transaction) error {
+	_, err := tx.Exec(fmt.Sprintf("delete from %s where %s=$1;",
+		cWebSessionTableName, cUserID), userID)
+	return err
+}
+
+func DeleteUserTX(userID int64, tx *gorp.Transaction) error {
+	_, err := tx.Exec(fmt.Sprintf("delete from %s where %s=$1;",
+		cUserTableName, cID), userID)
+	return err
+}
+
+func (db *Database) DeleteUser(userID int64) error {
+	_, err := db.mapping.Exec(fmt.Sprintf("delete from %s where %s=$1;",
+		cUserTableName, cID), userID)
+	return err
+}
+
+func (db *Database) DeleteWebSession(sessionID int64) error {
+	_, err := db.mapping.Exec(fmt.Sprintf("delete from %s where %s=$1;",
+		cWebSessionTableName, cID), sessionID)
+	return err
+}
+
+func DeleteTemplateOptionsTX(templateID int64, tx *gorp.Transaction) error {
+	_, err := tx.Exec(fmt.Sprintf("delete from %s where %s=$1;",
+		cTemplateOptionTableName, cTemplateID), templateID)
+	return err
+}
+
+func DeleteTemplateParticipantsTX(templateID int64,
+	tx *gorp.Transaction) error {
+
+	_, err := tx.Exec(fmt.Sprintf("delete from %s where %s=$1;",
+		cTemplateParticipantTableName, cTemplateID), templateID)
+	return err
+}
+
+func DeletePollTemplateTX(templateID int64, tx *gorp.Transaction) error {
+	_, err := tx.Exec(fmt.Sprintf("delete from %s where %s=$1;",
+		cPollTemplateTableName, cID), templateID)
+	return err
+}
+
+func DeleteJobTX(jobID int64, tx *gorp.Transaction) error {
+	_, err := tx.Exec(fmt.Sprintf("delete from %s where %s=$1;",
+		cJobTableName, cID), jobID)
+	return err
+}
+
+func (db *Database) DeleteJob(jobID int64) error {
+	_, err := db.mapping.Exec(fmt.Sprintf("delete from %s where %s=$1;",
+		cJobTableName, cID), jobID)
+	return err
+}
+
+/*
+ * Deletes the image attached to the given option. Returns the blob key of the
+ * image, which is empty if the option had none.
+ */
+func DeleteImageForOptionTX(optionID int64, tx *gorp.Transaction) (string,
+	error) {
+
+	blobKey, err := tx.SelectNullStr(fmt.Sprintf(
+		"delete from %s where %s=$1 returning %s;", cImageTableName, cOptionID,
+		cBlobKey), optionID)
+	return blobKey.String, err
+}
+
+/*
+ * Deletes the given image if it hasn't been attached to an option. Returns
+ * whether the image was deleted.
+ */
+func (db *Database) DeleteUnattachedImage(imageID int64) (bool, error) {
+	result, err := db.mapping.Exec(fmt.Sprintf(
+		"delete from %s where %s=$1 and %s=0;", cImageTableName, cID,
+		cOptionID), imageID)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected == 1, err
+}
+
+func DeleteCommentTX(commentID int64, tx *gorp.Transaction) error {
+	_, err := tx.Exec(fmt.Sprintf("delete from %s where %s=$1;",
+		cCommentTableName, cID), commentID)
+	return err
+}
+
+/* Deletes the given reaction of a user. Returns whether it existed. */
+func DeleteReactionTX(reaction *polly.Reaction, tx *gorp.Transaction) (bool,
+	error) {
+
+	result, err := tx.Exec(fmt.Sprintf(
+		"delete from %s where %s=$1 and %s=$2 and %s=$3 and %s=$4;",
+		cReactionTableName, cUserID, cOptionID, cCommentID, cEmoji),
+		reaction.UserID, reaction.OptionID, reaction.CommentID, reaction.Emoji)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected > 0, err
+}
+
+func DeleteReactionsForOptionTX(optionID int64, tx *gorp.Transaction) error {
+	_, err := tx.Exec(fmt.Sprintf("delete from %s where %s=$1;",
+		cReactionTableName, cOptionID), optionID)
+	return err
+}
+
+func DeleteReactionsForCommentTX(commentID int64,
+	tx *gorp.Transaction) error {
+
+	_, err := tx.Exec(fmt.Sprintf("delete from %s where %s=$1;",
+		cReactionTableName, cCommentID), commentID)
+	return err
+}
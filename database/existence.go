@@ -3,6 +3,8 @@ package database
 import (
 	"fmt"
 
+	"github.com/roxot/polly"
+
 	_ "github.com/lib/pq"
 	"gopkg.in/gorp.v1"
 )
@@ -32,3 +34,15 @@ func ExistsParticipantTX(userID, pollID int64, tx *gorp.Transaction) (bool,
 
 	return (count == 1), nil
 }
+
+func (db *Database) ExistsReaction(reaction *polly.Reaction) (bool, error) {
+	count, err := db.mapping.SelectInt(fmt.Sprintf(
+		"select count(1) from %s where %s=$1 and %s=$2 and %s=$3 and %s=$4;",
+		cReactionTableName, cUserID, cOptionID, cCommentID, cEmoji),
+		reaction.UserID, reaction.OptionID, reaction.CommentID, reaction.Emoji)
+	if err != nil {
+		return false, err
+	}
+
+	return (count == 1), nil
+}
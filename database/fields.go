@@ -1,33 +1,97 @@
 package database
 
 const (
-	cUserTableName        = "users"
-	cPollTableName        = "polls"
-	cQuestionTableName    = "questions"
-	cOptionTableName      = "options"
-	cVoteTableName        = "votes"
-	cParticipantTableName = "participants"
-	cSequenceNumber       = "sequence_number"
-	cClosingDate          = "closing_date"
-	cPK                   = "ID"
-	cID                   = "id"
-	cToken                = "token"
-	cDisplayName          = "display_name"
-	cDeviceType           = "device_type"
-	cDeviceGUID           = "device_guid"
-	cProfilePic           = "profile_pic"
-	cCreatorID            = "creator_id"
-	cCreationDate         = "creation_date"
-	cLastUpdated          = "last_updated"
-	cTitle                = "title"
-	cPollID               = "poll_id"
-	cQuestionID           = "question_id"
-	cValue                = "value"
-	cOptionalID           = "optional_id"
-	cOptionID             = "option_id"
-	cUserID               = "user_id"
-	cLastEventType        = "last_event_type"
-	cLastEventUser        = "last_event_user"
-	cLastEventUserID      = "last_event_user_id"
-	cLastEventTitle       = "last_event_title"
+	cUserTableName                = "users"
+	cPollTableName                = "polls"
+	cQuestionTableName            = "questions"
+	cOptionTableName              = "options"
+	cVoteTableName                = "votes"
+	cParticipantTableName         = "participants"
+	cGroupTableName               = "user_groups"
+	cGroupMemberTableName         = "group_members"
+	cInviteLinkTableName          = "invite_links"
+	cWebSessionTableName          = "web_sessions"
+	cPollTemplateTableName        = "poll_templates"
+	cTemplateOptionTableName      = "template_options"
+	cTemplateParticipantTableName = "template_participants"
+	cJobTableName                 = "jobs"
+	cImageTableName               = "images"
+	cCommentTableName             = "comments"
+	cReactionTableName            = "reactions"
+	cMigrationTableName           = "schema_migrations"
+	cSearchDocumentTableName      = "search_documents"
+	cSyncSearchDocumentFunc       = "sync_search_document"
+	cSearchConfig                 = "simple"
+	cVersion                      = "version"
+	cKind                         = "kind"
+	cSourceID                     = "source_id"
+	cDocument                     = "document"
+	cLastSeen                     = "last_seen"
+	cBadgeReset                   = "badge_reset"
+	cCommentID                    = "comment_id"
+	cEmoji                        = "emoji"
+	cContent                      = "content"
+	cLastEdited                   = "last_edited"
+	cBlobKey                      = "blob_key"
+	cSequenceNumber               = "sequence_number"
+	cClosingDate                  = "closing_date"
+	cPK                           = "ID"
+	cID                           = "id"
+	cToken                        = "token"
+	cDisplayName                  = "display_name"
+	cDeviceType                   = "device_type"
+	cDeviceGUID                   = "device_guid"
+	cProfilePicKey                = "profile_pic_key"
+	cProfilePic                   = "profile_pic"
+	cCreatorID                    = "creator_id"
+	cCreationDate                 = "creation_date"
+	cLastUpdated                  = "last_updated"
+	cTitle                        = "title"
+	cPollID                       = "poll_id"
+	cQuestionID                   = "question_id"
+	cValue                        = "value"
+	cOptionalID                   = "optional_id"
+	cOptionID                     = "option_id"
+	cUserID                       = "user_id"
+	cLastEventType                = "last_event_type"
+	cLastEventUser                = "last_event_user"
+	cLastEventUserID              = "last_event_user_id"
+	cLastEventTitle               = "last_event_title"
+	cGroupID                      = "group_id"
+	cOwnerID                      = "owner_id"
+	cName                         = "name"
+	cInviteToOpenPolls            = "invite_to_open_polls"
+	cExpirationDate               = "expiration_date"
+	cMaxUses                      = "max_uses"
+	cUses                         = "uses"
+	cRevoked                      = "revoked"
+	cGuestPollID                  = "guest_poll_id"
+	cAllowGuests                  = "allow_guests"
+	cCloseJobID                   = "close_job_id"
+	cRole                         = "role"
+	cResultsVisibility            = "results_visibility"
+	cTemplateID                   = "template_id"
+	cNextRun                      = "next_run"
+	cJobID                        = "job_id"
+	cReminderJobIDs               = "reminder_job_ids"
+	cClosed                       = "closed"
+	cWinner                       = "winner"
+	cRunAt                        = "run_at"
+	cRetries                      = "retries"
+	cFailed                       = "failed"
+	cCalendarToken                = "calendar_token"
+	cType                         = "type"
+	cAnonymous                    = "anonymous"
+	cStartDate                    = "start_date"
+	cDuration                     = "duration"
+	cMovieID                      = "movie_id"
+	cMovieTitle                   = "movie_title"
+	cMovieYear                    = "movie_year"
+	cPosterURL                    = "poster_url"
+	cRuntime                      = "runtime"
+	cLatitude                     = "latitude"
+	cLongitude                    = "longitude"
+	cAddress                      = "address"
+	cImageID                      = "image_id"
+	cLastNudge                    = "last_nudge"
 )
@@ -0,0 +1,175 @@
+package database
+
+import (
+	"github.com/roxot/polly"
+	"gopkg.in/gorp.v1"
+)
+
+func (db *Database) InsertGroupMessage(groupMsg *polly.GroupMessage) error {
+	var err error
+
+	// start the transaction
+	tx, err := db.Begin()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// insert the group object
+	err = AddGroupTX(&groupMsg.MetaData, tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// insert the members
+	err = addGroupMembersTX(groupMsg.MetaData.ID, groupMsg.Members, tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// commit the transaction
+	return tx.Commit()
+}
+
+/*
+ * Replaces the name, settings and members of an existing group. Returns the
+ * identifiers of the members that were not part of the group before.
+ */
+func (db *Database) UpdateGroupMessage(groupMsg *polly.GroupMessage) ([]int64,
+	error) {
+
+	var err error
+
+	// retrieve the current members to determine who joined
+	oldMembers, err := db.GetGroupMembersByGroupID(groupMsg.MetaData.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	oldMembersMap := make(map[int64]bool)
+	for _, member := range oldMembers {
+		oldMembersMap[member.UserID] = true
+	}
+
+	// start the transaction
+	tx, err := db.Begin()
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// update the group object
+	err = UpdateGroupTX(groupMsg.MetaData.ID, groupMsg.MetaData.Name,
+		groupMsg.MetaData.InviteToOpenPolls, tx)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// replace the members
+	err = DeleteGroupMembersTX(groupMsg.MetaData.ID, tx)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	err = addGroupMembersTX(groupMsg.MetaData.ID, groupMsg.Members, tx)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// commit the transaction
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	// collect the members that joined
+	joinedIDs := make([]int64, 0)
+	for _, member := range groupMsg.Members {
+		if !oldMembersMap[member.ID] {
+			joinedIDs = append(joinedIDs, member.ID)
+		}
+	}
+
+	return joinedIDs, nil
+}
+
+func (db *Database) DeleteGroup(groupID int64) error {
+	var err error
+
+	// start the transaction
+	tx, err := db.Begin()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// delete the members
+	err = DeleteGroupMembersTX(groupID, tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// delete the group object
+	err = DeleteGroupTX(groupID, tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// commit the transaction
+	return tx.Commit()
+}
+
+func (db *Database) ConstructGroupMessage(groupID int64) (*polly.GroupMessage,
+	error) {
+
+	groupMsg := polly.GroupMessage{}
+
+	// retrieve the group object
+	group, err := db.GetGroupByID(groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	groupMsg.MetaData = *group
+
+	// retrieve the members
+	members, err := db.GetGroupMembersByGroupID(groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	// convert the members to user objects
+	numMembers := len(members)
+	groupMsg.Members = make([]polly.PublicUser, numMembers)
+	for i := 0; i < numMembers; i++ {
+		user, err := db.GetPublicUserByID(members[i].UserID)
+		if err != nil {
+			return nil, err
+		}
+
+		groupMsg.Members[i] = *user
+	}
+
+	return &groupMsg, nil
+}
+
+func addGroupMembersTX(groupID int64, users []polly.PublicUser,
+	tx *gorp.Transaction) error {
+
+	for _, user := range users {
+		member := polly.GroupMember{GroupID: groupID, UserID: user.ID}
+		err := AddGroupMemberTX(&member, tx)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
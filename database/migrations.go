@@ -0,0 +1,258 @@
+package database
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/roxot/polly"
+)
+
+/*
+ * A schema migration that was applied. The migrations table is registered
+ * with the other tables, so truncating the database runs them again.
+ */
+type sMigration struct {
+	Version   int
+	AppliedAt int64 `db:"applied_at"`
+}
+
+/*
+ * Schema changes that the table mapping can't express, applied in order and
+ * each exactly once. Creating the tables never alters existing ones, so every
+ * column added to a mapped table needs a migration as well. Append new
+ * migrations, never change applied ones.
+ */
+var vMigrations = []string{
+
+	// 1: the full-text search documents of questions, options and comments
+	fmt.Sprintf(`create table if not exists %s (
+		%s text not null,
+		%s bigint not null,
+		%s bigint not null,
+		%s text not null,
+		%s tsvector not null,
+		primary key (%s, %s));`, cSearchDocumentTableName, cKind, cSourceID,
+		cPollID, cContent, cDocument, cKind, cSourceID),
+
+	// 2: the indexes used to match the search documents
+	fmt.Sprintf(`create index if not exists %s_%s_idx on %s using gin (%s);
+		create index if not exists %s_%s_idx on %s (%s);`,
+		cSearchDocumentTableName, cDocument, cSearchDocumentTableName,
+		cDocument, cSearchDocumentTableName, cPollID,
+		cSearchDocumentTableName, cPollID),
+
+	// 3: keep the search documents in sync with the rows they're made of
+	fmt.Sprintf(`create or replace function %s() returns trigger as $$
+		begin
+			if TG_OP = 'DELETE' then
+				delete from %s where %s=TG_ARGV[0] and %s=OLD.id;
+				return OLD;
+			end if;
+
+			insert into %s (%s, %s, %s, %s, %s)
+				values (TG_ARGV[0], NEW.id, NEW.poll_id,
+					coalesce(to_jsonb(NEW)->>TG_ARGV[1], ''),
+					to_tsvector('%s',
+						coalesce(to_jsonb(NEW)->>TG_ARGV[1], '')))
+				on conflict (%s, %s) do update set %s=excluded.%s,
+					%s=excluded.%s, %s=excluded.%s;
+			return NEW;
+		end;
+		$$ language plpgsql;`, cSyncSearchDocumentFunc,
+		cSearchDocumentTableName, cKind, cSourceID,
+		cSearchDocumentTableName, cKind, cSourceID, cPollID, cContent,
+		cDocument, cSearchConfig, cKind, cSourceID, cPollID, cPollID,
+		cContent, cContent, cDocument, cDocument) +
+		searchTrigger(cQuestionTableName, polly.SEARCH_KIND_QUESTION,
+			cTitle) +
+		searchTrigger(cOptionTableName, polly.SEARCH_KIND_OPTION, cValue) +
+		searchTrigger(cCommentTableName, polly.SEARCH_KIND_COMMENT,
+			cContent),
+
+	// 4: index the rows that existed before the triggers
+	searchBackfill(cQuestionTableName, polly.SEARCH_KIND_QUESTION, cTitle) +
+		searchBackfill(cOptionTableName, polly.SEARCH_KIND_OPTION, cValue) +
+		searchBackfill(cCommentTableName, polly.SEARCH_KIND_COMMENT,
+			cContent),
+
+	// 5: the last seen sequence number of participants, existing
+	//    participants start out having seen their polls
+	fmt.Sprintf(`alter table %s add column if not exists %s integer not null
+			default 0;
+		update %s set %s=%s.%s from %s where %s.%s=%s.%s;`,
+		cParticipantTableName, cLastSeen, cParticipantTableName, cLastSeen,
+		cPollTableName, cSequenceNumber, cPollTableName, cPollTableName, cID,
+		cParticipantTableName, cPollID),
+
+	// 6: the moment users last reset their badge count
+	fmt.Sprintf(`alter table %s add column if not exists %s bigint not null
+		default 0;`, cUserTableName, cBadgeReset),
+
+	// 7: the group a poll was created for
+	addColumn(cPollTableName, cGroupID, "bigint not null default 0"),
+
+	// 8: the guest accounts and polls that allow guests
+	addColumn(cUserTableName, cGuestPollID, "bigint not null default 0") +
+		addColumn(cPollTableName, cAllowGuests,
+			"boolean not null default false"),
+
+	// 9: the scheduled close job of polls
+	addColumn(cPollTableName, cCloseJobID, "text not null default ''"),
+
+	// 10: participant roles, existing creators become owners
+	addColumn(cParticipantTableName, cRole, "integer not null default 0") +
+		fmt.Sprintf("update %s set %s=%d where %s=(select %s from %s where "+
+			"%s.%s=%s.%s);", cParticipantTableName, cRole,
+			polly.PARTICIPANT_ROLE_OWNER, cUserID, cCreatorID, cPollTableName,
+			cPollTableName, cID, cParticipantTableName, cPollID),
+
+	// 11: anonymous polls
+	addColumn(cPollTableName, cAnonymous, "boolean not null default false"),
+
+	// 12: the results visibility of polls
+	addColumn(cPollTableName, cResultsVisibility,
+		"integer not null default 0"),
+
+	// 13: the reminders and nudges of polls
+	addColumn(cPollTableName, cReminderJobIDs, "text not null default ''") +
+		addColumn(cPollTableName, cLastNudge, "bigint not null default 0"),
+
+	// 14: closed polls and their winning options
+	addColumn(cPollTableName, cClosed, "boolean not null default false") +
+		addColumn(cOptionTableName, cWinner,
+			"boolean not null default false"),
+
+	// 15: calendar tokens and the dates of options
+	addColumn(cUserTableName, cCalendarToken, "text not null default ''") +
+		addColumn(cOptionTableName, cStartDate, "bigint not null default 0") +
+		addColumn(cOptionTableName, cDuration, "bigint not null default 0"),
+
+	// 16: the movies of options
+	addColumn(cOptionTableName, cMovieID, "text not null default ''") +
+		addColumn(cOptionTableName, cMovieTitle, "text not null default ''") +
+		addColumn(cOptionTableName, cMovieYear, "integer not null default 0") +
+		addColumn(cOptionTableName, cPosterURL, "text not null default ''") +
+		addColumn(cOptionTableName, cRuntime, "integer not null default 0"),
+
+	// 17: the locations of options and template options
+	addColumn(cOptionTableName, cLatitude, "double precision") +
+		addColumn(cOptionTableName, cLongitude, "double precision") +
+		addColumn(cOptionTableName, cAddress, "text not null default ''") +
+		addColumn(cTemplateOptionTableName, cLatitude, "double precision") +
+		addColumn(cTemplateOptionTableName, cLongitude, "double precision") +
+		addColumn(cTemplateOptionTableName, cAddress,
+			"text not null default ''"),
+
+	// 18: the storage key of profile pictures
+	addColumn(cUserTableName, cProfilePicKey, "text not null default ''"),
+
+	// 19: the images of options
+	addColumn(cOptionTableName, cImageID, "bigint not null default 0"),
+
+	// 20: the movies of template options
+	addColumn(cTemplateOptionTableName, cMovieID,
+		"text not null default ''") +
+		addColumn(cTemplateOptionTableName, cMovieTitle,
+			"text not null default ''") +
+		addColumn(cTemplateOptionTableName, cMovieYear,
+			"integer not null default 0") +
+		addColumn(cTemplateOptionTableName, cPosterURL,
+			"text not null default ''") +
+		addColumn(cTemplateOptionTableName, cRuntime,
+			"integer not null default 0"),
+
+	// 21: close the polls whose closing date passed before polls were closed
+	//     explicitly, along with their winners
+	fmt.Sprintf(`update %s set %s=%s in (select %s from %s where %s.%s=%s.%s
+			group by %s having count(*)=(select max(votes) from
+				(select count(*) as votes from %s where %s.%s=%s.%s
+					group by %s) as tallies))
+			where %s in (select %s from %s where not %s and
+				%s<=extract(epoch from now())*1000);
+		update %s set %s=true where not %s and
+			%s<=extract(epoch from now())*1000;`, cOptionTableName, cWinner,
+		cID, cOptionID, cVoteTableName, cVoteTableName, cPollID,
+		cOptionTableName, cPollID, cOptionID, cVoteTableName, cVoteTableName,
+		cPollID, cOptionTableName, cPollID, cOptionID, cPollID, cID,
+		cPollTableName, cClosed, cClosingDate, cPollTableName, cClosed,
+		cClosed, cClosingDate),
+}
+
+/*
+ * Applies the migrations that haven't been applied yet. The migrations table
+ * is locked while doing so, so servers starting together don't both apply
+ * them.
+ */
+func (db *Database) Migrate() error {
+	for {
+		tx, err := db.mapping.Begin()
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(fmt.Sprintf("lock table %s in exclusive mode;",
+			cMigrationTableName))
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		version, err := tx.SelectInt(fmt.Sprintf(
+			"select coalesce(max(%s), 0) from %s;", cVersion,
+			cMigrationTableName))
+		if err != nil {
+			tx.Rollback()
+			return err
+		} else if int(version) >= len(vMigrations) {
+			return tx.Rollback()
+		}
+
+		// apply the next migration along with recording it
+		_, err = tx.Exec(vMigrations[version])
+		if err == nil {
+			err = tx.Insert(&sMigration{int(version) + 1,
+				time.Now().UnixNano() / 1000000})
+		}
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %s", version+1, err)
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+/* Drops what the migrations created outside of the mapped tables. */
+func dropMigratedObjects(db *Database) error {
+	_, err := db.mapping.Exec(fmt.Sprintf(
+		"drop table if exists %s; drop function if exists %s() cascade;",
+		cSearchDocumentTableName, cSyncSearchDocumentFunc))
+	return err
+}
+
+/* Adds a column to a table that predates it. */
+func addColumn(table, column, definition string) string {
+	return fmt.Sprintf("alter table %s add column if not exists %s %s;",
+		table, column, definition)
+}
+
+func searchTrigger(table, kind, column string) string {
+	return fmt.Sprintf(`
+		drop trigger if exists %s_search on %s;
+		create trigger %s_search after insert or update or delete on %s
+			for each row execute procedure %s('%s', '%s');`, table, table,
+		table, table, cSyncSearchDocumentFunc, kind, column)
+}
+
+func searchBackfill(table, kind, column string) string {
+	return fmt.Sprintf(`
+		insert into %s (%s, %s, %s, %s, %s)
+			select '%s', %s, %s, coalesce(%s, ''),
+				to_tsvector('%s', coalesce(%s, '')) from %s
+			on conflict do nothing;`, cSearchDocumentTableName, cKind,
+		cSourceID, cPollID, cContent, cDocument, kind, cID, cPollID, column,
+		cSearchConfig, column, table)
+}
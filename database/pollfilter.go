@@ -0,0 +1,166 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/roxot/polly"
+)
+
+const (
+	POLL_STATUS_ALL = iota
+	POLL_STATUS_OPEN
+	POLL_STATUS_CLOSED
+)
+
+const (
+	POLL_SORT_LAST_UPDATED = iota
+	POLL_SORT_CLOSING_DATE
+	POLL_SORT_CREATION_DATE
+)
+
+/*
+ * Restricts and orders the polls listed for a user. The zero value lists all
+ * polls, most recently updated first.
+ */
+type PollFilter struct {
+	Status        int
+	CreatedByMe   bool
+	NotVoted      bool
+	Unread        bool
+	ClosingBefore int64
+	Sort          int
+
+	/* The time in milliseconds that open and closed polls are relative to. */
+	Now int64
+}
+
+/*
+ * Returns the conditions on the polls and participants tables that the filter
+ * adds to a listing for the user in $1, along with their arguments.
+ */
+func (filter *PollFilter) conditions() (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	addCondition := func(format string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(format, len(args)+1))
+	}
+
+	switch filter.Status {
+	case POLL_STATUS_OPEN:
+		addCondition(fmt.Sprintf("not %s.%s and %s.%s>$%%d", cPollTableName,
+			cClosed, cPollTableName, cClosingDate), filter.Now)
+	case POLL_STATUS_CLOSED:
+		addCondition(fmt.Sprintf("(%s.%s or %s.%s<=$%%d)", cPollTableName,
+			cClosed, cPollTableName, cClosingDate), filter.Now)
+	}
+
+	if filter.ClosingBefore != 0 {
+		addCondition(fmt.Sprintf("%s.%s<=$%%d", cPollTableName, cClosingDate),
+			filter.ClosingBefore)
+	}
+
+	if filter.CreatedByMe {
+		conditions = append(conditions, fmt.Sprintf("%s.%s=$1",
+			cPollTableName, cCreatorID))
+	}
+
+	if filter.NotVoted {
+		conditions = append(conditions, fmt.Sprintf("not exists (select 1 "+
+			"from %s where %s.%s=%s.%s and %s.%s=$1)", cVoteTableName,
+			cVoteTableName, cPollID, cPollTableName, cID, cVoteTableName,
+			cUserID))
+	}
+
+	if filter.Unread {
+		conditions = append(conditions, fmt.Sprintf("%s.%s>%s.%s",
+			cPollTableName, cSequenceNumber, cParticipantTableName, cLastSeen))
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+
+	return " and " + strings.Join(conditions, " and "), args
+}
+
+/*
+ * A position in a listing of polls: the value of the sort column and the id
+ * of the last poll seen. Unlike offsets, cursors don't shift when polls are
+ * updated between fetching pages.
+ */
+type PollCursor struct {
+	Sort  int
+	Value int64
+	ID    int64
+}
+
+/* A page of listed polls along with the total number passing the filter. */
+type PollPage struct {
+	Snapshots []polly.PollSnapshot
+	Total     int64
+
+	/* The cursor after the last poll, nil if the listing ends here. */
+	Next *PollCursor
+}
+
+/* A listed poll with the columns only needed for paging. */
+type sListedPoll struct {
+	polly.PollSnapshot
+	CreationDate int64 `db:"creation_date"`
+	Total        int64 `db:"total"`
+}
+
+/* Returns the value of the sort column of the filter for the listed poll. */
+func (filter *PollFilter) sortValue(listedPoll *sListedPoll) int64 {
+	switch filter.Sort {
+	case POLL_SORT_CLOSING_DATE:
+		return listedPoll.ClosingDate
+	case POLL_SORT_CREATION_DATE:
+		return listedPoll.CreationDate
+	default:
+		return listedPoll.LastUpdated
+	}
+}
+
+func (filter *PollFilter) sortColumn() string {
+	switch filter.Sort {
+	case POLL_SORT_CLOSING_DATE:
+		return cClosingDate
+	case POLL_SORT_CREATION_DATE:
+		return cCreationDate
+	default:
+		return cLastUpdated
+	}
+}
+
+/* Polls closing first are listed first, otherwise the newest come first. */
+func (filter *PollFilter) descending() bool {
+	return filter.Sort != POLL_SORT_CLOSING_DATE
+}
+
+/*
+ * Returns the condition on the listed polls that continues after the cursor,
+ * with its first placeholder numbered firstArg.
+ */
+func (filter *PollFilter) afterCursor(firstArg int) string {
+	comparison := ">"
+	if filter.descending() {
+		comparison = "<"
+	}
+
+	return fmt.Sprintf(" where (%s, %s)%s($%d, $%d)", filter.sortColumn(), cID,
+		comparison, firstArg, firstArg+1)
+}
+
+/* Returns the order by clause of the filter, ending on the poll id. */
+func (filter *PollFilter) orderBy() string {
+	direction := "asc"
+	if filter.descending() {
+		direction = "desc"
+	}
+
+	return fmt.Sprintf("%s %s, %s %s", filter.sortColumn(), direction, cID,
+		direction)
+}
@@ -1,10 +1,24 @@
 package database
 
 import (
+	"errors"
+	"time"
+
 	"github.com/roxot/polly"
+	"gopkg.in/gorp.v1"
 )
 
-func (db *Database) InsertPollMessage(pollMsg *polly.PollMessage) error {
+/* Returned when an option refers to an image another option already has. */
+var ErrImageAttached = errors.New("Image already attached.")
+
+/*
+ * Inserts the given poll message. The given hook runs within the same
+ * transaction once the poll has been inserted, so work like scheduling the
+ * closing of the poll commits atomically with it. The hook may be nil.
+ */
+func (db *Database) InsertPollMessage(pollMsg *polly.PollMessage,
+	hook func(tx *gorp.Transaction) error) error {
+
 	var err error
 
 	// start the transaction
@@ -42,6 +56,18 @@ func (db *Database) InsertPollMessage(pollMsg *polly.PollMessage) error {
 			tx.Rollback()
 			return err
 		}
+
+		// attach the image of the option
+		if option.ImageID != 0 {
+			attached, err := AttachImageTX(option.ImageID, option.ID, tx)
+			if err == nil && !attached {
+				err = ErrImageAttached
+			}
+			if err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
 	}
 
 	// insert the participants
@@ -51,6 +77,11 @@ func (db *Database) InsertPollMessage(pollMsg *polly.PollMessage) error {
 		participant := polly.Participant{} // TODO style inconsistency
 		participant.UserID = user.ID
 		participant.PollID = pollMsg.MetaData.ID
+		participant.Role = user.Role
+		if user.ID == pollMsg.MetaData.CreatorID {
+			participant.LastSeen = pollMsg.MetaData.SequenceNumber
+		}
+
 		err = AddParticipantTX(&participant, tx)
 		if err != nil {
 			tx.Rollback()
@@ -58,6 +89,15 @@ func (db *Database) InsertPollMessage(pollMsg *polly.PollMessage) error {
 		}
 	}
 
+	// let the caller finish the poll
+	if hook != nil {
+		err = hook(tx)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
 	// commit the transaction
 	err = tx.Commit()
 	if err != nil {
@@ -67,8 +107,14 @@ func (db *Database) InsertPollMessage(pollMsg *polly.PollMessage) error {
 	return nil
 }
 
-func (db *Database) ConstructPollMessage(pollID int64) (*polly.PollMessage,
-	error) {
+/*
+ * Constructs the poll message of the given poll as seen by the requester. The
+ * votes of anonymous polls are limited to the requester's own, the tallies
+ * hold the counts of everyone. Both are withheld while the results are hidden
+ * from the requester, as are the vote events and option sequence numbers.
+ */
+func (db *Database) ConstructPollMessage(pollID, requesterID int64) (
+	*polly.PollMessage, error) {
 
 	pollMsg := polly.PollMessage{}
 
@@ -93,13 +139,62 @@ func (db *Database) ConstructPollMessage(pollID int64) (*polly.PollMessage,
 		return nil, err
 	}
 
-	// retrieve the votes
-	votes, err := db.GetVotesByPollID(pollID)
-	pollMsg.Votes = votes
+	// retrieve the images of the options
+	images, err := db.GetImagesByPollID(pollID)
 	if err != nil {
 		return nil, err
 	}
 
+	for i := range images {
+		for j := range pollMsg.Options {
+			if pollMsg.Options[j].ID == images[i].OptionID {
+				pollMsg.Options[j].Image = &images[i]
+			}
+		}
+	}
+
+	// retrieve the reaction counts
+	pollMsg.Reactions, err = db.GetReactionCountsByPollID(pollID, requesterID)
+	if err != nil {
+		return nil, err
+	} else if pollMsg.Reactions == nil {
+		pollMsg.Reactions = []polly.ReactionCount{}
+	}
+
+	// retrieve the requester's own votes
+	ownVotes, err := db.GetVotesByPollIDForUser(pollID, requesterID)
+	if err != nil {
+		return nil, err
+	}
+
+	pollMsg.ResultsHidden = !AreResultsVisible(poll, len(ownVotes) > 0)
+	if poll.Anonymous || pollMsg.ResultsHidden {
+		pollMsg.Votes = ownVotes
+	} else {
+		pollMsg.Votes, err = db.GetVotesByPollID(pollID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// withhold what gives away the votes of others while hidden
+	if pollMsg.ResultsHidden {
+		hideVoteEvent(&pollMsg.MetaData)
+		for i := range pollMsg.Options {
+			pollMsg.Options[i].SequenceNumber = 0
+		}
+	}
+
+	// retrieve the vote counts
+	if pollMsg.ResultsHidden {
+		pollMsg.Tallies = []polly.Tally{}
+	} else {
+		pollMsg.Tallies, err = db.GetTalliesByPollID(pollID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// retrieve the participants
 	participants, err := db.GetParticipantsByPollID(pollID)
 	if err != nil {
@@ -117,7 +212,40 @@ func (db *Database) ConstructPollMessage(pollID int64) (*polly.PollMessage,
 		}
 
 		pollMsg.Participants[i] = *user
+		pollMsg.Participants[i].Role = participants[i].Role
 	}
 
 	return &pollMsg, nil
 }
+
+/*
+ * Checks whether the votes of the other participants of the poll may be shown
+ * to a participant, which depends on whether he or she voted already.
+ */
+func AreResultsVisible(poll *polly.Poll, hasVoted bool) bool {
+	switch poll.ResultsVisibility {
+	case polly.RESULTS_VISIBILITY_AFTER_VOTING:
+		return hasVoted || isClosed(poll)
+	case polly.RESULTS_VISIBILITY_AFTER_CLOSE:
+		return isClosed(poll)
+	default:
+		return true
+	}
+}
+
+/* Clears the last event of the poll if it was a vote. */
+func hideVoteEvent(poll *polly.Poll) {
+	switch poll.LastEventType {
+	case polly.EVENT_TYPE_NEW_VOTE, polly.EVENT_TYPE_UPVOTE,
+		polly.EVENT_TYPE_UNDONE_VOTE:
+		poll.LastEventType = 0
+		poll.LastEventUser = ""
+		poll.LastEventUserID = 0
+		poll.LastEventTitle = ""
+	}
+}
+
+/* The closing job may lag behind the closing date, so check both. */
+func isClosed(poll *polly.Poll) bool {
+	return poll.Closed || time.Now().UnixNano()/1000000 > poll.ClosingDate
+}
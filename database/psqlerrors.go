@@ -2,4 +2,5 @@ package database
 
 const (
 	ERR_SERIALIZATION_FAILURE = "40001"
+	ERR_UNIQUE_VIOLATION      = "23505"
 )
@@ -26,6 +26,7 @@ func (db *Database) GetPublicUserByID(id int64) (*polly.PublicUser, error) {
 	publicUser.ID = privateUser.ID
 	publicUser.DisplayName = privateUser.DisplayName
 	publicUser.ProfilePic = privateUser.ProfilePic
+	publicUser.Guest = privateUser.GuestPollID != 0
 	return &publicUser, nil
 }
 
@@ -40,9 +41,10 @@ func (db *Database) GetDeviceInfosForPollExcludeCreator(pollID,
 	creatorID int64) ([]polly.DeviceInfo, error) {
 
 	var deviceInfos []polly.DeviceInfo
-	_, err := db.mapping.Select(&deviceInfos, fmt.Sprintf("select %s.%s, %s.%s"+
-		" from %s, %s where %s.%s=%s.%s and %s.%s=$1 and %s.%s!=$2;",
-		cUserTableName, cDeviceType, cUserTableName, cDeviceGUID,
+	_, err := db.mapping.Select(&deviceInfos, fmt.Sprintf(
+		"select %s.%s, %s.%s, %s from %s, %s where "+
+			"%s.%s=%s.%s and %s.%s=$1 and %s.%s!=$2;",
+		cUserTableName, cDeviceType, cUserTableName, cDeviceGUID, badgeColumn(),
 		cUserTableName, cParticipantTableName, cUserTableName, cID,
 		cParticipantTableName, cUserID, cParticipantTableName, cPollID,
 		cUserTableName, cID), pollID, creatorID)
@@ -54,10 +56,11 @@ func (db *Database) GetDeviceInfosForPollExcludeCreatorAndUser(pollID,
 	creatorID, userID int64) ([]polly.DeviceInfo, error) {
 
 	var deviceInfos []polly.DeviceInfo
-	_, err := db.mapping.Select(&deviceInfos, fmt.Sprintf("select %s.%s, %s.%s"+
-		" from %s, %s where %s.%s=%s.%s and %s.%s=$1 and %s.%s!=$2 and "+
-		"%s.%s != $3;",
-		cUserTableName, cDeviceType, cUserTableName, cDeviceGUID,
+	_, err := db.mapping.Select(&deviceInfos, fmt.Sprintf(
+		"select %s.%s, %s.%s, %s from %s, %s where "+
+			"%s.%s=%s.%s and %s.%s=$1 and %s.%s!=$2 and "+
+			"%s.%s != $3;",
+		cUserTableName, cDeviceType, cUserTableName, cDeviceGUID, badgeColumn(),
 		cUserTableName, cParticipantTableName, cUserTableName, cID,
 		cParticipantTableName, cUserID, cParticipantTableName, cPollID,
 		cUserTableName, cID, cUserTableName, cID), pollID, creatorID, userID)
@@ -65,13 +68,34 @@ func (db *Database) GetDeviceInfosForPollExcludeCreatorAndUser(pollID,
 	return deviceInfos, err
 }
 
+/*
+ * Returns the devices of the participants that haven't voted in the poll,
+ * except for the given user.
+ */
+func (db *Database) GetDeviceInfosForNonVoters(pollID, excludedID int64) (
+	[]polly.DeviceInfo, error) {
+
+	var deviceInfos []polly.DeviceInfo
+	_, err := db.mapping.Select(&deviceInfos, fmt.Sprintf(
+		"select %s.%s, %s.%s, %s from %s, %s where "+
+			"%s.%s=%s.%s and %s.%s=$1 and %s.%s!=$2 and not "+
+			"exists (select 1 from %s where %s.%s=$1 and %s.%s=%s.%s);",
+		cUserTableName, cDeviceType, cUserTableName, cDeviceGUID, badgeColumn(),
+		cUserTableName, cParticipantTableName, cUserTableName, cID,
+		cParticipantTableName, cUserID, cParticipantTableName, cPollID,
+		cUserTableName, cID, cVoteTableName, cVoteTableName, cPollID,
+		cVoteTableName, cUserID, cUserTableName, cID), pollID, excludedID)
+
+	return deviceInfos, err
+}
+
 func (db *Database) GetDeviceInfoForUser(userID int64) (*polly.DeviceInfo,
 	error) {
 
 	var deviceInfo polly.DeviceInfo
 	err := db.mapping.SelectOne(&deviceInfo, fmt.Sprintf(
-		"select %s, %s from %s where %s=$1;", cDeviceGUID, cDeviceType,
-		cUserTableName, cID), userID)
+		"select %s, %s, %s from %s where %s=$1;", cDeviceGUID, cDeviceType,
+		badgeColumn(), cUserTableName, cID), userID)
 	return &deviceInfo, err
 }
 
@@ -79,9 +103,10 @@ func (db *Database) GetDeviceInfosForPoll(pollID int64) ([]polly.DeviceInfo,
 	error) {
 
 	var deviceInfos []polly.DeviceInfo
-	_, err := db.mapping.Select(&deviceInfos, fmt.Sprintf("select %s.%s, %s.%s"+
-		" from %s, %s where %s.%s=%s.%s and %s.%s=$1;",
-		cUserTableName, cDeviceType, cUserTableName, cDeviceGUID,
+	_, err := db.mapping.Select(&deviceInfos, fmt.Sprintf(
+		"select %s.%s, %s.%s, %s from %s, %s where "+
+			"%s.%s=%s.%s and %s.%s=$1;",
+		cUserTableName, cDeviceType, cUserTableName, cDeviceGUID, badgeColumn(),
 		cUserTableName, cParticipantTableName, cUserTableName, cID,
 		cParticipantTableName, cUserID, cParticipantTableName, cPollID),
 		pollID)
@@ -97,22 +122,78 @@ func (db *Database) GetPollByID(id int64) (*polly.Poll, error) {
 }
 
 /*
- * Returns the ordered-by-last-updated list of poll snapshots. Limits the
- * results on the given limit and from the given offset.
+ * Returns a page of the poll snapshots of the user that pass the filter, in
+ * the order of the filter. The snapshots include the poll's question title,
+ * whether the user voted and how many events the user hasn't seen yet. The
+ * page starts after the given cursor if there is one, and at the given offset
+ * from there. The total is counted in the same query, which returns a single
+ * row without a poll when the page is empty.
  */
-func (db *Database) GetPollSnapshotsByUserID(userID int64, limit, offset int) (
-	[]polly.PollSnapshot, error) {
+func (db *Database) GetPollSnapshotsByUserID(userID int64, filter *PollFilter,
+	after *PollCursor, limit, offset int) (*PollPage, error) {
+
+	conditions, args := filter.conditions()
+	args = append([]interface{}{userID}, args...)
 
-	var snapshots []polly.PollSnapshot
-	_, err := db.mapping.Select(&snapshots, fmt.Sprintf(
-		"select %s.%s, %s.%s, %s.%s, %s.%s from %s, %s where %s.%s=%s.%s and "+
-			"%s.%s=$1 order by %s desc limit %d offset %d;",
+	cursorCondition := ""
+	if after != nil {
+		cursorCondition = filter.afterCursor(len(args) + 1)
+		args = append(args, after.Value, after.ID)
+	}
+
+	// select one poll more than requested to know whether the listing goes on
+	var listedPolls []sListedPoll
+	_, err := db.mapping.Select(&listedPolls, fmt.Sprintf(
+		"with listed as (select %s.%s, %s.%s, %s.%s, %s.%s, %s.%s, %s.%s, "+
+			"%s.%s, exists (select 1 from %s where %s.%s=%s.%s and %s.%s=$1) "+
+			"as voted, %s.%s>%s.%s as unread, greatest(%s.%s-%s.%s, 0) as "+
+			"unread_count from %s, %s, %s where %s.%s=%s.%s and %s.%s=%s.%s "+
+			"and %s.%s=$1%s), page as (select * from listed%s order by %s "+
+			"limit %d offset %d) select coalesce(%s, 0) as %s, "+
+			"coalesce(%s, 0) as %s, coalesce(%s, 0) as %s, coalesce(%s, 0) "+
+			"as %s, coalesce(%s, false) as %s, coalesce(%s, 0) as %s, "+
+			"coalesce(%s, '') as %s, voted, unread, unread_count, total from "+
+			"(select count(*) as total from listed) as counted left join "+
+			"page on true order by %s;",
 		cPollTableName, cID, cPollTableName, cLastUpdated,
 		cPollTableName, cSequenceNumber, cPollTableName, cClosingDate,
-		cParticipantTableName, cPollTableName, cParticipantTableName, cPollID,
-		cPollTableName, cID, cParticipantTableName, cUserID, cLastUpdated,
-		limit, offset), userID)
-	return snapshots, err
+		cPollTableName, cClosed, cPollTableName, cCreationDate,
+		cQuestionTableName, cTitle,
+		cVoteTableName, cVoteTableName, cPollID, cPollTableName, cID,
+		cVoteTableName, cUserID,
+		cPollTableName, cSequenceNumber, cParticipantTableName, cLastSeen,
+		cPollTableName, cSequenceNumber, cParticipantTableName, cLastSeen,
+		cPollTableName, cParticipantTableName, cQuestionTableName,
+		cParticipantTableName, cPollID, cPollTableName, cID,
+		cQuestionTableName, cPollID, cPollTableName, cID,
+		cParticipantTableName, cUserID, conditions, cursorCondition,
+		filter.orderBy(), limit+1, offset, cID, cID, cLastUpdated,
+		cLastUpdated, cSequenceNumber, cSequenceNumber, cClosingDate,
+		cClosingDate, cClosed, cClosed, cCreationDate, cCreationDate, cTitle,
+		cTitle, filter.orderBy()), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	// an empty page only has the row carrying the total
+	page := PollPage{}
+	page.Total = listedPolls[0].Total
+	if listedPolls[0].ID == 0 {
+		return &page, nil
+	}
+
+	if len(listedPolls) > limit {
+		listedPolls = listedPolls[:limit]
+		last := &listedPolls[limit-1]
+		page.Next = &PollCursor{filter.Sort, filter.sortValue(last), last.ID}
+	}
+
+	page.Snapshots = make([]polly.PollSnapshot, len(listedPolls))
+	for i := range listedPolls {
+		page.Snapshots[i] = listedPolls[i].PollSnapshot
+	}
+
+	return &page, nil
 }
 
 func (db *Database) GetPollsByUserID(userID int64) ([]polly.Poll, error) {
@@ -181,6 +262,42 @@ func (db *Database) GetParticipantsByPollID(pollID int64) (
 	return participants, err
 }
 
+func (db *Database) GetParticipant(userID, pollID int64) (*polly.Participant,
+	error) {
+
+	var participant polly.Participant
+	err := db.mapping.SelectOne(&participant, fmt.Sprintf(
+		"select * from %s where %s=$1 and %s=$2;", cParticipantTableName,
+		cUserID, cPollID), userID, pollID)
+	return &participant, err
+}
+
+func GetParticipantTX(userID, pollID int64, tx *gorp.Transaction) (
+	*polly.Participant, error) {
+
+	var participant polly.Participant
+	err := tx.SelectOne(&participant, fmt.Sprintf(
+		"select * from %s where %s=$1 and %s=$2;", cParticipantTableName,
+		cUserID, cPollID), userID, pollID)
+	return &participant, err
+}
+
+/*
+ * Returns the participant that takes over a poll when its owner leaves,
+ * preferring admins over voters and viewers and earlier participants over
+ * later ones. Guests can't own polls.
+ */
+func GetSuccessorTX(pollID, ownerID int64, tx *gorp.Transaction) (
+	*polly.Participant, error) {
+
+	var participant polly.Participant
+	err := tx.SelectOne(&participant, fmt.Sprintf(
+		"select * from %s where %s=$1 and %s<>$2 and %s>0 order by %s=%d "+
+			"desc, %s asc limit 1;", cParticipantTableName, cPollID, cUserID,
+		cUserID, cRole, polly.PARTICIPANT_ROLE_ADMIN, cID), pollID, ownerID)
+	return &participant, err
+}
+
 func (db *Database) GetVotesByPollID(pollID int64) ([]polly.Vote, error) {
 	var votes []polly.Vote
 	_, err := db.mapping.Select(&votes,
@@ -189,6 +306,25 @@ func (db *Database) GetVotesByPollID(pollID int64) ([]polly.Vote, error) {
 	return votes, err
 }
 
+func (db *Database) GetVotesByPollIDForUser(pollID, userID int64) (
+	[]polly.Vote, error) {
+
+	var votes []polly.Vote
+	_, err := db.mapping.Select(&votes, fmt.Sprintf(
+		"select * from %s where %s=$1 and %s=$2;", cVoteTableName, cPollID,
+		cUserID), pollID, userID)
+	return votes, err
+}
+
+/* Returns the number of votes per option, options without votes are left out. */
+func (db *Database) GetTalliesByPollID(pollID int64) ([]polly.Tally, error) {
+	var tallies []polly.Tally
+	_, err := db.mapping.Select(&tallies, fmt.Sprintf(
+		"select %s, count(*) as votes from %s where %s=$1 group by %s;",
+		cOptionID, cVoteTableName, cPollID, cOptionID), pollID)
+	return tallies, err
+}
+
 func (db *Database) GetVoteByID(voteID int64) (*polly.Vote, error) {
 	var vote polly.Vote
 	err := db.mapping.SelectOne(&vote,
@@ -222,8 +358,18 @@ func GetPollSnapshotTX(pollID int64, tx *gorp.Transaction) (*polly.PollSnapshot,
 
 	var snapshot polly.PollSnapshot
 	err := tx.SelectOne(&snapshot, fmt.Sprintf(
-		"select %s, %s, %s, %s from %s where %s=$1;", cID, cLastUpdated,
-		cSequenceNumber, cClosingDate, cPollTableName, cID), pollID)
+		"select %s, %s, %s, %s, %s from %s where %s=$1;", cID, cLastUpdated,
+		cSequenceNumber, cClosingDate, cClosed, cPollTableName, cID), pollID)
+	return &snapshot, err
+}
+
+func (db *Database) GetPollSnapshot(pollID int64) (*polly.PollSnapshot,
+	error) {
+
+	var snapshot polly.PollSnapshot
+	err := db.mapping.SelectOne(&snapshot, fmt.Sprintf(
+		"select %s, %s, %s, %s, %s from %s where %s=$1;", cID, cLastUpdated,
+		cSequenceNumber, cClosingDate, cClosed, cPollTableName, cID), pollID)
 	return &snapshot, err
 }
 
@@ -231,3 +377,245 @@ func (db *Database) GetPollCreatorID(pollID int64) (int64, error) {
 	return db.mapping.SelectInt(fmt.Sprintf("select %s from %s where %s=$1;",
 		cCreatorID, cPollTableName, cID), pollID)
 }
+
+func (db *Database) GetGroupByID(groupID int64) (*polly.Group, error) {
+	var group polly.Group
+	err := db.mapping.SelectOne(&group,
+		fmt.Sprintf("select * from %s where %s=$1;", cGroupTableName, cID),
+		groupID)
+	return &group, err
+}
+
+func (db *Database) GetGroupsByOwnerID(ownerID int64) ([]polly.Group, error) {
+	var groups []polly.Group
+	_, err := db.mapping.Select(&groups,
+		fmt.Sprintf("select * from %s where %s=$1 order by %s;",
+			cGroupTableName, cOwnerID, cName), ownerID)
+	return groups, err
+}
+
+func (db *Database) GetGroupMembersByGroupID(groupID int64) (
+	[]polly.GroupMember, error) {
+
+	var members []polly.GroupMember
+	_, err := db.mapping.Select(&members,
+		fmt.Sprintf("select * from %s where %s=$1;", cGroupMemberTableName,
+			cGroupID), groupID)
+	return members, err
+}
+
+/* Returns the identifiers of the polls created for the given group that have
+ * not closed yet at the given time. */
+func (db *Database) GetOpenPollIDsByGroupID(groupID, now int64) ([]int64,
+	error) {
+
+	var polls []polly.Poll
+	_, err := db.mapping.Select(&polls,
+		fmt.Sprintf("select %s from %s where %s=$1 and %s>$2;", cID,
+			cPollTableName, cGroupID, cClosingDate), groupID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	pollIDs := make([]int64, len(polls))
+	for i := range polls {
+		pollIDs[i] = polls[i].ID
+	}
+
+	return pollIDs, nil
+}
+
+func (db *Database) GetInviteLinkByID(id int64) (*polly.InviteLink, error) {
+	var inviteLink polly.InviteLink
+	err := db.mapping.SelectOne(&inviteLink,
+		fmt.Sprintf("select * from %s where %s=$1;", cInviteLinkTableName,
+			cID), id)
+	return &inviteLink, err
+}
+
+func (db *Database) GetInviteLinkByToken(token string) (*polly.InviteLink,
+	error) {
+
+	var inviteLink polly.InviteLink
+	err := db.mapping.SelectOne(&inviteLink,
+		fmt.Sprintf("select * from %s where %s=$1;", cInviteLinkTableName,
+			cToken), token)
+	return &inviteLink, err
+}
+
+func (db *Database) GetInviteLinksByPollID(pollID int64) ([]polly.InviteLink,
+	error) {
+
+	var inviteLinks []polly.InviteLink
+	_, err := db.mapping.Select(&inviteLinks,
+		fmt.Sprintf("select * from %s where %s=$1 order by %s;",
+			cInviteLinkTableName, cPollID, cCreationDate), pollID)
+	return inviteLinks, err
+}
+
+func (db *Database) GetWebSessionByToken(token string) (*polly.WebSession,
+	error) {
+
+	var session polly.WebSession
+	err := db.mapping.SelectOne(&session,
+		fmt.Sprintf("select * from %s where %s=$1;", cWebSessionTableName,
+			cToken), token)
+	return &session, err
+}
+
+/* Returns the lowest user identifier in use, or zero if there is none lower. */
+func GetMinUserIDTX(tx *gorp.Transaction) (int64, error) {
+	return tx.SelectInt(fmt.Sprintf("select least(coalesce(min(%s), 0), 0) "+
+		"from %s;", cID, cUserTableName))
+}
+
+func (db *Database) GetPollTemplateByID(templateID int64) (*polly.PollTemplate,
+	error) {
+
+	var template polly.PollTemplate
+	err := db.mapping.SelectOne(&template,
+		fmt.Sprintf("select * from %s where %s=$1;", cPollTemplateTableName,
+			cID), templateID)
+	return &template, err
+}
+
+func (db *Database) GetPollTemplatesByCreatorID(creatorID int64) (
+	[]polly.PollTemplate, error) {
+
+	var templates []polly.PollTemplate
+	_, err := db.mapping.Select(&templates,
+		fmt.Sprintf("select * from %s where %s=$1 order by %s;",
+			cPollTemplateTableName, cCreatorID, cID), creatorID)
+	return templates, err
+}
+
+func (db *Database) GetTemplateOptionsByTemplateID(templateID int64) (
+	[]polly.TemplateOption, error) {
+
+	var options []polly.TemplateOption
+	_, err := db.mapping.Select(&options,
+		fmt.Sprintf("select * from %s where %s=$1 order by %s;",
+			cTemplateOptionTableName, cTemplateID, cID), templateID)
+	return options, err
+}
+
+func (db *Database) GetTemplateParticipantsByTemplateID(templateID int64) (
+	[]polly.TemplateParticipant, error) {
+
+	var participants []polly.TemplateParticipant
+	_, err := db.mapping.Select(&participants,
+		fmt.Sprintf("select * from %s where %s=$1 order by %s;",
+			cTemplateParticipantTableName, cTemplateID, cID), templateID)
+	return participants, err
+}
+
+/*
+ * Returns the job that is due the longest at the given time and locks it for
+ * the rest of the transaction. Jobs locked by other transactions are skipped,
+ * so multiple workers can run jobs side by side.
+ */
+func GetDueJobTX(now int64, tx *gorp.Transaction) (*polly.Job, error) {
+	var job polly.Job
+	err := tx.SelectOne(&job, fmt.Sprintf("select * from %s where %s=false "+
+		"and %s<=$1 order by %s limit 1 for update skip locked;",
+		cJobTableName, cFailed, cRunAt, cRunAt), now)
+	return &job, err
+}
+
+func (db *Database) GetUserByCalendarToken(calendarToken string) (
+	*polly.PrivateUser, error) {
+
+	var user polly.PrivateUser
+	err := db.mapping.SelectOne(&user,
+		fmt.Sprintf("select * from %s where %s=$1;", cUserTableName,
+			cCalendarToken), calendarToken)
+	return &user, err
+}
+
+/*
+ * Returns the identifiers of the closed polls of the given question type the
+ * given user participates in.
+ */
+func (db *Database) GetClosedPollIDsByUserID(userID int64, questionType int) (
+	[]int64, error) {
+
+	var polls []polly.Poll
+	_, err := db.mapping.Select(&polls, fmt.Sprintf("select %s.%s from %s, "+
+		"%s, %s where %s.%s=%s.%s and %s.%s=%s.%s and %s.%s=$1 and "+
+		"%s.%s=true and %s.%s=$2 order by %s.%s;",
+		cPollTableName, cID, cPollTableName, cParticipantTableName,
+		cQuestionTableName, cPollTableName, cID, cParticipantTableName,
+		cPollID, cPollTableName, cID, cQuestionTableName, cPollID,
+		cParticipantTableName, cUserID, cPollTableName, cClosed,
+		cQuestionTableName, cType, cPollTableName, cClosingDate), userID,
+		questionType)
+	if err != nil {
+		return nil, err
+	}
+
+	pollIDs := make([]int64, len(polls))
+	for idx, poll := range polls {
+		pollIDs[idx] = poll.ID
+	}
+
+	return pollIDs, nil
+}
+
+func (db *Database) GetWinnersByPollID(pollID int64) ([]polly.Option, error) {
+	var options []polly.Option
+	_, err := db.mapping.Select(&options,
+		fmt.Sprintf("select * from %s where %s=$1 and %s=true;",
+			cOptionTableName, cPollID, cWinner), pollID)
+	return options, err
+}
+
+func (db *Database) GetImageByID(imageID int64) (*polly.Image, error) {
+	var image polly.Image
+	err := db.mapping.SelectOne(&image, fmt.Sprintf(
+		"select * from %s where %s=$1;", cImageTableName, cID), imageID)
+	return &image, err
+}
+
+/* Returns the images attached to the options of the given poll. */
+func (db *Database) GetImagesByPollID(pollID int64) ([]polly.Image, error) {
+	var images []polly.Image
+	_, err := db.mapping.Select(&images, fmt.Sprintf("select %s.* from %s "+
+		"join %s on %s.%s=%s.%s where %s.%s=$1;", cImageTableName,
+		cImageTableName, cOptionTableName, cImageTableName, cOptionID,
+		cOptionTableName, cID, cOptionTableName, cPollID), pollID)
+	return images, err
+}
+
+func (db *Database) GetCommentByID(commentID int64) (*polly.Comment, error) {
+	var comment polly.Comment
+	err := db.mapping.SelectOne(&comment, fmt.Sprintf(
+		"select * from %s where %s=$1;", cCommentTableName, cID), commentID)
+	return &comment, err
+}
+
+/* Returns the comments on the given poll, newest first. */
+func (db *Database) GetCommentsByPollID(pollID int64, limit, offset int) (
+	[]polly.Comment, error) {
+
+	var comments []polly.Comment
+	_, err := db.mapping.Select(&comments, fmt.Sprintf(
+		"select * from %s where %s=$1 order by %s desc limit %d offset %d;",
+		cCommentTableName, cPollID, cID, limit, offset), pollID)
+	return comments, err
+}
+
+/*
+ * Returns the reaction counts on the options and comments of the given poll,
+ * marking the emoji the given user reacted with.
+ */
+func (db *Database) GetReactionCountsByPollID(pollID, userID int64) (
+	[]polly.ReactionCount, error) {
+
+	var counts []polly.ReactionCount
+	_, err := db.mapping.Select(&counts, fmt.Sprintf("select %s, %s, %s, "+
+		"count(*) as count, bool_or(%s=$2) as mine from %s where %s=$1 "+
+		"group by %s, %s, %s order by min(%s);", cOptionID, cCommentID, cEmoji,
+		cUserID, cReactionTableName, cPollID, cOptionID, cCommentID, cEmoji,
+		cID), pollID, userID)
+	return counts, err
+}
@@ -0,0 +1,69 @@
+package database
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/roxot/polly"
+)
+
+/*
+ * The matches are marked with control characters and only turned into tags
+ * once the rest of the highlight has been escaped. Control characters in the
+ * text itself can at most add stray bold tags, never markup of their own.
+ */
+const (
+	cHighlightStart   = "\x01"
+	cHighlightStop    = "\x02"
+	cHighlightOptions = "StartSel=\"" + cHighlightStart + "\", StopSel=\"" +
+		cHighlightStop + "\", MaxFragments=2"
+)
+
+var vHighlightTags = strings.NewReplacer(cHighlightStart, "<b>",
+	cHighlightStop, "</b>")
+
+/*
+ * Searches the questions, options and comments of the polls the user
+ * participates in for text containing all the given words, each matching as
+ * a prefix. The best ranked results come first. The words may only consist
+ * of letters and digits. The highlights are HTML, escaped apart from the bold
+ * tags around the matches.
+ */
+func (db *Database) SearchPolls(userID int64, words []string, limit,
+	offset int) ([]polly.SearchResult, error) {
+
+	prefixes := make([]string, len(words))
+	for idx, word := range words {
+		prefixes[idx] = word + ":*"
+	}
+
+	var results []polly.SearchResult
+	_, err := db.mapping.Select(&results, fmt.Sprintf(
+		"select %s.%s, %s.%s, %s.%s, %s.%s, ts_headline('%s', %s.%s, query, "+
+			"$3) as highlight, ts_rank(%s.%s, query) as rank from %s, %s, "+
+			"%s, to_tsquery('%s', $2) query where %s.%s=%s.%s and %s.%s=%s.%s "+
+			"and %s.%s=$1 and %s.%s @@ query order by rank desc, %s.%s desc "+
+			"limit %d offset %d;",
+		cSearchDocumentTableName, cPollID, cQuestionTableName, cTitle,
+		cSearchDocumentTableName, cKind, cSearchDocumentTableName, cSourceID,
+		cSearchConfig, cSearchDocumentTableName, cContent,
+		cSearchDocumentTableName, cDocument,
+		cSearchDocumentTableName, cParticipantTableName, cQuestionTableName,
+		cSearchConfig,
+		cParticipantTableName, cPollID, cSearchDocumentTableName, cPollID,
+		cQuestionTableName, cPollID, cSearchDocumentTableName, cPollID,
+		cParticipantTableName, cUserID, cSearchDocumentTableName, cDocument,
+		cSearchDocumentTableName, cPollID, limit, offset), userID,
+		strings.Join(prefixes, " & "), cHighlightOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	for idx := range results {
+		results[idx].Highlight = vHighlightTags.Replace(
+			html.EscapeString(results[idx].Highlight))
+	}
+
+	return results, nil
+}
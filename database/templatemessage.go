@@ -0,0 +1,129 @@
+package database
+
+import (
+	"github.com/roxot/polly"
+)
+
+func (db *Database) InsertPollTemplateMessage(
+	templateMsg *polly.PollTemplateMessage) error {
+
+	var err error
+
+	// start the transaction
+	tx, err := db.Begin()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// insert the template object
+	err = AddPollTemplateTX(&templateMsg.MetaData, tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// insert the options
+	for i := range templateMsg.Options {
+		templateMsg.Options[i].TemplateID = templateMsg.MetaData.ID
+		err = AddTemplateOptionTX(&templateMsg.Options[i], tx)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	// insert the participants
+	for _, user := range templateMsg.Participants {
+		participant := polly.TemplateParticipant{
+			TemplateID: templateMsg.MetaData.ID,
+			UserID:     user.ID,
+			Role:       user.Role,
+		}
+
+		err = AddTemplateParticipantTX(&participant, tx)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	// commit the transaction
+	return tx.Commit()
+}
+
+func (db *Database) DeletePollTemplate(templateID int64) error {
+	var err error
+
+	// start the transaction
+	tx, err := db.Begin()
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// delete the options
+	err = DeleteTemplateOptionsTX(templateID, tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// delete the participants
+	err = DeleteTemplateParticipantsTX(templateID, tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// delete the template object
+	err = DeletePollTemplateTX(templateID, tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	// commit the transaction
+	return tx.Commit()
+}
+
+func (db *Database) ConstructPollTemplateMessage(templateID int64) (
+	*polly.PollTemplateMessage, error) {
+
+	templateMsg := polly.PollTemplateMessage{}
+
+	// retrieve the template object
+	template, err := db.GetPollTemplateByID(templateID)
+	if err != nil {
+		return nil, err
+	}
+
+	templateMsg.MetaData = *template
+
+	// retrieve the options
+	templateMsg.Options, err = db.GetTemplateOptionsByTemplateID(templateID)
+	if err != nil {
+		return nil, err
+	}
+
+	// retrieve the participants
+	participants, err := db.GetTemplateParticipantsByTemplateID(templateID)
+	if err != nil {
+		return nil, err
+	}
+
+	// convert the participants to user objects
+	numParticipants := len(participants)
+	templateMsg.Participants = make([]polly.PublicUser, numParticipants)
+	for i := 0; i < numParticipants; i++ {
+		user, err := db.GetPublicUserByID(participants[i].UserID)
+		if err != nil {
+			return nil, err
+		}
+
+		templateMsg.Participants[i] = *user
+		templateMsg.Participants[i].Role = participants[i].Role
+	}
+
+	return &templateMsg, nil
+}
@@ -29,9 +29,16 @@ func (db *Database) UpdateDisplayName(userID int64, displayName string) error {
 	return err
 }
 
-func (db *Database) UpdateProfilePic(userID int64, profilePic string) error {
-	_, err := db.mapping.Exec(fmt.Sprintf("update %s set %s=$1 where %s=$2;",
-		cUserTableName, cProfilePic, cID), profilePic, userID)
+/*
+ * Updates the profile picture of a user. The key refers to the stored image of
+ * an uploaded picture and is empty for pictures hosted elsewhere.
+ */
+func (db *Database) UpdateProfilePic(userID int64, profilePic,
+	profilePicKey string) error {
+
+	_, err := db.mapping.Exec(fmt.Sprintf(
+		"update %s set %s=$1, %s=$2 where %s=$3;", cUserTableName, cProfilePic,
+		cProfilePicKey, cID), profilePic, profilePicKey, userID)
 	return err
 }
 
@@ -53,16 +60,29 @@ func (db *Database) UpdateSequenceNumber(pollID int64) error {
 	return err
 }
 
-/* Updates a poll its last updated and sequence number. */
+/*
+ * Updates a poll its last updated and sequence number. The actor causing the
+ * event has seen it, so if the actor was up to date before, that stays so.
+ * The actor differs from the last event user in anonymous polls, where the
+ * latter is left empty, and is 0 for events nobody caused.
+ */
 func UpdatePollTX(pollID, lastUpdated int64, lastEventType int,
 	lastEventUser string, lastEventUserID int64, lastEventTitle string,
-	tx *gorp.Transaction) error {
+	actorID int64, tx *gorp.Transaction) error {
 	_, err := tx.Exec(fmt.Sprintf(
 		"update %s set %s=%s+1, %s=$1, %s=$2, %s=$3, %s=$4, %s=$5 where %s=$6;",
 		cPollTableName, cSequenceNumber, cSequenceNumber, cLastUpdated,
 		cLastEventType, cLastEventUser, cLastEventUserID, cLastEventTitle, cID),
 		lastUpdated, lastEventType, lastEventUser, lastEventUserID, lastEventTitle,
 		pollID)
+	if err != nil || actorID == 0 {
+		return err
+	}
+
+	_, err = tx.Exec(fmt.Sprintf("update %s set %s=%s+1 where %s=$1 and "+
+		"%s=$2 and %s=(select %s-1 from %s where %s=$1);",
+		cParticipantTableName, cLastSeen, cLastSeen, cPollID, cUserID,
+		cLastSeen, cSequenceNumber, cPollTableName, cID), pollID, actorID)
 	return err
 }
 
@@ -79,3 +99,260 @@ func UpdateSequenceNumberTX(pollID int64, tx *gorp.Transaction) error {
 		cPollTableName, cSequenceNumber, cSequenceNumber, cID), pollID)
 	return err
 }
+
+func UpdateQuestionTitleTX(pollID int64, title string,
+	tx *gorp.Transaction) error {
+
+	_, err := tx.Exec(fmt.Sprintf("update %s set %s=$1 where %s=$2;",
+		cQuestionTableName, cTitle, cPollID), title, pollID)
+	return err
+}
+
+func UpdateClosingDateTX(pollID, closingDate int64,
+	tx *gorp.Transaction) error {
+
+	_, err := tx.Exec(fmt.Sprintf("update %s set %s=$1 where %s=$2;",
+		cPollTableName, cClosingDate, cID), closingDate, pollID)
+	return err
+}
+
+func UpdateResultsVisibilityTX(pollID int64, resultsVisibility int,
+	tx *gorp.Transaction) error {
+
+	_, err := tx.Exec(fmt.Sprintf("update %s set %s=$1 where %s=$2;",
+		cPollTableName, cResultsVisibility, cID), resultsVisibility, pollID)
+	return err
+}
+
+func (db *Database) UpdateCloseJobID(pollID int64, closeJobID string) error {
+	_, err := db.mapping.Exec(fmt.Sprintf("update %s set %s=$1 where %s=$2;",
+		cPollTableName, cCloseJobID, cID), closeJobID, pollID)
+	return err
+}
+
+func (db *Database) UpdateReminderJobIDs(pollID int64,
+	reminderJobIDs string) error {
+
+	_, err := db.mapping.Exec(fmt.Sprintf("update %s set %s=$1 where %s=$2;",
+		cPollTableName, cReminderJobIDs, cID), reminderJobIDs, pollID)
+	return err
+}
+
+/*
+ * Remembers the given time as the last nudge of the given poll, unless the
+ * previous nudge happened after the given earliest time. Returns whether the
+ * nudge was stored.
+ */
+func (db *Database) UpdateLastNudge(pollID, now, earliest int64) (bool,
+	error) {
+
+	result, err := db.mapping.Exec(fmt.Sprintf(
+		"update %s set %s=$1 where %s=$2 and %s<=$3;", cPollTableName,
+		cLastNudge, cID, cLastNudge), now, pollID, earliest)
+	if err != nil {
+		return false, err
+	}
+
+	numRows, err := result.RowsAffected()
+	return numRows > 0, err
+}
+
+/* Updates the retries left of the given job and when it runs next. */
+func UpdateJobRetriesTX(jobID int64, retries int, runAt int64,
+	tx *gorp.Transaction) error {
+
+	_, err := tx.Exec(fmt.Sprintf("update %s set %s=$1, %s=$2 where %s=$3;",
+		cJobTableName, cRetries, cRunAt, cID), retries, runAt, jobID)
+	return err
+}
+
+func UpdateJobFailedTX(jobID int64, tx *gorp.Transaction) error {
+	_, err := tx.Exec(fmt.Sprintf("update %s set %s=true where %s=$1;",
+		cJobTableName, cFailed, cID), jobID)
+	return err
+}
+
+func UpdateCloseJobIDTX(pollID int64, closeJobID string,
+	tx *gorp.Transaction) error {
+
+	_, err := tx.Exec(fmt.Sprintf("update %s set %s=$1 where %s=$2;",
+		cPollTableName, cCloseJobID, cID), closeJobID, pollID)
+	return err
+}
+
+func UpdateReminderJobIDsTX(pollID int64, reminderJobIDs string,
+	tx *gorp.Transaction) error {
+
+	_, err := tx.Exec(fmt.Sprintf("update %s set %s=$1 where %s=$2;",
+		cPollTableName, cReminderJobIDs, cID), reminderJobIDs, pollID)
+	return err
+}
+
+func (db *Database) UpdateCalendarToken(userID int64,
+	calendarToken string) error {
+
+	_, err := db.mapping.Exec(fmt.Sprintf("update %s set %s=$1 where %s=$2;",
+		cUserTableName, cCalendarToken, cID), calendarToken, userID)
+	return err
+}
+
+func (db *Database) UpdateTemplateSchedule(templateID, nextRun int64,
+	jobID string) error {
+
+	_, err := db.mapping.Exec(fmt.Sprintf(
+		"update %s set %s=$1, %s=$2 where %s=$3;", cPollTemplateTableName,
+		cNextRun, cJobID, cID), nextRun, jobID, templateID)
+	return err
+}
+
+/*
+ * Marks the given poll closed unless it is already, reporting whether it was
+ * this call that closed it.
+ */
+func ClosePollTX(pollID int64, tx *gorp.Transaction) (bool, error) {
+	result, err := tx.Exec(fmt.Sprintf(
+		"update %s set %s=true where %s=$1 and not %s;", cPollTableName,
+		cClosed, cID, cClosed), pollID)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected == 1, err
+}
+
+func UpdateClosedTX(pollID int64, closed bool, tx *gorp.Transaction) error {
+	_, err := tx.Exec(fmt.Sprintf("update %s set %s=$1 where %s=$2;",
+		cPollTableName, cClosed, cID), closed, pollID)
+	return err
+}
+
+/*
+ * Marks the options with the most votes as the winners of the given poll, all
+ * of them in case of a tie. Polls without votes have no winner.
+ */
+func UpdateWinnersTX(pollID int64, tx *gorp.Transaction) error {
+	_, err := tx.Exec(fmt.Sprintf("update %s set %s=%s in (select %s from "+
+		"%s where %s=$1 group by %s having count(*)=(select max(votes) from "+
+		"(select count(*) as votes from %s where %s=$1 group by %s) as "+
+		"tallies)) where %s=$1;", cOptionTableName, cWinner, cID, cOptionID,
+		cVoteTableName, cPollID, cOptionID, cVoteTableName, cPollID,
+		cOptionID, cPollID), pollID)
+	return err
+}
+
+func ClearWinnersTX(pollID int64, tx *gorp.Transaction) error {
+	_, err := tx.Exec(fmt.Sprintf("update %s set %s=false where %s=$1;",
+		cOptionTableName, cWinner, cPollID), pollID)
+	return err
+}
+
+func UpdateParticipantRoleTX(userID, pollID int64, role int,
+	tx *gorp.Transaction) error {
+
+	_, err := tx.Exec(fmt.Sprintf(
+		"update %s set %s=$1 where %s=$2 and %s=$3;", cParticipantTableName,
+		cRole, cUserID, cPollID), role, userID, pollID)
+	return err
+}
+
+func UpdatePollCreatorTX(pollID, creatorID int64, tx *gorp.Transaction) error {
+	_, err := tx.Exec(fmt.Sprintf("update %s set %s=$1 where %s=$2;",
+		cPollTableName, cCreatorID, cID), creatorID, pollID)
+	return err
+}
+
+func UpdateGroupTX(groupID int64, name string, inviteToOpenPolls bool,
+	tx *gorp.Transaction) error {
+
+	_, err := tx.Exec(fmt.Sprintf("update %s set %s=$1, %s=$2 where %s=$3;",
+		cGroupTableName, cName, cInviteToOpenPolls, cID), name,
+		inviteToOpenPolls, groupID)
+	return err
+}
+
+func (db *Database) RevokeInviteLink(inviteLinkID int64) error {
+	_, err := db.mapping.Exec(fmt.Sprintf("update %s set %s=true where %s=$1;",
+		cInviteLinkTableName, cRevoked, cID), inviteLinkID)
+	return err
+}
+
+/*
+ * Increments the number of uses of an invite link, unless its maximum number
+ * of uses has been reached. Returns whether the link could be used.
+ */
+func UseInviteLinkTX(inviteLinkID int64, tx *gorp.Transaction) (bool, error) {
+	result, err := tx.Exec(fmt.Sprintf("update %s set %s=%s+1 where %s=$1 "+
+		"and %s=false and (%s=0 or %s<%s);", cInviteLinkTableName, cUses, cUses,
+		cID, cRevoked, cMaxUses, cUses, cMaxUses), inviteLinkID)
+	if err != nil {
+		return false, err
+	}
+
+	numRows, err := result.RowsAffected()
+	return numRows == 1, err
+}
+
+func (db *Database) UpdateAllowGuests(pollID int64, allowGuests bool) error {
+	_, err := db.mapping.Exec(fmt.Sprintf("update %s set %s=$1 where %s=$2;",
+		cPollTableName, cAllowGuests, cID), allowGuests, pollID)
+	return err
+}
+
+/*
+ * Attaches an image to an option, unless it has been attached to one before.
+ * Returns whether the image was attached.
+ */
+func AttachImageTX(imageID, optionID int64, tx *gorp.Transaction) (bool,
+	error) {
+
+	result, err := tx.Exec(fmt.Sprintf(
+		"update %s set %s=$1 where %s=$2 and %s=0;", cImageTableName,
+		cOptionID, cID, cOptionID), optionID, imageID)
+	if err != nil {
+		return false, err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected == 1, err
+}
+
+func UpdateCommentTX(commentID int64, content string, lastEdited int64,
+	tx *gorp.Transaction) error {
+
+	_, err := tx.Exec(fmt.Sprintf("update %s set %s=$1, %s=$2 where %s=$3;",
+		cCommentTableName, cContent, cLastEdited, cID), content, lastEdited,
+		commentID)
+	return err
+}
+
+/* Keeps the comments on a removed option as comments on the poll. */
+func DetachCommentsFromOptionTX(optionID int64, tx *gorp.Transaction) error {
+	_, err := tx.Exec(fmt.Sprintf("update %s set %s=0 where %s=$1;",
+		cCommentTableName, cOptionID, cOptionID), optionID)
+	return err
+}
+
+/*
+ * Marks the poll as seen by the user up to the given sequence number, though
+ * never beyond the poll's current one and never back. Returns the sequence
+ * number the user has now seen the poll up to.
+ */
+func (db *Database) MarkPollSeen(userID, pollID int64, sequenceNumber int) (
+	int, error) {
+
+	lastSeen, err := db.mapping.SelectInt(fmt.Sprintf(
+		"update %s set %s=greatest(%s, least($3, (select %s from %s where "+
+			"%s=$2))) where %s=$1 and %s=$2 returning %s;",
+		cParticipantTableName, cLastSeen, cLastSeen, cSequenceNumber,
+		cPollTableName, cID, cUserID, cPollID, cLastSeen), userID, pollID,
+		sequenceNumber)
+	return int(lastSeen), err
+}
+
+/* Resets the badge count of the user, as of the given time. */
+func (db *Database) ResetBadge(userID, badgeReset int64) error {
+	_, err := db.mapping.Exec(fmt.Sprintf("update %s set %s=$1 where %s=$2;",
+		cUserTableName, cBadgeReset, cID), badgeReset, userID)
+	return err
+}
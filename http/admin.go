@@ -0,0 +1,495 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/roxot/polly"
+	"github.com/roxot/polly/database"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/lib/pq"
+	"gopkg.in/gorp.v1"
+)
+
+const (
+	cEditPollTag   = "PUT/POLL"
+	cClosePollTag  = "POST/CLOSE"
+	cReopenPollTag = "POST/REOPEN"
+)
+
+/*
+ * Type of the functions that are run in the transaction in which a poll is
+ * changed. Returns an API error code and the underlying error.
+ */
+type fPollTXHook func(tx *gorp.Transaction) (int, error)
+
+// PUT /v0.1/poll.json
+func (server *sServer) EditPoll(writer http.ResponseWriter,
+	request *http.Request, _ httprouter.Params) {
+
+	// authenticate the user
+	user, errCode := server.authenticateRequest(request)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cEditPollTag, writer, request)
+		return
+	}
+
+	// decode the edit message
+	var editMsg polly.EditPollMessage
+	decoder := json.NewDecoder(request.Body)
+	err := decoder.Decode(&editMsg)
+	if err != nil {
+		server.respondWithError(ERR_BAD_JSON, err, cEditPollTag, writer,
+			request)
+		return
+	}
+
+	// retrieve the poll and make sure the user may edit it
+	poll, question, errCode, err := server.getPollForAction(user,
+		editMsg.PollID, cPermissionEdit)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, err, cEditPollTag, writer, request)
+		return
+	}
+
+	// closed polls have to be reopened first
+	now := time.Now()
+	currentTime := now.UnixNano() / 1000000
+	if currentTime > poll.ClosingDate {
+		server.respondWithError(ERR_ILL_POLL_CLOSED, nil, cEditPollTag, writer,
+			request)
+		return
+	}
+
+	// validate the new title
+	title := question.Title
+	if editMsg.Title != nil {
+		title = strings.TrimSpace(*editMsg.Title)
+		if len(title) == 0 {
+			server.respondWithError(ERR_BAD_EMPTY_QUESTION, nil, cEditPollTag,
+				writer, request)
+			return
+		}
+	}
+
+	// make sure the removed options belong to the poll
+	options, err := server.db.GetOptionsByPollID(poll.ID)
+	if err != nil {
+		server.respondWithError(ERR_INT_DB_GET, err, cEditPollTag, writer,
+			request)
+		return
+	}
+
+	remainingOptions := make(map[int64]bool)
+	for _, option := range options {
+		remainingOptions[option.ID] = true
+	}
+
+	for _, optionID := range editMsg.RemovedOptionIDs {
+		if !remainingOptions[optionID] {
+			server.respondWithError(ERR_BAD_NO_OPTION, nil, cEditPollTag,
+				writer, request)
+			return
+		}
+
+		delete(remainingOptions, optionID)
+	}
+
+	// multiple choice polls can't lose all of their options
+	if len(remainingOptions) == 0 &&
+		(question.Type == polly.QUESTION_TYPE_MC ||
+			question.Type == polly.QUESTION_TYPE_MOVIE_MC ||
+			question.Type == polly.QUESTION_TYPE_DATE) {
+
+		server.respondWithError(ERR_BAD_EMPTY_POLL, nil, cEditPollTag, writer,
+			request)
+		return
+	}
+
+	// validate the new closing date
+	closingDate := poll.ClosingDate
+	if editMsg.ClosingDate != nil {
+		closingDate = *editMsg.ClosingDate
+		if !isValidClosingDate(closingDate, now) {
+			server.respondWithError(ERR_BAD_CLOSING_DATE, nil, cEditPollTag,
+				writer, request)
+			return
+		}
+	}
+
+	// validate the new results visibility
+	resultsVisibility := poll.ResultsVisibility
+	if editMsg.ResultsVisibility != nil {
+		resultsVisibility = *editMsg.ResultsVisibility
+		if !isValidResultsVisibility(resultsVisibility) {
+			server.respondWithError(ERR_BAD_RESULTS_VISIBILITY, nil,
+				cEditPollTag, writer, request)
+			return
+		}
+	}
+
+	// apply the changes, remembering the images of the removed options
+	var removedImageKeys []string
+	errCode, err = server.updatePollByUser(poll.ID,
+		polly.EVENT_TYPE_POLL_EDITED, user, title, cEditPollTag,
+		func(tx *gorp.Transaction) (int, error) {
+			err := database.UpdateQuestionTitleTX(poll.ID, title, tx)
+			if err != nil {
+				return ERR_INT_DB_UPDATE, err
+			}
+
+			removedImageKeys = nil
+			for _, optionID := range editMsg.RemovedOptionIDs {
+				var imageKey string
+				err = database.DeleteVotesForOptionTX(optionID, tx)
+				if err == nil {
+					imageKey, err = database.DeleteImageForOptionTX(optionID,
+						tx)
+				}
+				if err == nil {
+					err = database.DeleteReactionsForOptionTX(optionID, tx)
+				}
+				if err == nil {
+					err = database.DetachCommentsFromOptionTX(optionID, tx)
+				}
+				if err == nil {
+					err = database.DeleteOptionTX(optionID, tx)
+				}
+				if err != nil {
+					return ERR_INT_DB_DELETE, err
+				}
+
+				if len(imageKey) > 0 {
+					removedImageKeys = append(removedImageKeys, imageKey)
+				}
+			}
+
+			err = database.UpdateClosingDateTX(poll.ID, closingDate, tx)
+			if err == nil {
+				err = database.UpdateResultsVisibilityTX(poll.ID,
+					resultsVisibility, tx)
+			}
+			if err != nil {
+				return ERR_INT_DB_UPDATE, err
+			}
+
+			return NO_ERR, nil
+		})
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, err, cEditPollTag, writer, request)
+		return
+	}
+
+	// the images of the removed options aren't referenced anymore
+	for _, imageKey := range removedImageKeys {
+		server.deleteStoredImage(imageKey)
+	}
+
+	// the closing job carries the title, so replace it on either change
+	if title != question.Title || closingDate != poll.ClosingDate {
+		err = server.rescheduleClosePoll(poll.ID, title, closingDate)
+		if err != nil {
+			server.respondWithError(ERR_INT_CP_SCHEDULER, err, cEditPollTag,
+				writer, request)
+			return
+		}
+	}
+
+	// notify the poll participants
+	err = server.pushClient.NotifyForEditedPoll(&server.db, user, poll.ID,
+		title)
+	if err != nil {
+		// TODO neaten up
+		server.logger.Log(cEditPollTag, "Error notifying: "+err.Error(), "::1")
+	}
+
+	// respond with the edited poll
+	pollMsg, err := server.db.ConstructPollMessage(poll.ID, user.ID)
+	if err != nil {
+		server.respondWithError(ERR_INT_DB_GET, err, cEditPollTag, writer,
+			request)
+		return
+	}
+
+	server.respondWithPollMessage(pollMsg, cEditPollTag, writer, request)
+}
+
+// POST /v0.1/close.json
+func (server *sServer) ClosePollEarly(writer http.ResponseWriter,
+	request *http.Request, _ httprouter.Params) {
+
+	// authenticate the user
+	user, errCode := server.authenticateRequest(request)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cClosePollTag, writer, request)
+		return
+	}
+
+	// decode the close message
+	var closeMsg polly.ClosePollMessage
+	decoder := json.NewDecoder(request.Body)
+	err := decoder.Decode(&closeMsg)
+	if err != nil {
+		server.respondWithError(ERR_BAD_JSON, err, cClosePollTag, writer,
+			request)
+		return
+	}
+
+	// retrieve the poll and make sure the user may close it
+	poll, question, errCode, err := server.getPollForAction(user,
+		closeMsg.PollID, cPermissionClose)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, err, cClosePollTag, writer, request)
+		return
+	}
+
+	// make sure the poll hasn't closed yet
+	currentTime := time.Now().UnixNano() / 1000000
+	if currentTime > poll.ClosingDate {
+		server.respondWithError(ERR_ILL_POLL_CLOSED, nil, cClosePollTag,
+			writer, request)
+		return
+	}
+
+	// close the poll by moving its closing date to now
+	errCode, err = server.updatePollByUser(poll.ID,
+		polly.EVENT_TYPE_POLL_CLOSED, user, question.Title, cClosePollTag,
+		func(tx *gorp.Transaction) (int, error) {
+			err := database.UpdateClosingDateTX(poll.ID, currentTime, tx)
+			if err != nil {
+				return ERR_INT_DB_UPDATE, err
+			}
+
+			return closePollTX(poll.ID)(tx)
+		})
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, err, cClosePollTag, writer, request)
+		return
+	}
+
+	// cancel the scheduled closing and close the poll right away
+	err = server.rescheduleClosePoll(poll.ID, question.Title, currentTime)
+	if err != nil {
+		server.respondWithError(ERR_INT_CP_SCHEDULER, err, cClosePollTag,
+			writer, request)
+		return
+	}
+
+	// notify the participants of the closing
+	err = server.pushClient.NotifyForClosedEvent(&server.db, poll.ID,
+		question.Title)
+	if err != nil {
+		// TODO neaten up
+		server.logger.Log(cClosePollTag, "Error notifying: "+err.Error(), "::1")
+	}
+
+	server.respondWithSnapshot(poll.ID, cClosePollTag, writer, request)
+}
+
+// POST /v0.1/reopen.json
+func (server *sServer) ReopenPoll(writer http.ResponseWriter,
+	request *http.Request, _ httprouter.Params) {
+
+	// authenticate the user
+	user, errCode := server.authenticateRequest(request)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cReopenPollTag, writer, request)
+		return
+	}
+
+	// decode the reopen message
+	var reopenMsg polly.ReopenPollMessage
+	decoder := json.NewDecoder(request.Body)
+	err := decoder.Decode(&reopenMsg)
+	if err != nil {
+		server.respondWithError(ERR_BAD_JSON, err, cReopenPollTag, writer,
+			request)
+		return
+	}
+
+	// retrieve the poll and make sure the user may reopen it
+	poll, question, errCode, err := server.getPollForAction(user,
+		reopenMsg.PollID, cPermissionEdit)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, err, cReopenPollTag, writer, request)
+		return
+	}
+
+	// make sure the poll has closed
+	now := time.Now()
+	if now.UnixNano()/1000000 <= poll.ClosingDate {
+		server.respondWithError(ERR_ILL_POLL_NOT_CLOSED, nil, cReopenPollTag,
+			writer, request)
+		return
+	}
+
+	// validate the new closing date
+	if !isValidClosingDate(reopenMsg.ClosingDate, now) {
+		server.respondWithError(ERR_BAD_CLOSING_DATE, nil, cReopenPollTag,
+			writer, request)
+		return
+	}
+
+	// reopen the poll by moving its closing date to the future
+	errCode, err = server.updatePollByUser(poll.ID,
+		polly.EVENT_TYPE_POLL_REOPENED, user, question.Title, cReopenPollTag,
+		func(tx *gorp.Transaction) (int, error) {
+			err := database.UpdateClosingDateTX(poll.ID,
+				reopenMsg.ClosingDate, tx)
+			if err == nil {
+				err = database.UpdateClosedTX(poll.ID, false, tx)
+			}
+			if err == nil {
+				err = database.ClearWinnersTX(poll.ID, tx)
+			}
+			if err != nil {
+				return ERR_INT_DB_UPDATE, err
+			}
+
+			return NO_ERR, nil
+		})
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, err, cReopenPollTag, writer, request)
+		return
+	}
+
+	// schedule the new closing of the poll
+	err = server.rescheduleClosePoll(poll.ID, question.Title,
+		reopenMsg.ClosingDate)
+	if err != nil {
+		server.respondWithError(ERR_INT_CP_SCHEDULER, err, cReopenPollTag,
+			writer, request)
+		return
+	}
+
+	// notify the poll participants
+	err = server.pushClient.NotifyForReopenedPoll(&server.db, user, poll.ID,
+		question.Title)
+	if err != nil {
+		// TODO neaten up
+		server.logger.Log(cReopenPollTag, "Error notifying: "+err.Error(),
+			"::1")
+	}
+
+	server.respondWithSnapshot(poll.ID, cReopenPollTag, writer, request)
+}
+
+/*
+ * Retrieves the given poll and its question, making sure the role of the user
+ * grants the given permission. Returns an API error code and the underlying
+ * error.
+ */
+func (server *sServer) getPollForAction(user *polly.PrivateUser, pollID int64,
+	permission int) (*polly.Poll, *polly.Question, int, error) {
+
+	poll, err := server.db.GetPollByID(pollID)
+	if err != nil {
+		return nil, nil, ERR_BAD_NO_POLL, err
+	}
+
+	_, errCode := server.authorizePollAction(user.ID, pollID, permission)
+	if errCode != NO_ERR {
+		return nil, nil, errCode, nil
+	}
+
+	question, err := server.db.GetQuestionByPollID(pollID)
+	if err != nil {
+		return nil, nil, ERR_INT_DB_GET, err
+	}
+
+	return poll, question, NO_ERR, nil
+}
+
+/*
+ * Updates the last event and sequence number of the given poll and runs the
+ * optional hook in the same serializable transaction, retrying on
+ * serialization failures. Returns an API error code and the underlying error.
+ */
+func (server *sServer) updatePollByUser(pollID int64, eventType int,
+	user *polly.PrivateUser, pollTitle string, tag string,
+	hook fPollTXHook) (int, error) {
+
+	return server.updatePollByActor(pollID, eventType, user.ID, user,
+		pollTitle, tag, hook)
+}
+
+/*
+ * Like updatePollByUser, for events recorded under another user than the
+ * actor causing them, such as the anonymized ones.
+ */
+func (server *sServer) updatePollByActor(pollID int64, eventType int,
+	actorID int64, eventUser *polly.PrivateUser, pollTitle string, tag string,
+	hook fPollTXHook) (int, error) {
+
+	currentTime := time.Now().UnixNano() / 1000000
+	transactionNumber := rand.Int()
+	for {
+
+		// start a transaction
+		tx, err := server.db.Begin()
+		if err != nil {
+			tx.Rollback()
+			return ERR_INT_DB_TX_BEGIN, err
+		}
+
+		// set the transaction isolation level
+		_, err = tx.Exec("set transaction isolation level serializable;")
+		if err != nil {
+			tx.Rollback()
+			return ERR_INT_DB_TX_SET_TX_LEVEL, err
+		}
+
+		// update the poll last updated and seq number
+		errCode := NO_ERR
+		err = database.UpdatePollTX(pollID, currentTime, eventType,
+			eventUser.DisplayName, eventUser.ID, pollTitle, actorID, tx)
+		if err != nil {
+			errCode = ERR_INT_DB_UPDATE
+		} else if hook != nil {
+			errCode, err = hook(tx)
+		}
+		if err != nil || errCode != NO_ERR {
+			tx.Rollback()
+			if pqErr, ok := err.(*pq.Error); ok &&
+				pqErr.Code == database.ERR_SERIALIZATION_FAILURE {
+				server.logger.Log(tag, fmt.Sprintf("%d: %s",
+					transactionNumber, "Serialization failure, retrying..."),
+					"::1")
+				continue
+			}
+
+			return errCode, err
+		}
+
+		// commit the transaction
+		err = tx.Commit()
+		if err != nil {
+			tx.Rollback()
+			return ERR_INT_DB_TX_COMMIT, err
+		}
+
+		return NO_ERR, nil
+	}
+}
+
+func (server *sServer) respondWithPollMessage(pollMsg *polly.PollMessage,
+	tag string, writer http.ResponseWriter, request *http.Request) {
+
+	// marshall the response
+	responseBody, err := json.MarshalIndent(pollMsg, "", "\t")
+	if err != nil {
+		server.respondWithError(ERR_INT_MARSHALL, err, tag, writer, request)
+		return
+	}
+
+	// send the response
+	err = server.respondWithJSONBody(writer, responseBody)
+	if err != nil {
+		server.respondWithError(ERR_INT_WRITE, err, tag, writer, request)
+	}
+}
@@ -28,6 +28,11 @@ func (server *sServer) authenticateRequest(request *http.Request) (
 		return nil, ERR_AUT_BAD_TOKEN
 	}
 
+	// guests can only use the web pages of their poll
+	if user.GuestPollID != 0 {
+		return nil, ERR_AUT_GUEST
+	}
+
 	return user, NO_ERR
 }
 
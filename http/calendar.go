@@ -0,0 +1,328 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/roxot/polly"
+
+	"github.com/dchest/uniuri"
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	cPollCalendarTag        = "GET/POLL.ICS"
+	cPostCalendarTokenTag   = "POST/CALENDAR"
+	cCalendarFeedTag        = "GET/CALENDAR"
+	cCalendarContentType    = "text/calendar; charset=utf-8"
+	cCalendarExtension      = ".ics"
+	cCalendarTokenLength    = 32
+	cCalendarDateTimeFormat = "20060102T150405Z"
+	cCalendarDateFormat     = "20060102"
+	cCalendarLineLength     = 75
+	cCalendarProductID      = "-//Polly//Polly//EN"
+	cCalendarUIDFormat      = "poll-%d@getpollyapp.com"
+	cCalendarAttendeeFormat = "urn:polly:user:%d"
+)
+
+/* The event a decided date poll results in. */
+type sCalendarEvent struct {
+	UID       string
+	Summary   string
+	Stamp     time.Time
+	Start     time.Time
+	Duration  time.Duration
+	Attendees []polly.PublicUser
+}
+
+// GET /v0.1/poll/:id.ics
+func (server *sServer) PollCalendar(writer http.ResponseWriter,
+	request *http.Request, params httprouter.Params) {
+
+	// authenticate the user
+	user, errCode := server.authenticateRequest(request)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cPollCalendarTag, writer,
+			request)
+		return
+	}
+
+	// parse the poll id from the file name
+	pollID, err := strconv.ParseInt(strings.TrimSuffix(params.ByName(cID),
+		cCalendarExtension), 10, 64)
+	if err != nil {
+		server.respondWithError(ERR_BAD_ID, err, cPollCalendarTag, writer,
+			request)
+		return
+	}
+
+	// make sure the user participates in the poll
+	_, errCode = server.authorizePollAction(user.ID, pollID, cPermissionView)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cPollCalendarTag, writer,
+			request)
+		return
+	}
+
+	// parse the time zone that all day events fall in
+	location, err := calendarLocation(request)
+	if err != nil {
+		server.respondWithError(ERR_BAD_TIME_ZONE, err, cPollCalendarTag,
+			writer, request)
+		return
+	}
+
+	// construct the event from the winning option
+	event, errCode, err := server.pollCalendarEvent(pollID)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, err, cPollCalendarTag, writer,
+			request)
+		return
+	}
+
+	server.respondWithCalendar([]sCalendarEvent{*event}, location,
+		cPollCalendarTag, writer, request)
+}
+
+// POST /v0.1/calendar.json
+func (server *sServer) PostCalendarToken(writer http.ResponseWriter,
+	request *http.Request, _ httprouter.Params) {
+
+	// authenticate the user
+	user, errCode := server.authenticateRequest(request)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cPostCalendarTokenTag, writer,
+			request)
+		return
+	}
+
+	// replace the feed token, which revokes the previous one
+	tokenMsg := polly.CalendarTokenMessage{}
+	tokenMsg.Token = uniuri.NewLen(cCalendarTokenLength)
+	err := server.db.UpdateCalendarToken(user.ID, tokenMsg.Token)
+	if err != nil {
+		server.respondWithError(ERR_INT_DB_UPDATE, err, cPostCalendarTokenTag,
+			writer, request)
+		return
+	}
+
+	// marshall the response
+	responseBody, err := json.MarshalIndent(tokenMsg, "", "\t")
+	if err != nil {
+		server.respondWithError(ERR_INT_MARSHALL, err, cPostCalendarTokenTag,
+			writer, request)
+		return
+	}
+
+	// send the response
+	err = server.respondWithJSONBody(writer, responseBody)
+	if err != nil {
+		server.respondWithError(ERR_INT_WRITE, err, cPostCalendarTokenTag,
+			writer, request)
+	}
+}
+
+/*
+ * Serves the decided date polls of a user as a calendar feed. Calendar apps
+ * can't send our credentials, so the secret token in the URL authenticates
+ * the request.
+ */
+// GET /calendar/:token.ics
+func (server *sServer) CalendarFeed(writer http.ResponseWriter,
+	request *http.Request, params httprouter.Params) {
+
+	// authenticate the user by the feed token
+	token := strings.TrimSuffix(params.ByName(cTokenParam), cCalendarExtension)
+	if len(token) == 0 {
+		server.respondWithError(ERR_AUT_BAD_CALENDAR_TOKEN, nil,
+			cCalendarFeedTag, writer, request)
+		return
+	}
+
+	user, err := server.db.GetUserByCalendarToken(token)
+	if err != nil {
+		server.respondWithError(ERR_AUT_BAD_CALENDAR_TOKEN, err,
+			cCalendarFeedTag, writer, request)
+		return
+	}
+
+	// parse the time zone that all day events fall in
+	location, err := calendarLocation(request)
+	if err != nil {
+		server.respondWithError(ERR_BAD_TIME_ZONE, err, cCalendarFeedTag,
+			writer, request)
+		return
+	}
+
+	// retrieve the closed date polls of the user
+	pollIDs, err := server.db.GetClosedPollIDsByUserID(user.ID,
+		polly.QUESTION_TYPE_DATE)
+	if err != nil {
+		server.respondWithError(ERR_INT_DB_GET, err, cCalendarFeedTag, writer,
+			request)
+		return
+	}
+
+	// polls that ended in a tie or without votes are left out
+	events := make([]sCalendarEvent, 0, len(pollIDs))
+	for _, pollID := range pollIDs {
+		event, errCode, err := server.pollCalendarEvent(pollID)
+		if errCode == ERR_ILL_POLL_UNDECIDED {
+			continue
+		} else if errCode != NO_ERR {
+			server.respondWithError(errCode, err, cCalendarFeedTag, writer,
+				request)
+			return
+		}
+
+		events = append(events, *event)
+	}
+
+	server.respondWithCalendar(events, location, cCalendarFeedTag, writer,
+		request)
+}
+
+/*
+ * Constructs the event of a closed date poll from its winning option. Polls
+ * without a single winner are undecided. Returns the event and an API error
+ * code with the underlying error.
+ */
+func (server *sServer) pollCalendarEvent(pollID int64) (*sCalendarEvent,
+	int, error) {
+
+	poll, err := server.db.GetPollByID(pollID)
+	if err != nil {
+		return nil, ERR_BAD_NO_POLL, err
+	}
+
+	question, err := server.db.GetQuestionByPollID(pollID)
+	if err != nil {
+		return nil, ERR_INT_DB_GET, err
+	} else if question.Type != polly.QUESTION_TYPE_DATE {
+		return nil, ERR_BAD_NOT_DATE_POLL, nil
+	} else if !poll.Closed {
+		return nil, ERR_ILL_POLL_NOT_CLOSED, nil
+	}
+
+	winners, err := server.db.GetWinnersByPollID(pollID)
+	if err != nil {
+		return nil, ERR_INT_DB_GET, err
+	} else if len(winners) != 1 {
+		return nil, ERR_ILL_POLL_UNDECIDED, nil
+	}
+
+	pollMsg, err := server.db.ConstructPollMessage(pollID, 0)
+	if err != nil {
+		return nil, ERR_INT_DB_GET, err
+	}
+
+	event := sCalendarEvent{}
+	event.UID = fmt.Sprintf(cCalendarUIDFormat, pollID)
+	event.Summary = question.Title
+	event.Stamp = time.Unix(0, poll.ClosingDate*1000000)
+	event.Start = time.Unix(0, winners[0].StartDate*1000000)
+	event.Duration = time.Duration(winners[0].Duration) * time.Millisecond
+	event.Attendees = pollMsg.Participants
+	return &event, NO_ERR, nil
+}
+
+/*
+ * Returns the time zone of the optional time zone parameter of the request,
+ * UTC if it's left out.
+ */
+func calendarLocation(request *http.Request) (*time.Location, error) {
+	timeZone := request.URL.Query().Get(cTimeZone)
+	if len(timeZone) == 0 {
+		return time.UTC, nil
+	}
+
+	return time.LoadLocation(timeZone)
+}
+
+/*
+ * Responds with an RFC 5545 calendar holding the given events. Events without
+ * a duration last all day, on the day of their start in the given time zone.
+ * Those are floating dates, so calendar apps show them on that day wherever
+ * they are.
+ */
+func (server *sServer) respondWithCalendar(events []sCalendarEvent,
+	location *time.Location, tag string, writer http.ResponseWriter,
+	request *http.Request) {
+
+	var buffer bytes.Buffer
+	writeCalendarLine(&buffer, "BEGIN:VCALENDAR")
+	writeCalendarLine(&buffer, "VERSION:2.0")
+	writeCalendarLine(&buffer, "PRODID:"+cCalendarProductID)
+	writeCalendarLine(&buffer, "CALSCALE:GREGORIAN")
+	writeCalendarLine(&buffer, "METHOD:PUBLISH")
+
+	for _, event := range events {
+		writeCalendarLine(&buffer, "BEGIN:VEVENT")
+		writeCalendarLine(&buffer, "UID:"+event.UID)
+		writeCalendarLine(&buffer, "DTSTAMP:"+
+			event.Stamp.UTC().Format(cCalendarDateTimeFormat))
+
+		start := event.Start.UTC()
+		if event.Duration == 0 {
+			day := event.Start.In(location)
+			writeCalendarLine(&buffer, "DTSTART;VALUE=DATE:"+
+				day.Format(cCalendarDateFormat))
+			writeCalendarLine(&buffer, "DTEND;VALUE=DATE:"+
+				day.AddDate(0, 0, 1).Format(cCalendarDateFormat))
+		} else {
+			writeCalendarLine(&buffer, "DTSTART:"+
+				start.Format(cCalendarDateTimeFormat))
+			writeCalendarLine(&buffer, "DTEND:"+
+				start.Add(event.Duration).Format(cCalendarDateTimeFormat))
+		}
+
+		writeCalendarLine(&buffer, "SUMMARY:"+escapeCalendarText(event.Summary))
+		for _, attendee := range event.Attendees {
+			writeCalendarLine(&buffer, fmt.Sprintf("ATTENDEE;CN=\"%s\":"+
+				cCalendarAttendeeFormat, escapeCalendarParam(
+				attendee.DisplayName), attendee.ID))
+		}
+
+		writeCalendarLine(&buffer, "END:VEVENT")
+	}
+
+	writeCalendarLine(&buffer, "END:VCALENDAR")
+
+	writer.Header().Set("Content-Type", cCalendarContentType)
+	_, err := writer.Write(buffer.Bytes())
+	if err != nil {
+		server.respondWithError(ERR_INT_WRITE, err, tag, writer, request)
+	}
+}
+
+/* Writes a content line, folded into lines of at most 75 octets. */
+func writeCalendarLine(buffer *bytes.Buffer, line string) {
+	lineLength := 0
+	for _, char := range line {
+		charLength := len(string(char))
+		if lineLength+charLength > cCalendarLineLength {
+			buffer.WriteString("\r\n ")
+			lineLength = 1
+		}
+
+		buffer.WriteRune(char)
+		lineLength += charLength
+	}
+
+	buffer.WriteString("\r\n")
+}
+
+func escapeCalendarText(text string) string {
+	return strings.NewReplacer("\\", "\\\\", ";", "\\;", ",", "\\,",
+		"\r\n", "\\n", "\n", "\\n").Replace(text)
+}
+
+/* Quoted parameter values can't contain quotes or line breaks at all. */
+func escapeCalendarParam(value string) string {
+	return strings.NewReplacer("\"", "'", "\r", " ", "\n", " ").Replace(value)
+}
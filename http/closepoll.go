@@ -1,5 +1,15 @@
 package http
 
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/roxot/polly"
+	"github.com/roxot/polly/database"
+
+	"gopkg.in/gorp.v1"
+)
+
 const (
 	cClosedPollEvent = "closed_poll_event"
 )
@@ -10,16 +20,155 @@ type tPollToClose struct { // TODO should this be here?
 	Title string
 }
 
+/*
+ * Closes the poll and notifies its participants. Polls that have been closed
+ * already, by an earlier run of the job or another server, are left alone.
+ * Jobs for a closing date that moved to the future are ignored. Notifying is
+ * retried by a job of its own, since a retried closing job would find the
+ * poll closed.
+ */
 func (server *sServer) ClosePoll(poll *tPollToClose) error {
+	dbPoll, err := server.db.GetPollByID(poll.ID)
+	if err != nil {
+		return err
+	} else if dbPoll.ClosingDate > time.Now().UnixNano()/1000000 {
+		return nil
+	}
+
+	// store the closed state and the winners
+	errCode, err := server.updatePollByUser(poll.ID,
+		polly.EVENT_TYPE_POLL_CLOSED, &polly.PrivateUser{}, poll.Title,
+		cClosedPollEvent, closePollTX(poll.ID))
+	if errCode == ERR_ILL_POLL_CLOSED {
+		return nil
+	} else if errCode != NO_ERR {
+		return err
+	}
 
 	// send a notification to other participants
-	err := server.pushClient.NotifyForClosedEvent(&server.db, poll.ID,
+	err = server.pushClient.NotifyForClosedEvent(&server.db, poll.ID,
 		poll.Title)
 	if err != nil {
-		// TODO neaten up
 		server.logger.Log(cClosedPollEvent, "Error notifying: "+err.Error(),
 			"::1")
+		_, err = server.scheduler.Schedule(cClosedPushJobs, time.Now(),
+			poll)
+	}
+
+	return err
+}
+
+/*
+ * Returns the transaction hook that marks the poll closed with its winners.
+ * The hook fails on polls that are closed already, so the closing event is
+ * rolled back along with it.
+ */
+func closePollTX(pollID int64) fPollTXHook {
+	return func(tx *gorp.Transaction) (int, error) {
+		closed, err := database.ClosePollTX(pollID, tx)
+		if err == nil && !closed {
+			return ERR_ILL_POLL_CLOSED, nil
+		} else if err == nil {
+			err = database.UpdateWinnersTX(pollID, tx)
+		}
+		if err != nil {
+			return ERR_INT_DB_UPDATE, err
+		}
+
+		return NO_ERR, nil
+	}
+}
+
+/* Decodes the poll of a closing job. */
+func (server *sServer) closePollJob(data []byte) error {
+	var poll tPollToClose
+	err := json.Unmarshal(data, &poll)
+	if err != nil {
+		return err
+	}
+
+	return server.ClosePoll(&poll)
+}
+
+/* Notifies the participants of a closed poll again after a failed push. */
+func (server *sServer) notifyClosedPollJob(data []byte) error {
+	var poll tPollToClose
+	err := json.Unmarshal(data, &poll)
+	if err != nil {
+		return err
+	}
+
+	return server.pushClient.NotifyForClosedEvent(&server.db, poll.ID,
+		poll.Title)
+}
+
+/*
+ * Schedules the closing of the given poll at the given closing date and
+ * remembers the job so it can be rescheduled or cancelled later on.
+ */
+func (server *sServer) scheduleClosePoll(pollID int64, title string,
+	closingDate int64) error {
+
+	pollToClose := tPollToClose{pollID, title}
+	jobID, err := server.scheduler.Schedule(cClosedPollsJobs, time.Unix(0,
+		1000000*closingDate), &pollToClose)
+	if err != nil {
+		return err
+	}
+
+	return server.db.UpdateCloseJobID(pollID, jobID)
+}
+
+/* Schedules the closing of a poll within the transaction that inserts it. */
+func (server *sServer) scheduleClosePollTX(pollID int64, title string,
+	closingDate int64, tx *gorp.Transaction) error {
+
+	pollToClose := tPollToClose{pollID, title}
+	jobID, err := server.scheduler.ScheduleTX(cClosedPollsJobs, time.Unix(0,
+		1000000*closingDate), &pollToClose, tx)
+	if err != nil {
+		return err
+	}
+
+	return database.UpdateCloseJobIDTX(pollID, jobID, tx)
+}
+
+/*
+ * Cancels the scheduled closing of the given poll. Jobs that already ran are
+ * gone, so those are ignored.
+ */
+func (server *sServer) cancelClosePoll(pollID int64) error {
+	poll, err := server.db.GetPollByID(pollID)
+	if err != nil {
+		return err
+	} else if len(poll.CloseJobID) == 0 {
+		return nil
+	}
+
+	return server.scheduler.Cancel(poll.CloseJobID)
+}
+
+/*
+ * Replaces the scheduled closing of the given poll and its reminders. The
+ * title is part of the job, so this is also needed when only the title
+ * changed. No new job is scheduled for polls that are closed by then.
+ */
+func (server *sServer) rescheduleClosePoll(pollID int64, title string,
+	closingDate int64) error {
+
+	err := server.rescheduleReminders(pollID, closingDate)
+	if err != nil {
+		return err
+	}
+
+	err = server.cancelClosePoll(pollID)
+	if err != nil {
+		return err
+	}
+
+	if closingDate <= time.Now().UnixNano()/1000000 {
+		return server.db.UpdateCloseJobID(pollID, "")
 	}
 
-	return nil
+	return server.scheduleClosePoll(pollID, title, closingDate)
 }
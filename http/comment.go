@@ -0,0 +1,405 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/roxot/polly"
+	"github.com/roxot/polly/database"
+
+	"github.com/julienschmidt/httprouter"
+	"gopkg.in/gorp.v1"
+)
+
+const (
+	cPostCommentTag   = "POST/COMMENT"
+	cEditCommentTag   = "PUT/COMMENT"
+	cDeleteCommentTag = "DELETE/COMMENT"
+	cGetCommentsTag   = "GET/COMMENTS"
+)
+
+// POST /v0.1/comment.json
+func (server *sServer) PostComment(writer http.ResponseWriter,
+	request *http.Request, _ httprouter.Params) {
+
+	// authenticate the user
+	user, errCode := server.authenticateRequest(request)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cPostCommentTag, writer, request)
+		return
+	}
+
+	// decode the comment message
+	var commentMsg polly.CommentMessage
+	decoder := json.NewDecoder(request.Body)
+	err := decoder.Decode(&commentMsg)
+	if err != nil {
+		server.respondWithError(ERR_BAD_JSON, err, cPostCommentTag, writer,
+			request)
+		return
+	}
+
+	// make sure the user may comment on the poll
+	_, _, errCode, err = server.getPollForAction(user, commentMsg.PollID,
+		cPermissionComment)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, err, cPostCommentTag, writer, request)
+		return
+	}
+
+	// validate the comment
+	content, errCode := validCommentContent(commentMsg.Content)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cPostCommentTag, writer, request)
+		return
+	}
+
+	// comments can only be about options of the same poll
+	if commentMsg.OptionID != 0 {
+		option, err := server.db.GetOptionByID(commentMsg.OptionID)
+		if err != nil || option.PollID != commentMsg.PollID {
+			server.respondWithError(ERR_BAD_NO_OPTION, err, cPostCommentTag,
+				writer, request)
+			return
+		}
+	}
+
+	// insert the comment along with bumping the poll
+	comment := polly.Comment{}
+	comment.PollID = commentMsg.PollID
+	comment.OptionID = commentMsg.OptionID
+	comment.UserID = user.ID
+	comment.Content = content
+	comment.CreationDate = time.Now().UnixNano() / 1000000
+
+	var snapshot *polly.PollSnapshot
+	errCode, err = server.updatePollByUser(comment.PollID,
+		polly.EVENT_TYPE_NEW_COMMENT, user, eventTitle(content),
+		cPostCommentTag, func(tx *gorp.Transaction) (int, error) {
+			comment.ID = 0
+			err := database.AddCommentTX(&comment, tx)
+			if err != nil {
+				return ERR_INT_DB_ADD, err
+			}
+
+			snapshot, err = database.GetPollSnapshotTX(comment.PollID, tx)
+			if err != nil {
+				return ERR_INT_DB_GET, err
+			}
+
+			return NO_ERR, nil
+		})
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, err, cPostCommentTag, writer, request)
+		return
+	}
+
+	// notify the other participants
+	err = server.pushClient.NotifyForComment(&server.db, user, comment.PollID,
+		eventTitle(content))
+	if err != nil {
+		// TODO neaten up
+		server.logger.Log(cPostCommentTag, "Error notifying: "+err.Error(),
+			"::1")
+	}
+
+	server.respondWithComment(&comment, snapshot, cPostCommentTag, writer,
+		request)
+}
+
+// PUT /v0.1/comment.json
+func (server *sServer) EditComment(writer http.ResponseWriter,
+	request *http.Request, _ httprouter.Params) {
+
+	// authenticate the user
+	user, errCode := server.authenticateRequest(request)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cEditCommentTag, writer, request)
+		return
+	}
+
+	// decode the comment message
+	var commentMsg polly.CommentMessage
+	decoder := json.NewDecoder(request.Body)
+	err := decoder.Decode(&commentMsg)
+	if err != nil {
+		server.respondWithError(ERR_BAD_JSON, err, cEditCommentTag, writer,
+			request)
+		return
+	}
+
+	// only the author can edit a comment
+	comment, err := server.db.GetCommentByID(commentMsg.ID)
+	if err != nil {
+		server.respondWithError(ERR_BAD_NO_COMMENT, err, cEditCommentTag,
+			writer, request)
+		return
+	} else if comment.UserID != user.ID {
+		server.respondWithError(ERR_ILL_COMMENT_ACCESS, nil, cEditCommentTag,
+			writer, request)
+		return
+	}
+
+	// the author may have lost the right to comment in the meantime
+	_, _, errCode, err = server.getPollForAction(user, comment.PollID,
+		cPermissionComment)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, err, cEditCommentTag, writer, request)
+		return
+	}
+
+	// validate the new content
+	content, errCode := validCommentContent(commentMsg.Content)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cEditCommentTag, writer, request)
+		return
+	}
+
+	// update the comment along with bumping the poll
+	comment.Content = content
+	comment.LastEdited = time.Now().UnixNano() / 1000000
+
+	var snapshot *polly.PollSnapshot
+	errCode, err = server.updatePollByUser(comment.PollID,
+		polly.EVENT_TYPE_COMMENT_EDITED, user, eventTitle(content),
+		cEditCommentTag, func(tx *gorp.Transaction) (int, error) {
+			err := database.UpdateCommentTX(comment.ID, comment.Content,
+				comment.LastEdited, tx)
+			if err != nil {
+				return ERR_INT_DB_UPDATE, err
+			}
+
+			snapshot, err = database.GetPollSnapshotTX(comment.PollID, tx)
+			if err != nil {
+				return ERR_INT_DB_GET, err
+			}
+
+			return NO_ERR, nil
+		})
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, err, cEditCommentTag, writer, request)
+		return
+	}
+
+	server.respondWithComment(comment, snapshot, cEditCommentTag, writer,
+		request)
+}
+
+/* Comments can be deleted by their author and by the poll's owner. */
+// DELETE /v0.1/comment.json?id=
+func (server *sServer) DeleteComment(writer http.ResponseWriter,
+	request *http.Request, _ httprouter.Params) {
+
+	// authenticate the user
+	user, errCode := server.authenticateRequest(request)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cDeleteCommentTag, writer,
+			request)
+		return
+	}
+
+	// convert the id to an integer
+	ids := request.URL.Query()[cID]
+	if len(ids) == 0 {
+		server.respondWithError(ERR_BAD_NO_ID, nil, cDeleteCommentTag, writer,
+			request)
+		return
+	}
+
+	// parse the provided comment id to an integer
+	commentID, err := strconv.ParseInt(ids[0], 10, 64)
+	if err != nil {
+		server.respondWithError(ERR_BAD_ID, err, cDeleteCommentTag, writer,
+			request)
+		return
+	}
+
+	// retrieve the comment
+	comment, err := server.db.GetCommentByID(commentID)
+	if err != nil {
+		server.respondWithError(ERR_BAD_NO_COMMENT, err, cDeleteCommentTag,
+			writer, request)
+		return
+	}
+
+	// make sure the user wrote the comment or moderates the poll
+	permission := cPermissionModerateComments
+	if comment.UserID == user.ID {
+		permission = cPermissionView
+	}
+
+	_, errCode = server.authorizePollAction(user.ID, comment.PollID,
+		permission)
+	if errCode == ERR_ILL_ROLE {
+		errCode = ERR_ILL_COMMENT_ACCESS
+	}
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cDeleteCommentTag, writer,
+			request)
+		return
+	}
+
+	// delete the comment along with bumping the poll
+	var snapshot *polly.PollSnapshot
+	errCode, err = server.updatePollByUser(comment.PollID,
+		polly.EVENT_TYPE_COMMENT_DELETED, user, eventTitle(comment.Content),
+		cDeleteCommentTag, func(tx *gorp.Transaction) (int, error) {
+			err := database.DeleteReactionsForCommentTX(comment.ID, tx)
+			if err == nil {
+				err = database.DeleteCommentTX(comment.ID, tx)
+			}
+			if err != nil {
+				return ERR_INT_DB_DELETE, err
+			}
+
+			snapshot, err = database.GetPollSnapshotTX(comment.PollID, tx)
+			if err != nil {
+				return ERR_INT_DB_GET, err
+			}
+
+			return NO_ERR, nil
+		})
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, err, cDeleteCommentTag, writer,
+			request)
+		return
+	}
+
+	server.respondWithComment(nil, snapshot, cDeleteCommentTag, writer,
+		request)
+}
+
+// GET /v0.1/comments.json?poll_id=&page=
+func (server *sServer) GetComments(writer http.ResponseWriter,
+	request *http.Request, _ httprouter.Params) {
+
+	// authenticate the user
+	user, errCode := server.authenticateRequest(request)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cGetCommentsTag, writer, request)
+		return
+	}
+
+	// retrieve the poll id
+	pollIDs := request.URL.Query()[cPollID]
+	if len(pollIDs) == 0 {
+		server.respondWithError(ERR_BAD_NO_ID, nil, cGetCommentsTag, writer,
+			request)
+		return
+	}
+
+	pollID, err := strconv.ParseInt(pollIDs[0], 10, 64)
+	if err != nil {
+		server.respondWithError(ERR_BAD_ID, err, cGetCommentsTag, writer,
+			request)
+		return
+	}
+
+	// retrieve the page argument
+	page := 1
+	if pageStrings := request.URL.Query()[cPage]; len(pageStrings) > 0 {
+		page, err = strconv.Atoi(pageStrings[0])
+		if err != nil || page < 1 {
+			server.respondWithError(ERR_BAD_PAGE, err, cGetCommentsTag, writer,
+				request)
+			return
+		}
+	}
+
+	// make sure the user participates in the poll
+	_, errCode = server.authorizePollAction(user.ID, pollID, cPermissionView)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cGetCommentsTag, writer, request)
+		return
+	}
+
+	// retrieve the comments
+	comments, err := server.db.GetCommentsByPollID(pollID, cCommentListMax,
+		(page-1)*cCommentListMax)
+	if err != nil {
+		server.respondWithError(ERR_INT_DB_GET, err, cGetCommentsTag, writer,
+			request)
+		return
+	}
+
+	total, err := server.db.CountCommentsForPoll(pollID)
+	if err != nil {
+		server.respondWithError(ERR_INT_DB_GET, err, cGetCommentsTag, writer,
+			request)
+		return
+	}
+
+	// construct the CommentList object
+	commentListMsg := polly.CommentListMessage{}
+	commentListMsg.Comments = comments
+	if commentListMsg.Comments == nil {
+		commentListMsg.Comments = []polly.Comment{}
+	}
+
+	commentListMsg.Page = page
+	commentListMsg.PageSize = cCommentListMax
+	commentListMsg.NumResults = len(comments)
+	commentListMsg.Total = total
+
+	// marshall the response
+	responseBody, err := json.MarshalIndent(commentListMsg, "", "\t")
+	if err != nil {
+		server.respondWithError(ERR_INT_MARSHALL, err, cGetCommentsTag, writer,
+			request)
+		return
+	}
+
+	// send the response
+	err = server.respondWithJSONBody(writer, responseBody)
+	if err != nil {
+		server.respondWithError(ERR_INT_WRITE, err, cGetCommentsTag, writer,
+			request)
+	}
+}
+
+func (server *sServer) respondWithComment(comment *polly.Comment,
+	snapshot *polly.PollSnapshot, tag string, writer http.ResponseWriter,
+	request *http.Request) {
+
+	// construct the response message
+	response := polly.CommentResponseMessage{}
+	response.Comment = comment
+	response.Poll = *snapshot
+
+	// marshall the response
+	responseBody, err := json.MarshalIndent(response, "", "\t")
+	if err != nil {
+		server.respondWithError(ERR_INT_MARSHALL, err, tag, writer, request)
+		return
+	}
+
+	// send the response
+	err = server.respondWithJSONBody(writer, responseBody)
+	if err != nil {
+		server.respondWithError(ERR_INT_WRITE, err, tag, writer, request)
+	}
+}
+
+/* Trims the content of a comment and makes sure it isn't empty or too long. */
+func validCommentContent(content string) (string, int) {
+	content = strings.TrimSpace(content)
+	if len(content) == 0 || utf8.RuneCountInString(content) > cMaxCommentLen {
+		return "", ERR_BAD_COMMENT
+	}
+
+	return content, NO_ERR
+}
+
+/* Shortens free text to fit the last event title and push notifications. */
+func eventTitle(text string) string {
+	runes := []rune(text)
+	if len(runes) <= cEventTitleLen {
+		return text
+	}
+
+	return string(runes[:cEventTitleLen-1]) + "…"
+}
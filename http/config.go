@@ -12,6 +12,16 @@ type Config struct {
 	TruncateDB            bool
 	Port                  string
 	ClosedPollPushRetries uint
+	InviteSecret          string
+	FacebookAppID         string
+	ReminderOffsets       []string
+	Scheduler             string
+	MovieProvider         string
+	MovieAPIURL           string
+	MovieAPIKey           string
+	BlobStore             string
+	BlobDir               string
+	PublicURL             string
 }
 
 func ConfigFromFile(filename string) (*Config, error) {
@@ -6,4 +6,21 @@ const (
 	cDeviceGUID  = "device_guid"
 	cDisplayName = "display_name"
 	cPage        = "page"
+	cPollID      = "poll_id"
+	cUserID      = "user_id"
+	cQuery       = "query"
+	cNear        = "near"
+	cOptionID    = "option_id"
+	cCommentID   = "comment_id"
+	cEmoji       = "emoji"
+	cPageSize    = "page_size"
+	cStatus      = "status"
+	cCreatedByMe = "created_by_me"
+	cNotVoted    = "not_voted"
+	cClosingSoon = "closing_soon"
+	cSort        = "sort"
+	cCursor      = "cursor"
+	cSearchQuery = "q"
+	cUnread      = "unread"
+	cTimeZone    = "time_zone"
 )
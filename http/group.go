@@ -0,0 +1,295 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/roxot/polly"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	cPostGroupTag   = "POST/GROUP"
+	cGetGroupsTag   = "GET/GROUPS"
+	cUpdateGroupTag = "PUT/GROUP"
+	cDeleteGroupTag = "DELETE/GROUP"
+)
+
+func (server *sServer) PostGroup(writer http.ResponseWriter,
+	request *http.Request, _ httprouter.Params) {
+
+	// authenticate the user
+	user, errCode := server.authenticateRequest(request)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cPostGroupTag, writer, request)
+		return
+	}
+
+	// decode the group
+	var groupMsg polly.GroupMessage
+	decoder := json.NewDecoder(request.Body)
+	err := decoder.Decode(&groupMsg)
+	if err != nil {
+		server.respondWithError(ERR_BAD_JSON, err, cPostGroupTag, writer,
+			request)
+		return
+	}
+
+	// validate the group
+	errCode = isValidGroupMessage(&server.db, &groupMsg, user.ID)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cPostGroupTag, writer, request)
+		return
+	}
+
+	// insert the group
+	err = server.db.InsertGroupMessage(&groupMsg)
+	if err != nil {
+		server.respondWithError(ERR_INT_DB_ADD, err, cPostGroupTag, writer,
+			request)
+		return
+	}
+
+	// marshall the response
+	responseBody, err := json.MarshalIndent(groupMsg, "", "\t")
+	if err != nil {
+		server.respondWithError(ERR_INT_MARSHALL, err, cPostGroupTag, writer,
+			request)
+		return
+	}
+
+	// send the response
+	err = server.respondWithJSONBody(writer, responseBody)
+	if err != nil {
+		server.respondWithError(ERR_INT_WRITE, err, cPostGroupTag, writer,
+			request)
+	}
+}
+
+func (server *sServer) GetGroups(writer http.ResponseWriter,
+	request *http.Request, _ httprouter.Params) {
+
+	// authenticate the user
+	user, errCode := server.authenticateRequest(request)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cGetGroupsTag, writer, request)
+		return
+	}
+
+	// retrieve the groups owned by the user
+	groups, err := server.db.GetGroupsByOwnerID(user.ID)
+	if err != nil {
+		server.respondWithError(ERR_INT_DB_GET, err, cGetGroupsTag, writer,
+			request)
+		return
+	}
+
+	// construct the GroupList object
+	groupListMsg := polly.GroupListMessage{}
+	groupListMsg.Groups = make([]polly.GroupMessage, len(groups))
+	for idx, group := range groups {
+		groupMsg, err := server.db.ConstructGroupMessage(group.ID)
+		if err != nil {
+			server.respondWithError(ERR_INT_DB_GET, err, cGetGroupsTag,
+				writer, request)
+			return
+		}
+
+		groupListMsg.Groups[idx] = *groupMsg
+	}
+
+	// marshall the response
+	responseBody, err := json.MarshalIndent(groupListMsg, "", "\t")
+	if err != nil {
+		server.respondWithError(ERR_INT_MARSHALL, err, cGetGroupsTag, writer,
+			request)
+		return
+	}
+
+	// send the response
+	err = server.respondWithJSONBody(writer, responseBody)
+	if err != nil {
+		server.respondWithError(ERR_INT_WRITE, err, cGetGroupsTag, writer,
+			request)
+	}
+}
+
+func (server *sServer) UpdateGroup(writer http.ResponseWriter,
+	request *http.Request, _ httprouter.Params) {
+
+	// authenticate the user
+	user, errCode := server.authenticateRequest(request)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cUpdateGroupTag, writer, request)
+		return
+	}
+
+	// decode the group
+	var groupMsg polly.GroupMessage
+	decoder := json.NewDecoder(request.Body)
+	err := decoder.Decode(&groupMsg)
+	if err != nil {
+		server.respondWithError(ERR_BAD_JSON, err, cUpdateGroupTag, writer,
+			request)
+		return
+	}
+
+	// make sure the user owns the group
+	group, err := server.db.GetGroupByID(groupMsg.MetaData.ID)
+	if err != nil {
+		server.respondWithError(ERR_BAD_NO_GROUP, err, cUpdateGroupTag, writer,
+			request)
+		return
+	} else if group.OwnerID != user.ID {
+		server.respondWithError(ERR_ILL_GROUP_ACCESS, nil, cUpdateGroupTag,
+			writer, request)
+		return
+	}
+
+	// validate the group
+	errCode = isValidGroupMessage(&server.db, &groupMsg, user.ID)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cUpdateGroupTag, writer, request)
+		return
+	}
+
+	// update the group
+	joinedIDs, err := server.db.UpdateGroupMessage(&groupMsg)
+	if err != nil {
+		server.respondWithError(ERR_INT_DB_UPDATE, err, cUpdateGroupTag, writer,
+			request)
+		return
+	}
+
+	// invite the members that joined to the group's open polls
+	if groupMsg.MetaData.InviteToOpenPolls && len(joinedIDs) > 0 {
+		server.inviteToOpenGroupPolls(user, groupMsg.MetaData.ID, joinedIDs)
+	}
+
+	// marshall the response
+	responseBody, err := json.MarshalIndent(groupMsg, "", "\t")
+	if err != nil {
+		server.respondWithError(ERR_INT_MARSHALL, err, cUpdateGroupTag, writer,
+			request)
+		return
+	}
+
+	// send the response
+	err = server.respondWithJSONBody(writer, responseBody)
+	if err != nil {
+		server.respondWithError(ERR_INT_WRITE, err, cUpdateGroupTag, writer,
+			request)
+	}
+}
+
+func (server *sServer) DeleteGroup(writer http.ResponseWriter,
+	request *http.Request, _ httprouter.Params) {
+
+	// authenticate the user
+	user, errCode := server.authenticateRequest(request)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cDeleteGroupTag, writer, request)
+		return
+	}
+
+	// convert the id to an integer
+	ids := request.URL.Query()[cID]
+	if len(ids) == 0 {
+		server.respondWithError(ERR_BAD_NO_ID, nil, cDeleteGroupTag, writer,
+			request)
+		return
+	}
+
+	// parse the provided group id to an integer
+	groupID, err := strconv.ParseInt(ids[0], 10, 64)
+	if err != nil {
+		server.respondWithError(ERR_BAD_ID, err, cDeleteGroupTag, writer,
+			request)
+		return
+	}
+
+	// make sure the user owns the group
+	group, err := server.db.GetGroupByID(groupID)
+	if err != nil {
+		server.respondWithError(ERR_BAD_NO_GROUP, err, cDeleteGroupTag, writer,
+			request)
+		return
+	} else if group.OwnerID != user.ID {
+		server.respondWithError(ERR_ILL_GROUP_ACCESS, nil, cDeleteGroupTag,
+			writer, request)
+		return
+	}
+
+	// delete the group, polls created for it are left untouched
+	err = server.db.DeleteGroup(groupID)
+	if err != nil {
+		server.respondWithError(ERR_INT_DB_DELETE, err, cDeleteGroupTag, writer,
+			request)
+		return
+	}
+
+	// respond with 200 OK
+	server.respondOkay(writer, request)
+}
+
+/*
+ * Adds the given users to all open polls that were created for the given group
+ * and notifies the poll participants. Failures are logged, as the group itself
+ * has already been updated at this point.
+ */
+func (server *sServer) inviteToOpenGroupPolls(owner *polly.PrivateUser,
+	groupID int64, userIDs []int64) {
+
+	currentTime := time.Now().UnixNano() / 1000000
+	pollIDs, err := server.db.GetOpenPollIDsByGroupID(groupID, currentTime)
+	if err != nil {
+		server.logger.Log(cUpdateGroupTag, "Error retrieving open polls: "+
+			err.Error(), "::1")
+		return
+	}
+
+	for _, pollID := range pollIDs {
+
+		// the owner may have left the poll in the meantime
+		if !server.hasPollAccess(owner.ID, pollID) {
+			continue
+		}
+
+		question, err := server.db.GetQuestionByPollID(pollID)
+		if err != nil {
+			server.logger.Log(cUpdateGroupTag, "Error retrieving question: "+
+				err.Error(), "::1")
+			continue
+		}
+
+		for _, userID := range userIDs {
+			newUser, err := server.db.GetUserByID(userID)
+			if err != nil {
+				continue
+			}
+
+			// users that already participate are skipped silently
+			errCode, err := server.addParticipant(pollID, newUser,
+				question.Title, cUpdateGroupTag, nil)
+			if errCode == ERR_BAD_DUPLICATE_PARTICIPANT {
+				continue
+			} else if errCode != NO_ERR {
+				server.logger.Log(cUpdateGroupTag, fmt.Sprintf(
+					"Error adding participant (%d): %s", errCode, err), "::1")
+				continue
+			}
+
+			err = server.pushClient.NotifyForNewParticipant(&server.db, owner,
+				pollID, question.Title, newUser)
+			if err != nil {
+				// TODO neaten up
+				server.logger.Log(cUpdateGroupTag, "Error notifying: "+
+					err.Error(), "::1")
+			}
+		}
+	}
+}
@@ -0,0 +1,568 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/roxot/polly"
+	"github.com/roxot/polly/database"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/lib/pq"
+	"github.com/satori/go.uuid"
+)
+
+const (
+	cGuestPageTag           = "GET/GUEST"
+	cGuestSnapshotTag       = "GET/GUEST/SNAPSHOT"
+	cJoinAsGuestTag         = "POST/GUEST/JOIN"
+	cGuestVoteTag           = "POST/GUEST/VOTE"
+	cGuestUndoVoteTag       = "POST/GUEST/UNVOTE"
+	cUpdateGuestSettingsTag = "PUT/GUESTS"
+	cRemoveGuestTag         = "DELETE/GUEST"
+
+	cTokenParam       = "token"
+	cDisplayNameField = "display_name"
+	cOptionIDField    = "option_id"
+	cValueField       = "value"
+	cVoteIDField      = "vote_id"
+	cGuestURLFormat   = "/guest/%s"
+)
+
+// GET /guest/:token
+func (server *sServer) GuestPage(writer http.ResponseWriter,
+	request *http.Request, params httprouter.Params) {
+
+	token := params.ByName(cTokenParam)
+	page := sWebPollPage{Token: token}
+
+	// retrieve the invite link
+	if !isValidInviteToken(server.inviteSecret, token) {
+		server.renderError(ERR_BAD_INVITE_TOKEN, nil, cGuestPageTag, writer,
+			request)
+		return
+	}
+
+	inviteLink, err := server.db.GetInviteLinkByToken(token)
+	if err != nil {
+		server.renderError(ERR_BAD_INVITE_TOKEN, err, cGuestPageTag, writer,
+			request)
+		return
+	}
+
+	// show the poll to guests that already joined it
+	guest, session, errCode := server.authenticateWebRequest(request)
+	if errCode == NO_ERR && guest.GuestPollID == inviteLink.PollID &&
+		server.hasPollAccess(guest.ID, inviteLink.PollID) {
+
+		pollMsg, err := server.db.ConstructPollMessage(inviteLink.PollID,
+			guest.ID)
+		if err != nil {
+			server.renderError(ERR_INT_DB_GET, err, cGuestPageTag, writer,
+				request)
+			return
+		}
+
+		page.Poll = newWebPoll(pollMsg, guest.ID)
+		page.Guest = guest
+		page.CSRFToken = session.CSRFToken
+		page.ActionPrefix = fmt.Sprintf(cGuestURLFormat, token)
+		server.renderTemplate(cGuestTemplate, &page, cGuestPageTag, writer,
+			request)
+		return
+	}
+
+	// otherwise make sure the visitor is able to join
+	_, errCode, err = server.getGuestInviteLink(token)
+	if errCode != NO_ERR {
+		server.renderError(errCode, err, cGuestPageTag, writer, request)
+		return
+	}
+
+	server.renderTemplate(cGuestTemplate, &page, cGuestPageTag, writer,
+		request)
+}
+
+// GET /guest/:token/snapshot.json
+func (server *sServer) GuestPollSnapshot(writer http.ResponseWriter,
+	request *http.Request, params httprouter.Params) {
+
+	// authenticate the guest
+	guest, _, errCode := server.authenticateWebRequest(request)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cGuestSnapshotTag, writer,
+			request)
+		return
+	}
+
+	// make sure the guest belongs to the poll of the invite link
+	inviteLink, err := server.db.GetInviteLinkByToken(
+		params.ByName(cTokenParam))
+	if err != nil {
+		server.respondWithError(ERR_BAD_INVITE_TOKEN, err, cGuestSnapshotTag,
+			writer, request)
+		return
+	} else if guest.GuestPollID != inviteLink.PollID {
+		server.respondWithError(ERR_ILL_POLL_ACCESS, nil, cGuestSnapshotTag,
+			writer, request)
+		return
+	}
+
+	server.respondWithSnapshot(inviteLink.PollID, cGuestSnapshotTag, writer,
+		request)
+}
+
+// POST /guest/:token/join
+func (server *sServer) JoinAsGuest(writer http.ResponseWriter,
+	request *http.Request, params httprouter.Params) {
+
+	token := params.ByName(cTokenParam)
+
+	// retrieve and check the invite link
+	inviteLink, errCode, err := server.getGuestInviteLink(token)
+	if errCode != NO_ERR {
+		server.renderError(errCode, err, cJoinAsGuestTag, writer, request)
+		return
+	}
+
+	// make sure the guest chose a display name
+	displayName := strings.TrimSpace(request.PostFormValue(cDisplayNameField))
+	if len(displayName) == 0 {
+		server.renderError(ERR_BAD_NO_DISPLAY_NAME, nil, cJoinAsGuestTag,
+			writer, request)
+		return
+	} else if len([]rune(displayName)) > cMaxDisplayNameLen {
+		displayName = string([]rune(displayName)[:cMaxDisplayNameLen])
+	}
+
+	// create the guest
+	guest, errCode, err := server.addGuest(displayName, inviteLink.PollID,
+		cJoinAsGuestTag)
+	if errCode != NO_ERR {
+		server.renderError(errCode, err, cJoinAsGuestTag, writer, request)
+		return
+	}
+
+	// join the poll, guests can't exist outside of their poll
+	errCode, err = server.joinWithInviteLink(guest, inviteLink,
+		cJoinAsGuestTag)
+	if errCode != NO_ERR {
+		if err := server.db.DeleteUser(guest.ID); err != nil {
+			server.logger.Log(cJoinAsGuestTag, "Error deleting guest: "+
+				err.Error(), "::1")
+		}
+
+		server.renderError(errCode, err, cJoinAsGuestTag, writer, request)
+		return
+	}
+
+	// start the guest's session
+	_, err = server.startWebSession(guest.ID, writer)
+	if err != nil {
+		server.renderError(ERR_INT_DB_ADD, err, cJoinAsGuestTag, writer,
+			request)
+		return
+	}
+
+	http.Redirect(writer, request, fmt.Sprintf(cGuestURLFormat, token),
+		http.StatusSeeOther)
+}
+
+// POST /guest/:token/vote
+func (server *sServer) GuestVote(writer http.ResponseWriter,
+	request *http.Request, params httprouter.Params) {
+
+	token := params.ByName(cTokenParam)
+
+	// authenticate the guest
+	guest, errCode := server.authenticateGuestForm(token, request)
+	if errCode != NO_ERR {
+		server.renderError(errCode, nil, cGuestVoteTag, writer, request)
+		return
+	}
+
+	// construct the vote message from the form
+	voteMsg, errCode, err := server.voteMessageFromForm(guest.GuestPollID,
+		request)
+	if errCode != NO_ERR {
+		server.renderError(errCode, err, cGuestVoteTag, writer, request)
+		return
+	}
+
+	// cast the vote
+	_, errCode, err = server.castVote(guest, voteMsg, cGuestVoteTag)
+	if errCode != NO_ERR {
+		server.renderError(errCode, err, cGuestVoteTag, writer, request)
+		return
+	}
+
+	http.Redirect(writer, request, fmt.Sprintf(cGuestURLFormat, token),
+		http.StatusSeeOther)
+}
+
+// POST /guest/:token/unvote
+func (server *sServer) GuestUndoVote(writer http.ResponseWriter,
+	request *http.Request, params httprouter.Params) {
+
+	token := params.ByName(cTokenParam)
+
+	// authenticate the guest
+	guest, errCode := server.authenticateGuestForm(token, request)
+	if errCode != NO_ERR {
+		server.renderError(errCode, nil, cGuestUndoVoteTag, writer, request)
+		return
+	}
+
+	// parse the provided vote id to an integer
+	voteID, err := strconv.ParseInt(request.PostFormValue(cVoteIDField), 10,
+		64)
+	if err != nil {
+		server.renderError(ERR_BAD_ID, err, cGuestUndoVoteTag, writer, request)
+		return
+	}
+
+	// undo the vote
+	_, errCode, err = server.undoVote(guest, voteID, cGuestUndoVoteTag)
+	if errCode != NO_ERR {
+		server.renderError(errCode, err, cGuestUndoVoteTag, writer, request)
+		return
+	}
+
+	http.Redirect(writer, request, fmt.Sprintf(cGuestURLFormat, token),
+		http.StatusSeeOther)
+}
+
+// PUT /v0.1/guests.json
+func (server *sServer) UpdateGuestSettings(writer http.ResponseWriter,
+	request *http.Request, _ httprouter.Params) {
+
+	// authenticate the user
+	user, errCode := server.authenticateRequest(request)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cUpdateGuestSettingsTag, writer,
+			request)
+		return
+	}
+
+	// decode the guest settings
+	var settingsMsg polly.GuestSettingsMessage
+	decoder := json.NewDecoder(request.Body)
+	err := decoder.Decode(&settingsMsg)
+	if err != nil {
+		server.respondWithError(ERR_BAD_JSON, err, cUpdateGuestSettingsTag,
+			writer, request)
+		return
+	}
+
+	// make sure the user is allowed to manage the participants
+	_, errCode = server.authorizePollAction(user.ID, settingsMsg.PollID,
+		cPermissionManageParticipants)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cUpdateGuestSettingsTag, writer,
+			request)
+		return
+	}
+
+	// update the setting, guests that already joined remain in the poll
+	err = server.db.UpdateAllowGuests(settingsMsg.PollID,
+		settingsMsg.AllowGuests)
+	if err != nil {
+		server.respondWithError(ERR_INT_DB_UPDATE, err,
+			cUpdateGuestSettingsTag, writer, request)
+		return
+	}
+
+	// respond with 200 OK
+	server.respondOkay(writer, request)
+}
+
+// DELETE /v0.1/guest.json
+func (server *sServer) RemoveGuest(writer http.ResponseWriter,
+	request *http.Request, _ httprouter.Params) {
+
+	// authenticate the user
+	user, errCode := server.authenticateRequest(request)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cRemoveGuestTag, writer, request)
+		return
+	}
+
+	// convert the id to an integer
+	ids := request.URL.Query()[cID]
+	if len(ids) == 0 {
+		server.respondWithError(ERR_BAD_NO_ID, nil, cRemoveGuestTag, writer,
+			request)
+		return
+	}
+
+	// parse the provided guest id to an integer
+	guestID, err := strconv.ParseInt(ids[0], 10, 64)
+	if err != nil {
+		server.respondWithError(ERR_BAD_ID, err, cRemoveGuestTag, writer,
+			request)
+		return
+	}
+
+	// retrieve the guest
+	guest, err := server.db.GetUserByID(guestID)
+	if err != nil || guest.GuestPollID == 0 {
+		server.respondWithError(ERR_BAD_NO_GUEST, err, cRemoveGuestTag, writer,
+			request)
+		return
+	}
+
+	pollID := guest.GuestPollID
+
+	// make sure the user is allowed to manage the participants
+	_, errCode = server.authorizePollAction(user.ID, pollID,
+		cPermissionManageParticipants)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cRemoveGuestTag, writer, request)
+		return
+	}
+
+	// retrieve the poll question
+	question, err := server.db.GetQuestionByPollID(pollID)
+	if err != nil {
+		server.respondWithError(ERR_INT_DB_GET, err, cRemoveGuestTag, writer,
+			request)
+		return
+	}
+
+	currentTime := time.Now().UnixNano() / 1000000
+	retryTransaction := true
+	transactionNumber := rand.Int()
+	for retryTransaction {
+
+		// start a transaction
+		tx, err := server.db.Begin()
+		if err != nil {
+			tx.Rollback()
+			server.respondWithError(ERR_INT_DB_TX_BEGIN, err, cRemoveGuestTag,
+				writer, request)
+			return
+		}
+
+		// set the transaction isolation level
+		_, err = tx.Exec("set transaction isolation level serializable;")
+		if err != nil {
+			tx.Rollback()
+			server.respondWithError(ERR_INT_DB_TX_SET_TX_LEVEL, err,
+				cRemoveGuestTag, writer, request)
+			return
+		}
+
+		// update the poll last updated and seq number
+		err = database.UpdatePollTX(pollID, currentTime,
+			polly.EVENT_TYPE_PARTICIPANT_LEFT, guest.DisplayName, guest.ID,
+			question.Title, guest.ID, tx)
+		if err != nil {
+			tx.Rollback()
+			if pqErr, ok := err.(*pq.Error); ok &&
+				pqErr.Code == database.ERR_SERIALIZATION_FAILURE {
+				server.logger.Log(cRemoveGuestTag, fmt.Sprintf("%d: %s",
+					transactionNumber, "Serialization failure, retrying..."),
+					"::1")
+				continue
+			}
+
+			server.respondWithError(ERR_INT_DB_UPDATE, err, cRemoveGuestTag,
+				writer, request)
+			return
+		}
+
+		// delete the guest's votes, participation, sessions and user
+		err = database.DeleteVotesForUserTX(guest.ID, pollID, tx)
+		if err == nil {
+			err = database.DeleteParticipantTX(guest.ID, pollID, tx)
+		}
+		if err == nil {
+			err = database.DeleteWebSessionsForUserTX(guest.ID, tx)
+		}
+		if err == nil {
+			err = database.DeleteUserTX(guest.ID, tx)
+		}
+		if err != nil {
+			tx.Rollback()
+			if pqErr, ok := err.(*pq.Error); ok &&
+				pqErr.Code == database.ERR_SERIALIZATION_FAILURE {
+				server.logger.Log(cRemoveGuestTag, fmt.Sprintf("%d: %s",
+					transactionNumber, "Serialization failure, retrying..."),
+					"::1")
+				continue
+			}
+
+			server.respondWithError(ERR_INT_DB_DELETE, err, cRemoveGuestTag,
+				writer, request)
+			return
+		}
+
+		// commit the transaction
+		err = tx.Commit()
+		if err != nil {
+			tx.Rollback()
+			server.respondWithError(ERR_INT_DB_TX_COMMIT, err, cRemoveGuestTag,
+				writer, request)
+			return
+		}
+
+		retryTransaction = false
+	}
+
+	// notify the poll participants
+	err = server.pushClient.NotifyForParticipantLeft(&server.db, guest, pollID,
+		question.Title)
+	if err != nil {
+		// TODO neaten up
+		server.logger.Log(cRemoveGuestTag, "Error notifying: "+err.Error(),
+			"::1")
+	}
+
+	// respond with 200 ok
+	server.respondOkay(writer, request)
+}
+
+/*
+ * Retrieves the invite link belonging to the given token and makes sure guests
+ * can use it to join the poll. Returns an API error code and the underlying
+ * error.
+ */
+func (server *sServer) getGuestInviteLink(token string) (*polly.InviteLink,
+	int, error) {
+
+	inviteLink, errCode, err := server.getUsableInviteLink(token)
+	if errCode != NO_ERR {
+		return nil, errCode, err
+	}
+
+	poll, err := server.db.GetPollByID(inviteLink.PollID)
+	if err != nil {
+		return nil, ERR_BAD_NO_POLL, err
+	} else if !poll.AllowGuests {
+		return nil, ERR_ILL_GUESTS_NOT_ALLOWED, nil
+	}
+
+	return inviteLink, NO_ERR, nil
+}
+
+/*
+ * Authenticates a form submitted by a guest of the poll that belongs to the
+ * given invite token. Returns the guest and an API error code.
+ */
+func (server *sServer) authenticateGuestForm(token string,
+	request *http.Request) (*polly.PrivateUser, int) {
+
+	guest, session, errCode := server.authenticateWebRequest(request)
+	if errCode != NO_ERR {
+		return nil, errCode
+	} else if !isValidCSRFToken(session, request) {
+		return nil, ERR_AUT_BAD_CSRF_TOKEN
+	}
+
+	inviteLink, err := server.db.GetInviteLinkByToken(token)
+	if err != nil {
+		return nil, ERR_BAD_INVITE_TOKEN
+	} else if guest.GuestPollID != inviteLink.PollID {
+		return nil, ERR_ILL_POLL_ACCESS
+	}
+
+	return guest, NO_ERR
+}
+
+/*
+ * Creates a guest user that is limited to the given poll. Guests receive
+ * negative identifiers so they never collide with Facebook identifiers.
+ * Returns the guest, an API error code and the underlying error.
+ */
+func (server *sServer) addGuest(displayName string, pollID int64,
+	tag string) (*polly.PrivateUser, int, error) {
+
+	guest := polly.PrivateUser{}
+	guest.DisplayName = displayName
+	guest.GuestPollID = pollID
+	guest.Token = uuid.NewV4().String()
+
+	transactionNumber := rand.Int()
+	for {
+
+		// start a transaction
+		tx, err := server.db.Begin()
+		if err != nil {
+			tx.Rollback()
+			return nil, ERR_INT_DB_TX_BEGIN, err
+		}
+
+		// set the transaction isolation level
+		_, err = tx.Exec("set transaction isolation level serializable;")
+		if err != nil {
+			tx.Rollback()
+			return nil, ERR_INT_DB_TX_SET_TX_LEVEL, err
+		}
+
+		// pick the next free negative identifier
+		minID, err := database.GetMinUserIDTX(tx)
+		if err == nil {
+			guest.ID = minID - 1
+			err = database.AddUserTX(&guest, tx)
+		}
+		if err != nil {
+			tx.Rollback()
+			if pqErr, ok := err.(*pq.Error); ok &&
+				pqErr.Code == database.ERR_SERIALIZATION_FAILURE {
+				server.logger.Log(tag, fmt.Sprintf("%d: %s",
+					transactionNumber, "Serialization failure, retrying..."),
+					"::1")
+				continue
+			}
+
+			return nil, ERR_INT_DB_ADD, err
+		}
+
+		// commit the transaction
+		err = tx.Commit()
+		if err != nil {
+			tx.Rollback()
+			return nil, ERR_INT_DB_TX_COMMIT, err
+		}
+
+		return &guest, NO_ERR, nil
+	}
+}
+
+/*
+ * Constructs a vote message from a submitted web form. The form either holds
+ * the identifier of an existing option or the value of a new option for the
+ * question of the given poll.
+ */
+func (server *sServer) voteMessageFromForm(pollID int64,
+	request *http.Request) (*polly.VoteMessage, int, error) {
+
+	voteMsg := polly.VoteMessage{}
+	if optionIDStr := request.PostFormValue(cOptionIDField); len(
+		optionIDStr) > 0 {
+
+		optionID, err := strconv.ParseInt(optionIDStr, 10, 64)
+		if err != nil {
+			return nil, ERR_BAD_ID, err
+		}
+
+		voteMsg.Type = polly.VOTE_TYPE_UPVOTE
+		voteMsg.ID = optionID
+		return &voteMsg, NO_ERR, nil
+	}
+
+	question, err := server.db.GetQuestionByPollID(pollID)
+	if err != nil {
+		return nil, ERR_BAD_NO_QUESTION, err
+	}
+
+	voteMsg.Type = polly.VOTE_TYPE_NEW
+	voteMsg.ID = question.ID
+	voteMsg.Value = strings.TrimSpace(request.PostFormValue(cValueField))
+	return &voteMsg, NO_ERR, nil
+}
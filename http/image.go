@@ -0,0 +1,335 @@
+package http
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/roxot/polly"
+	"github.com/roxot/polly/blob"
+	"github.com/roxot/polly/imaging"
+
+	"github.com/dchest/uniuri"
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	cUploadProfilePicTag     = "POST/PROFILE_PIC"
+	cUploadImageTag          = "POST/IMAGE"
+	cGetImageTag             = "GET/IMAGE"
+	cImagePathParam          = "path"
+	cImagesPath              = "/images/"
+	cProfilePicPrefix        = "profile"
+	cOptionImagePrefix       = "option"
+	cThumbnailSuffix         = "_thumb"
+	cImageNameLength         = 24
+	cMaxImageBytes           = 8 << 20
+	cProfilePicSize          = 512
+	cOptionImageSize         = 1024
+	cThumbnailSize           = 128
+	cImageCacheControl       = "public, max-age=31536000, immutable"
+	cUnattachedImageLifetime = 24 * time.Hour
+)
+
+type tImageToCleanUp struct {
+	ID int64
+}
+
+var vImageExtensions = map[string]string{
+	imaging.FORMAT_JPEG: ".jpg",
+	imaging.FORMAT_PNG:  ".png",
+}
+
+var vImageContentTypes = map[string]string{
+	".jpg": "image/jpeg",
+	".png": "image/png",
+}
+
+/* An uploaded image as it was stored, along with its thumbnail. */
+type sStoredImage struct {
+	Key    string
+	Width  int
+	Height int
+}
+
+/*
+ * Replaces the profile picture of the user with the JPEG or PNG image in the
+ * request body. The picture is stored without its metadata and at most
+ * cProfilePicSize pixels wide and high, next to a thumbnail of at most
+ * cThumbnailSize pixels. The thumbnail URL is the picture URL with
+ * cThumbnailSuffix before its extension.
+ */
+// POST /v0.1/profile_pic.json
+func (server *sServer) UploadProfilePic(writer http.ResponseWriter,
+	request *http.Request, _ httprouter.Params) {
+
+	// authenticate the user
+	user, errCode := server.authenticateRequest(request)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cUploadProfilePicTag, writer,
+			request)
+		return
+	}
+
+	// store the picture and its thumbnail
+	prefix := fmt.Sprintf("%s/%d", cProfilePicPrefix, user.ID)
+	storedImage, errCode, err := server.storeUploadedImage(writer, request,
+		prefix, cProfilePicSize)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, err, cUploadProfilePicTag, writer,
+			request)
+		return
+	}
+
+	// point the profile picture at the stored image
+	previousKey := user.ProfilePicKey
+	user.ProfilePic = server.imageURL(storedImage.Key)
+	user.ProfilePicKey = storedImage.Key
+	err = server.db.UpdateProfilePic(user.ID, user.ProfilePic,
+		user.ProfilePicKey)
+	if err != nil {
+		server.deleteStoredImage(storedImage.Key)
+		server.respondWithError(ERR_INT_DB_UPDATE, err, cUploadProfilePicTag,
+			writer, request)
+		return
+	}
+
+	// the previous picture isn't referenced anymore
+	server.deleteStoredImage(previousKey)
+
+	// create the response body
+	responseBody, err := json.MarshalIndent(user, "", "\t")
+	if err != nil {
+		server.respondWithError(ERR_INT_MARSHALL, err, cUploadProfilePicTag,
+			writer, request)
+		return
+	}
+
+	// send the user a 200 OK with the updated user
+	err = server.respondWithJSONBody(writer, responseBody)
+	if err != nil {
+		server.respondWithError(ERR_INT_WRITE, err, cUploadProfilePicTag,
+			writer, request)
+	}
+}
+
+/*
+ * Uploads an image that the options of a poll can refer to. The image is
+ * deleted again if no option refers to it within cUnattachedImageLifetime.
+ */
+// POST /v0.1/image.json
+func (server *sServer) UploadImage(writer http.ResponseWriter,
+	request *http.Request, _ httprouter.Params) {
+
+	// authenticate the user
+	user, errCode := server.authenticateRequest(request)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cUploadImageTag, writer, request)
+		return
+	}
+
+	// store the image and its thumbnail
+	prefix := fmt.Sprintf("%s/%d", cOptionImagePrefix, user.ID)
+	storedImage, errCode, err := server.storeUploadedImage(writer, request,
+		prefix, cOptionImageSize)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, err, cUploadImageTag, writer, request)
+		return
+	}
+
+	// insert the image
+	now := time.Now()
+	image := polly.Image{}
+	image.UserID = user.ID
+	image.BlobKey = storedImage.Key
+	image.URL = server.imageURL(storedImage.Key)
+	image.ThumbnailURL = server.imageURL(thumbnailKey(storedImage.Key))
+	image.Width = storedImage.Width
+	image.Height = storedImage.Height
+	image.CreationDate = now.UnixNano() / 1000000
+	err = server.db.AddImage(&image)
+	if err != nil {
+		server.deleteStoredImage(storedImage.Key)
+		server.respondWithError(ERR_INT_DB_ADD, err, cUploadImageTag, writer,
+			request)
+		return
+	}
+
+	// clean up the image in case it never gets used
+	_, err = server.scheduler.Schedule(cImageCleanupJobs,
+		now.Add(cUnattachedImageLifetime), &tImageToCleanUp{image.ID})
+	if err != nil {
+		server.logger.Log(cUploadImageTag, fmt.Sprintf(
+			"Error scheduling the clean up of image %d: %s", image.ID, err),
+			"::1")
+	}
+
+	// marshall the response
+	responseBody, err := json.MarshalIndent(image, "", "\t")
+	if err != nil {
+		server.respondWithError(ERR_INT_MARSHALL, err, cUploadImageTag, writer,
+			request)
+		return
+	}
+
+	// send the response
+	err = server.respondWithJSONBody(writer, responseBody)
+	if err != nil {
+		server.respondWithError(ERR_INT_WRITE, err, cUploadImageTag, writer,
+			request)
+	}
+}
+
+/* Deletes an uploaded image that no option refers to. */
+func (server *sServer) cleanUpImageJob(data []byte) error {
+	var imageToCleanUp tImageToCleanUp
+	err := json.Unmarshal(data, &imageToCleanUp)
+	if err != nil {
+		return err
+	}
+
+	image, err := server.db.GetImageByID(imageToCleanUp.ID)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	deleted, err := server.db.DeleteUnattachedImage(image.ID)
+	if err != nil {
+		return err
+	} else if deleted {
+		server.deleteStoredImage(image.BlobKey)
+	}
+
+	return nil
+}
+
+/*
+ * Serves stored images. Their keys are random and never reused, so they can
+ * be cached forever.
+ */
+// GET /images/*path
+func (server *sServer) GetImage(writer http.ResponseWriter,
+	request *http.Request, params httprouter.Params) {
+
+	key := strings.TrimPrefix(params.ByName(cImagePathParam), "/")
+	contentType, ok := vImageContentTypes[path.Ext(key)]
+	if !ok {
+		server.respondWithError(ERR_BAD_NO_IMAGE, nil, cGetImageTag, writer,
+			request)
+		return
+	}
+
+	data, err := server.blobStore.Get(key)
+	if err == blob.ErrNotFound {
+		server.respondWithError(ERR_BAD_NO_IMAGE, nil, cGetImageTag, writer,
+			request)
+		return
+	} else if err != nil {
+		server.respondWithError(ERR_INT_BLOB_STORE, err, cGetImageTag, writer,
+			request)
+		return
+	}
+
+	writer.Header().Set("Content-Type", contentType)
+	writer.Header().Set("Cache-Control", cImageCacheControl)
+	_, err = writer.Write(data)
+	if err != nil {
+		server.respondWithError(ERR_INT_WRITE, err, cGetImageTag, writer,
+			request)
+	}
+}
+
+/*
+ * Decodes the image in the request body and stores it below the given prefix,
+ * scaled down to the given size, together with its thumbnail. Storing the
+ * image again drops any metadata it carried. Returns the stored image, an API
+ * error code and the underlying error.
+ */
+func (server *sServer) storeUploadedImage(writer http.ResponseWriter,
+	request *http.Request, prefix string, size int) (*sStoredImage, int,
+	error) {
+
+	// read the image, refusing to read more than the maximum size
+	data, err := ioutil.ReadAll(http.MaxBytesReader(writer, request.Body,
+		cMaxImageBytes))
+	if err != nil {
+		return nil, ERR_BAD_IMAGE_TOO_LARGE, err
+	}
+
+	// decode the image, which checks its type and dimensions
+	img, format, err := imaging.Decode(data)
+	if err == imaging.ErrUnsupportedFormat {
+		return nil, ERR_BAD_IMAGE_TYPE, err
+	} else if err == imaging.ErrTooManyPixels {
+		return nil, ERR_BAD_IMAGE_TOO_LARGE, err
+	} else if err != nil {
+		return nil, ERR_BAD_IMAGE, err
+	}
+
+	// encode the image and its thumbnail
+	img = imaging.Fit(img, size)
+	encoded, err := imaging.Encode(img, format)
+	if err != nil {
+		return nil, ERR_BAD_IMAGE, err
+	}
+
+	encodedThumbnail, err := imaging.Encode(imaging.Fit(img, cThumbnailSize),
+		format)
+	if err != nil {
+		return nil, ERR_BAD_IMAGE, err
+	}
+
+	// store both under a fresh key
+	storedImage := sStoredImage{}
+	storedImage.Key = fmt.Sprintf("%s/%s%s", prefix,
+		uniuri.NewLen(cImageNameLength), vImageExtensions[format])
+	storedImage.Width = img.Bounds().Dx()
+	storedImage.Height = img.Bounds().Dy()
+
+	err = server.blobStore.Put(storedImage.Key, encoded)
+	if err == nil {
+		err = server.blobStore.Put(thumbnailKey(storedImage.Key),
+			encodedThumbnail)
+	}
+	if err != nil {
+		server.deleteStoredImage(storedImage.Key)
+		return nil, ERR_INT_BLOB_STORE, err
+	}
+
+	return &storedImage, NO_ERR, nil
+}
+
+/*
+ * Deletes a stored image and its thumbnail. Failures are only logged, an image
+ * that is left behind isn't referenced anymore.
+ */
+func (server *sServer) deleteStoredImage(key string) {
+	if len(key) == 0 {
+		return
+	}
+
+	for _, blobKey := range []string{key, thumbnailKey(key)} {
+		err := server.blobStore.Delete(blobKey)
+		if err != nil {
+			server.logger.Log(cGetImageTag, fmt.Sprintf(
+				"Error deleting image %s: %s", blobKey, err), "::1")
+		}
+	}
+}
+
+/* Returns the public URL of the stored image with the given key. */
+func (server *sServer) imageURL(key string) string {
+	return server.publicURL + cImagesPath + key
+}
+
+func thumbnailKey(key string) string {
+	extension := path.Ext(key)
+	return strings.TrimSuffix(key, extension) + cThumbnailSuffix + extension
+}
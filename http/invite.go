@@ -0,0 +1,414 @@
+package http
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/roxot/polly"
+	"github.com/roxot/polly/database"
+
+	"github.com/dchest/uniuri"
+	"github.com/julienschmidt/httprouter"
+	"gopkg.in/gorp.v1"
+)
+
+const (
+	cPostInviteLinkTag   = "POST/INVITE"
+	cGetInviteLinksTag   = "GET/INVITES"
+	cRevokeInviteLinkTag = "DELETE/INVITE"
+	cRedeemInviteLinkTag = "POST/JOIN"
+
+	cInviteTokenRandomLength = 24
+	cInviteTokenSeparator    = "."
+	cInviteSecretLength      = 32
+)
+
+func (server *sServer) PostInviteLink(writer http.ResponseWriter,
+	request *http.Request, _ httprouter.Params) {
+
+	// authenticate the user
+	user, errCode := server.authenticateRequest(request)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cPostInviteLinkTag, writer,
+			request)
+		return
+	}
+
+	// decode the invite link
+	var inviteLink polly.InviteLink
+	decoder := json.NewDecoder(request.Body)
+	err := decoder.Decode(&inviteLink)
+	if err != nil {
+		server.respondWithError(ERR_BAD_JSON, err, cPostInviteLinkTag, writer,
+			request)
+		return
+	}
+
+	// make sure the user is allowed to manage the participants
+	_, errCode = server.authorizePollAction(user.ID, inviteLink.PollID,
+		cPermissionManageParticipants)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cPostInviteLinkTag, writer,
+			request)
+		return
+	}
+
+	// retrieve the closing date
+	closingDate, err := server.db.GetClosingDate(inviteLink.PollID)
+	if err != nil {
+		server.respondWithError(ERR_INT_DB_GET, err, cPostInviteLinkTag,
+			writer, request)
+		return
+	}
+
+	// make sure the poll hasn't closed yet
+	currentTime := time.Now().UnixNano() / 1000000
+	if currentTime > closingDate {
+		server.respondWithError(ERR_ILL_POLL_CLOSED, nil, cPostInviteLinkTag,
+			writer, request)
+		return
+	}
+
+	// an expiration date of zero means the link never expires
+	if inviteLink.ExpirationDate != 0 &&
+		inviteLink.ExpirationDate <= currentTime {
+
+		server.respondWithError(ERR_BAD_EXPIRATION_DATE, nil,
+			cPostInviteLinkTag, writer, request)
+		return
+	}
+
+	// a maximum of zero uses means the link can be used indefinitely
+	if inviteLink.MaxUses < 0 {
+		server.respondWithError(ERR_BAD_MAX_USES, nil, cPostInviteLinkTag,
+			writer, request)
+		return
+	}
+
+	// insert the invite link
+	inviteLink.CreatorID = user.ID
+	inviteLink.CreationDate = currentTime
+	inviteLink.Token = newInviteToken(server.inviteSecret)
+	inviteLink.Uses = 0
+	inviteLink.Revoked = false
+	err = server.db.AddInviteLink(&inviteLink)
+	if err != nil {
+		server.respondWithError(ERR_INT_DB_ADD, err, cPostInviteLinkTag,
+			writer, request)
+		return
+	}
+
+	// marshall the response
+	responseBody, err := json.MarshalIndent(inviteLink, "", "\t")
+	if err != nil {
+		server.respondWithError(ERR_INT_MARSHALL, err, cPostInviteLinkTag,
+			writer, request)
+		return
+	}
+
+	// send the response
+	err = server.respondWithJSONBody(writer, responseBody)
+	if err != nil {
+		server.respondWithError(ERR_INT_WRITE, err, cPostInviteLinkTag, writer,
+			request)
+	}
+}
+
+func (server *sServer) GetInviteLinks(writer http.ResponseWriter,
+	request *http.Request, _ httprouter.Params) {
+
+	// authenticate the user
+	user, errCode := server.authenticateRequest(request)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cGetInviteLinksTag, writer,
+			request)
+		return
+	}
+
+	// convert the id to an integer
+	ids := request.URL.Query()[cID]
+	if len(ids) == 0 {
+		server.respondWithError(ERR_BAD_NO_ID, nil, cGetInviteLinksTag, writer,
+			request)
+		return
+	}
+
+	// parse the provided poll id to an integer
+	pollID, err := strconv.ParseInt(ids[0], 10, 64)
+	if err != nil {
+		server.respondWithError(ERR_BAD_ID, err, cGetInviteLinksTag, writer,
+			request)
+		return
+	}
+
+	// make sure the user is allowed to manage the participants
+	_, errCode = server.authorizePollAction(user.ID, pollID,
+		cPermissionManageParticipants)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cGetInviteLinksTag, writer,
+			request)
+		return
+	}
+
+	// retrieve the invite links
+	inviteLinks, err := server.db.GetInviteLinksByPollID(pollID)
+	if err != nil {
+		server.respondWithError(ERR_INT_DB_GET, err, cGetInviteLinksTag,
+			writer, request)
+		return
+	}
+
+	// construct the InviteLinkList object
+	inviteLinkListMsg := polly.InviteLinkListMessage{}
+	inviteLinkListMsg.InviteLinks = inviteLinks
+	if inviteLinkListMsg.InviteLinks == nil {
+		inviteLinkListMsg.InviteLinks = make([]polly.InviteLink, 0)
+	}
+
+	// marshall the response
+	responseBody, err := json.MarshalIndent(inviteLinkListMsg, "", "\t")
+	if err != nil {
+		server.respondWithError(ERR_INT_MARSHALL, err, cGetInviteLinksTag,
+			writer, request)
+		return
+	}
+
+	// send the response
+	err = server.respondWithJSONBody(writer, responseBody)
+	if err != nil {
+		server.respondWithError(ERR_INT_WRITE, err, cGetInviteLinksTag, writer,
+			request)
+	}
+}
+
+func (server *sServer) RevokeInviteLink(writer http.ResponseWriter,
+	request *http.Request, _ httprouter.Params) {
+
+	// authenticate the user
+	user, errCode := server.authenticateRequest(request)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cRevokeInviteLinkTag, writer,
+			request)
+		return
+	}
+
+	// convert the id to an integer
+	ids := request.URL.Query()[cID]
+	if len(ids) == 0 {
+		server.respondWithError(ERR_BAD_NO_ID, nil, cRevokeInviteLinkTag,
+			writer, request)
+		return
+	}
+
+	// parse the provided invite link id to an integer
+	inviteLinkID, err := strconv.ParseInt(ids[0], 10, 64)
+	if err != nil {
+		server.respondWithError(ERR_BAD_ID, err, cRevokeInviteLinkTag, writer,
+			request)
+		return
+	}
+
+	// retrieve the invite link
+	inviteLink, err := server.db.GetInviteLinkByID(inviteLinkID)
+	if err != nil {
+		server.respondWithError(ERR_BAD_NO_INVITE, err, cRevokeInviteLinkTag,
+			writer, request)
+		return
+	}
+
+	// make sure the user is allowed to manage the participants
+	_, errCode = server.authorizePollAction(user.ID, inviteLink.PollID,
+		cPermissionManageParticipants)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cRevokeInviteLinkTag, writer,
+			request)
+		return
+	}
+
+	// revoke the invite link
+	err = server.db.RevokeInviteLink(inviteLinkID)
+	if err != nil {
+		server.respondWithError(ERR_INT_DB_UPDATE, err, cRevokeInviteLinkTag,
+			writer, request)
+		return
+	}
+
+	// respond with 200 OK
+	server.respondOkay(writer, request)
+}
+
+func (server *sServer) RedeemInviteLink(writer http.ResponseWriter,
+	request *http.Request, _ httprouter.Params) {
+
+	// authenticate the user
+	user, errCode := server.authenticateRequest(request)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cRedeemInviteLinkTag, writer,
+			request)
+		return
+	}
+
+	// decode the redeem message
+	var redeemMsg polly.RedeemInviteMessage
+	decoder := json.NewDecoder(request.Body)
+	err := decoder.Decode(&redeemMsg)
+	if err != nil {
+		server.respondWithError(ERR_BAD_JSON, err, cRedeemInviteLinkTag,
+			writer, request)
+		return
+	}
+
+	// retrieve and check the invite link
+	inviteLink, errCode, err := server.getUsableInviteLink(redeemMsg.Token)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, err, cRedeemInviteLinkTag, writer,
+			request)
+		return
+	}
+
+	// join the poll
+	errCode, err = server.joinWithInviteLink(user, inviteLink,
+		cRedeemInviteLinkTag)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, err, cRedeemInviteLinkTag, writer,
+			request)
+		return
+	}
+
+	// construct the poll message
+	pollMsg, err := server.db.ConstructPollMessage(inviteLink.PollID,
+		user.ID)
+	if err != nil {
+		server.respondWithError(ERR_INT_DB_GET, err, cRedeemInviteLinkTag,
+			writer, request)
+		return
+	}
+
+	// marshall the response
+	responseBody, err := json.MarshalIndent(pollMsg, "", "\t")
+	if err != nil {
+		server.respondWithError(ERR_INT_MARSHALL, err, cRedeemInviteLinkTag,
+			writer, request)
+		return
+	}
+
+	// send the response
+	err = server.respondWithJSONBody(writer, responseBody)
+	if err != nil {
+		server.respondWithError(ERR_INT_WRITE, err, cRedeemInviteLinkTag,
+			writer, request)
+	}
+}
+
+/*
+ * Retrieves the invite link belonging to the given token and makes sure it
+ * can still be used to join its poll. Returns an API error code and the
+ * underlying error.
+ */
+func (server *sServer) getUsableInviteLink(token string) (*polly.InviteLink,
+	int, error) {
+
+	// don't touch the database for tokens we didn't sign
+	if !isValidInviteToken(server.inviteSecret, token) {
+		return nil, ERR_BAD_INVITE_TOKEN, nil
+	}
+
+	// retrieve the invite link
+	inviteLink, err := server.db.GetInviteLinkByToken(token)
+	if err != nil {
+		return nil, ERR_BAD_INVITE_TOKEN, err
+	}
+
+	// make sure the invite link can still be used
+	currentTime := time.Now().UnixNano() / 1000000
+	if inviteLink.Revoked {
+		return nil, ERR_ILL_INVITE_REVOKED, nil
+	} else if inviteLink.ExpirationDate != 0 &&
+		currentTime > inviteLink.ExpirationDate {
+
+		return nil, ERR_ILL_INVITE_EXPIRED, nil
+	} else if inviteLink.MaxUses != 0 &&
+		inviteLink.Uses >= inviteLink.MaxUses {
+
+		return nil, ERR_ILL_INVITE_USED_UP, nil
+	}
+
+	// make sure the poll hasn't closed yet
+	closingDate, err := server.db.GetClosingDate(inviteLink.PollID)
+	if err != nil {
+		return nil, ERR_BAD_NO_POLL, err
+	} else if currentTime > closingDate {
+		return nil, ERR_ILL_POLL_CLOSED, nil
+	}
+
+	return inviteLink, NO_ERR, nil
+}
+
+/*
+ * Adds the given user to the poll of the given invite link, counts the use of
+ * the link and notifies the other participants. Returns an API error code and
+ * the underlying error.
+ */
+func (server *sServer) joinWithInviteLink(user *polly.PrivateUser,
+	inviteLink *polly.InviteLink, tag string) (int, error) {
+
+	// retrieve the poll question
+	question, err := server.db.GetQuestionByPollID(inviteLink.PollID)
+	if err != nil {
+		return ERR_INT_DB_GET, err
+	}
+
+	// add the user and use the link in the same transaction
+	errCode, err := server.addParticipant(inviteLink.PollID, user,
+		question.Title, tag, func(tx *gorp.Transaction) (int, error) {
+			ok, err := database.UseInviteLinkTX(inviteLink.ID, tx)
+			if err != nil {
+				return ERR_INT_DB_UPDATE, err
+			} else if !ok {
+				return ERR_ILL_INVITE_USED_UP, nil
+			}
+
+			return NO_ERR, nil
+		})
+	if errCode != NO_ERR {
+		return errCode, err
+	}
+
+	// notify the poll participants
+	err = server.pushClient.NotifyForJoinedPoll(&server.db, user,
+		inviteLink.PollID, question.Title)
+	if err != nil {
+		// TODO neaten up
+		server.logger.Log(tag, "Error notifying: "+err.Error(), "::1")
+	}
+
+	return NO_ERR, nil
+}
+
+/* Creates a new random invite token signed with the given secret. */
+func newInviteToken(secret []byte) string {
+	random := uniuri.NewLen(cInviteTokenRandomLength)
+	return random + cInviteTokenSeparator + signInviteToken(secret, random)
+}
+
+func signInviteToken(secret []byte, random string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(random))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func isValidInviteToken(secret []byte, token string) bool {
+	parts := strings.SplitN(token, cInviteTokenSeparator, 2)
+	if len(parts) != 2 {
+		return false
+	}
+
+	signature := signInviteToken(secret, parts[0])
+	return hmac.Equal([]byte(parts[1]), []byte(signature))
+}
@@ -1,10 +1,17 @@
 package http
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/roxot/polly"
 	"net/http"
+	"net/url"
 	"strconv"
+	"time"
+
+	"github.com/roxot/polly/database"
 
 	"github.com/julienschmidt/httprouter"
 )
@@ -13,6 +20,32 @@ const (
 	cListUserPollsTag = "GET/LIST_POLLS"
 )
 
+var vPollStatuses = map[string]int{
+	"":       database.POLL_STATUS_ALL,
+	"all":    database.POLL_STATUS_ALL,
+	"open":   database.POLL_STATUS_OPEN,
+	"closed": database.POLL_STATUS_CLOSED,
+}
+
+var vPollSorts = map[string]int{
+	"":              database.POLL_SORT_LAST_UPDATED,
+	"last_updated":  database.POLL_SORT_LAST_UPDATED,
+	"closing_date":  database.POLL_SORT_CLOSING_DATE,
+	"creation_date": database.POLL_SORT_CREATION_DATE,
+}
+
+/*
+ * Lists the polls of the user. The polls can be filtered on their status
+ * (open or closed), on being created by the user, on not having the user's
+ * vote yet, on having events the user hasn't seen and on closing within
+ * cClosingSoonWindow, and sorted on their last update, closing date or
+ * creation date. The next page is fetched by passing the returned next_cursor
+ * along with the same filters. Pages by number are still supported for older
+ * clients, but shift when polls are updated. Besides the page, the number of
+ * polls with unseen events is returned.
+ */
+// GET /v0.1/list_polls.json?cursor=&page=&page_size=&status=&created_by_me=
+//     &not_voted=&unread=&closing_soon=&sort=
 func (server *sServer) ListPolls(writer http.ResponseWriter,
 	request *http.Request, _ httprouter.Params) {
 	var err error
@@ -33,7 +66,7 @@ func (server *sServer) ListPolls(writer http.ResponseWriter,
 		// convert the page argument to an integer
 		pageStr := pageStrings[0]
 		page, err = strconv.Atoi(pageStr)
-		if err != nil {
+		if err != nil || page < 1 {
 			server.respondWithError(ERR_BAD_PAGE, err, cListUserPollsTag,
 				writer, request)
 			return
@@ -43,10 +76,41 @@ func (server *sServer) ListPolls(writer http.ResponseWriter,
 		page = 1
 	}
 
+	// retrieve the page size argument
+	pageSize := cPollListMax
+	pageSizeStr := request.URL.Query().Get(cPageSize)
+	if len(pageSizeStr) > 0 {
+		pageSize, err = strconv.Atoi(pageSizeStr)
+		if err != nil || pageSize < 1 || pageSize > cPollPageSizeMax {
+			server.respondWithError(ERR_BAD_PAGE_SIZE, err, cListUserPollsTag,
+				writer, request)
+			return
+		}
+	}
+
+	// retrieve the filter arguments
+	filter, err := parsePollFilter(request.URL.Query())
+	if err != nil {
+		server.respondWithError(ERR_BAD_POLL_FILTER, err, cListUserPollsTag,
+			writer, request)
+		return
+	}
+
+	// retrieve the cursor argument, which takes the place of the page
+	var cursor *database.PollCursor
+	if cursorStr := request.URL.Query().Get(cCursor); len(cursorStr) > 0 {
+		cursor, err = decodePollCursor(cursorStr, filter.Sort)
+		if err != nil || len(pageStrings) > 0 {
+			server.respondWithError(ERR_BAD_CURSOR, err, cListUserPollsTag,
+				writer, request)
+			return
+		}
+	}
+
 	// retrieve poll snapshots
-	offset := (page - 1) * cPollListMax
-	snapshots, err := server.db.GetPollSnapshotsByUserID(user.ID, cPollListMax,
-		offset)
+	offset := (page - 1) * pageSize
+	pollPage, err := server.db.GetPollSnapshotsByUserID(user.ID, filter,
+		cursor, pageSize, offset)
 	if err != nil {
 		server.respondWithError(ERR_INT_DB_GET, err, cListUserPollsTag, writer,
 			request)
@@ -55,11 +119,25 @@ func (server *sServer) ListPolls(writer http.ResponseWriter,
 
 	// construct the PollList object
 	pollListMsg := polly.PollListMessage{}
-	pollListMsg.Snapshots = snapshots
+	pollListMsg.Snapshots = pollPage.Snapshots
+	if pollListMsg.Snapshots == nil {
+		pollListMsg.Snapshots = []polly.PollSnapshot{}
+	}
+
 	pollListMsg.Page = page
-	pollListMsg.PageSize = cPollListMax
-	pollListMsg.NumResults = len(snapshots)
-	pollListMsg.Total = server.db.CountPollsForUser(user.ID)
+	pollListMsg.PageSize = pageSize
+	pollListMsg.NumResults = len(pollPage.Snapshots)
+	pollListMsg.Total = pollPage.Total
+	if pollPage.Next != nil {
+		pollListMsg.NextCursor = encodePollCursor(pollPage.Next)
+	}
+
+	pollListMsg.Unread, err = server.db.CountUnreadPollsForUser(user.ID)
+	if err != nil {
+		server.respondWithError(ERR_INT_DB_GET, err, cListUserPollsTag, writer,
+			request)
+		return
+	}
 
 	// marshall the response
 	responseBody, err := json.MarshalIndent(pollListMsg, "", "\t")
@@ -77,3 +155,82 @@ func (server *sServer) ListPolls(writer http.ResponseWriter,
 		return
 	}
 }
+
+/* Converts the filter and sort arguments of a poll listing. */
+func parsePollFilter(query url.Values) (*database.PollFilter, error) {
+	now := time.Now()
+	filter := database.PollFilter{}
+	filter.Now = now.UnixNano() / 1000000
+
+	var ok bool
+	filter.Status, ok = vPollStatuses[query.Get(cStatus)]
+	if !ok {
+		return nil, fmt.Errorf("unknown status %q", query.Get(cStatus))
+	}
+
+	filter.Sort, ok = vPollSorts[query.Get(cSort)]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort order %q", query.Get(cSort))
+	}
+
+	var err error
+	for argument, value := range map[string]*bool{
+		cCreatedByMe: &filter.CreatedByMe,
+		cNotVoted:    &filter.NotVoted,
+		cUnread:      &filter.Unread,
+	} {
+		if len(query.Get(argument)) > 0 {
+			*value, err = strconv.ParseBool(query.Get(argument))
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// polls closing soon are open polls closing within the window
+	if len(query.Get(cClosingSoon)) > 0 {
+		closingSoon, err := strconv.ParseBool(query.Get(cClosingSoon))
+		if err != nil {
+			return nil, err
+		} else if closingSoon {
+			if filter.Status == database.POLL_STATUS_CLOSED {
+				return nil, errors.New("closed polls can't be closing soon")
+			}
+
+			filter.Status = database.POLL_STATUS_OPEN
+			filter.ClosingBefore = now.Add(cClosingSoonWindow).UnixNano() /
+				1000000
+		}
+	}
+
+	return &filter, nil
+}
+
+/*
+ * Cursors are opaque to clients, they only have to hand them back. A cursor
+ * holds the sort order it was created for, so it can't be mixed with another.
+ */
+func encodePollCursor(cursor *database.PollCursor) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(
+		"%d:%d:%d", cursor.Sort, cursor.Value, cursor.ID)))
+}
+
+func decodePollCursor(cursorStr string, sort int) (*database.PollCursor,
+	error) {
+
+	data, err := base64.RawURLEncoding.DecodeString(cursorStr)
+	if err != nil {
+		return nil, err
+	}
+
+	cursor := database.PollCursor{}
+	_, err = fmt.Sscanf(string(data), "%d:%d:%d", &cursor.Sort, &cursor.Value,
+		&cursor.ID)
+	if err != nil {
+		return nil, err
+	} else if cursor.Sort != sort {
+		return nil, errors.New("cursor of another sort order")
+	}
+
+	return &cursor, nil
+}
@@ -0,0 +1,103 @@
+package http
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/roxot/polly"
+)
+
+const (
+	cEarthRadius         = 6371000.0 // in meters
+	cCoordinateSeparator = ","
+)
+
+/* Sorts options by their distance, options without a location come last. */
+type tOptionsByDistance []polly.Option
+
+func (options tOptionsByDistance) Len() int {
+	return len(options)
+}
+
+func (options tOptionsByDistance) Swap(i, j int) {
+	options[i], options[j] = options[j], options[i]
+}
+
+func (options tOptionsByDistance) Less(i, j int) bool {
+	if options[j].Distance == nil {
+		return options[i].Distance != nil
+	} else if options[i].Distance == nil {
+		return false
+	}
+
+	return *options[i].Distance < *options[j].Distance
+}
+
+/* Returns whether the given coordinates are present and on the map. */
+func isValidLocation(latitude, longitude *float64) bool {
+	return latitude != nil && longitude != nil &&
+		*latitude >= -90 && *latitude <= 90 &&
+		*longitude >= -180 && *longitude <= 180
+}
+
+/* Parses a point formatted as "latitude,longitude". */
+func parseLocation(point string) (float64, float64, error) {
+	coordinates := strings.Split(point, cCoordinateSeparator)
+	if len(coordinates) != 2 {
+		return 0, 0, errors.New("Expected latitude,longitude.")
+	}
+
+	latitude, err := strconv.ParseFloat(strings.TrimSpace(coordinates[0]), 64)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	longitude, err := strconv.ParseFloat(strings.TrimSpace(coordinates[1]),
+		64)
+	if err != nil {
+		return 0, 0, err
+	} else if !isValidLocation(&latitude, &longitude) {
+		return 0, 0, errors.New("Coordinates out of range.")
+	}
+
+	return latitude, longitude, nil
+}
+
+/*
+ * Sorts the given options by their distance in meters from the given point,
+ * which is set on the options as well. The order of options at the same
+ * distance is kept.
+ */
+func sortOptionsByDistance(options []polly.Option, latitude,
+	longitude float64) {
+
+	for idx := range options {
+		option := &options[idx]
+		if !isValidLocation(option.Latitude, option.Longitude) {
+			continue
+		}
+
+		distance := haversineDistance(latitude, longitude, *option.Latitude,
+			*option.Longitude)
+		option.Distance = &distance
+	}
+
+	sort.Stable(tOptionsByDistance(options))
+}
+
+/* Returns the great-circle distance between two points in meters. */
+func haversineDistance(latitude1, longitude1, latitude2,
+	longitude2 float64) float64 {
+
+	toRadians := math.Pi / 180
+	deltaLatitude := (latitude2 - latitude1) * toRadians
+	deltaLongitude := (longitude2 - longitude1) * toRadians
+	a := math.Pow(math.Sin(deltaLatitude/2), 2) +
+		math.Cos(latitude1*toRadians)*math.Cos(latitude2*toRadians)*
+			math.Pow(math.Sin(deltaLongitude/2), 2)
+
+	return 2 * cEarthRadius * math.Asin(math.Min(1, math.Sqrt(a)))
+}
@@ -0,0 +1,149 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/roxot/polly"
+	"github.com/roxot/polly/movie"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	cSearchMoviesTag = "GET/MOVIES"
+)
+
+// GET /v0.1/movies.json?query=
+func (server *sServer) SearchMovies(writer http.ResponseWriter,
+	request *http.Request, _ httprouter.Params) {
+
+	// authenticate the user
+	_, errCode := server.authenticateRequest(request)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cSearchMoviesTag, writer, request)
+		return
+	}
+
+	// retrieve the search query
+	queries := request.URL.Query()[cQuery]
+	if len(queries) == 0 || len(strings.TrimSpace(queries[0])) == 0 {
+		server.respondWithError(ERR_BAD_NO_QUERY, nil, cSearchMoviesTag,
+			writer, request)
+		return
+	}
+
+	// search the movies
+	searchMsg := polly.MovieSearchMessage{}
+	var err error
+	searchMsg.Movies, err = server.movieProvider.Search(
+		strings.TrimSpace(queries[0]))
+	if err != nil {
+		server.respondWithError(ERR_INT_MOVIE_PROVIDER, err, cSearchMoviesTag,
+			writer, request)
+		return
+	}
+
+	// marshall the response
+	responseBody, err := json.MarshalIndent(searchMsg, "", "\t")
+	if err != nil {
+		server.respondWithError(ERR_INT_MARSHALL, err, cSearchMoviesTag, writer,
+			request)
+		return
+	}
+
+	// send the response
+	err = server.respondWithJSONBody(writer, responseBody)
+	if err != nil {
+		server.respondWithError(ERR_INT_WRITE, err, cSearchMoviesTag, writer,
+			request)
+	}
+}
+
+/* Returns whether options of the given question type describe movies. */
+func isMovieQuestion(questionType int) bool {
+	return questionType == polly.QUESTION_TYPE_MOVIE_MC ||
+		questionType == polly.QUESTION_TYPE_MOVIE_OPEN
+}
+
+/*
+ * Fills in the metadata of a movie option from the provider. Options without
+ * a movie identifier, such as the ones added from the web pages, are matched
+ * by searching for their value. The value of the option becomes the title of
+ * the movie. Returns an API error code and the underlying error.
+ */
+func (server *sServer) resolveMovieOption(option *polly.Option) (int,
+	error) {
+
+	movieID := option.MovieID
+	if len(movieID) == 0 {
+		movies, err := server.movieProvider.Search(option.Value)
+		if err != nil {
+			return ERR_INT_MOVIE_PROVIDER, err
+		} else if len(movies) == 0 {
+			return ERR_BAD_NO_MOVIE, nil
+		}
+
+		movieID = movies[0].ID
+	}
+
+	foundMovie, err := server.movieProvider.Lookup(movieID)
+	if err == movie.ErrNotFound {
+		return ERR_BAD_NO_MOVIE, nil
+	} else if err != nil {
+		return ERR_INT_MOVIE_PROVIDER, err
+	}
+
+	option.Value = foundMovie.Title
+	option.MovieID = foundMovie.ID
+	option.MovieTitle = foundMovie.Title
+	option.MovieYear = foundMovie.Year
+	option.PosterURL = foundMovie.PosterURL
+	option.Runtime = foundMovie.Runtime
+	return NO_ERR, nil
+}
+
+/*
+ * Fills in the metadata of a movie option of a new poll like
+ * resolveMovieOption. Options the provider can't match, or can't be reached
+ * for, keep their value as an unresolved title, so the poll can still be
+ * created. The tag is used for logging.
+ */
+func (server *sServer) resolveNewMovieOption(option *polly.Option,
+	tag string) {
+
+	errCode, err := server.resolveMovieOption(option)
+	if errCode == NO_ERR {
+		return
+	}
+
+	server.logger.Log(tag, fmt.Sprintf("Movie %q left unresolved (%d): %v",
+		option.Value, errCode, err), "::1")
+	option.MovieID = ""
+	option.MovieTitle = ""
+	option.MovieYear = 0
+	option.PosterURL = ""
+	option.Runtime = 0
+}
+
+/* Fills in the metadata of the movie options of a new template. */
+func (server *sServer) resolveTemplateMovieOptions(
+	templateMsg *polly.PollTemplateMessage, tag string) {
+
+	for idx := range templateMsg.Options {
+		templateOption := &templateMsg.Options[idx]
+		option := polly.Option{}
+		option.Value = templateOption.Value
+		option.MovieID = templateOption.MovieID
+		server.resolveNewMovieOption(&option, tag)
+
+		templateOption.Value = option.Value
+		templateOption.MovieID = option.MovieID
+		templateOption.MovieTitle = option.MovieTitle
+		templateOption.MovieYear = option.MovieYear
+		templateOption.PosterURL = option.PosterURL
+		templateOption.Runtime = option.Runtime
+	}
+}
@@ -0,0 +1,241 @@
+package http
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/roxot/polly"
+	"github.com/roxot/polly/database"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/lib/pq"
+	"gopkg.in/gorp.v1"
+)
+
+const (
+	cRemoveParticipantTag = "DELETE/PARTICIPANT"
+)
+
+/*
+ * Performs additional work in the transaction that adds a participant. Returns
+ * an API error code and the underlying error.
+ */
+type fParticipantTXHook func(tx *gorp.Transaction) (int, error)
+
+/*
+ * Adds the given user as a participant to the given poll and updates the poll
+ * its last updated and sequence number accordingly. The tag is used for logging
+ * serialization failures, the optional hook is run before committing. Returns
+ * an API error code and the underlying error.
+ */
+func (server *sServer) addParticipant(pollID int64,
+	newUser *polly.PrivateUser, pollTitle, tag string,
+	hook fParticipantTXHook) (int, error) {
+
+	currentTime := time.Now().UnixNano() / 1000000
+	transactionNumber := rand.Int()
+	for {
+
+		// start a transaction
+		tx, err := server.db.Begin()
+		if err != nil {
+			tx.Rollback()
+			return ERR_INT_DB_TX_BEGIN, err
+		}
+
+		// set the transaction isolation level
+		_, err = tx.Exec("set transaction isolation level serializable;")
+		if err != nil {
+			tx.Rollback()
+			return ERR_INT_DB_TX_SET_TX_LEVEL, err
+		}
+
+		// update the poll last updated and seq number
+		err = database.UpdatePollTX(pollID, currentTime,
+			polly.EVENT_TYPE_NEW_PARTICIPANT, newUser.DisplayName, newUser.ID,
+			pollTitle, newUser.ID, tx)
+		if err != nil {
+			tx.Rollback()
+			if pqErr, ok := err.(*pq.Error); ok &&
+				pqErr.Code == database.ERR_SERIALIZATION_FAILURE {
+				server.logger.Log(tag, fmt.Sprintf("%d: %s",
+					transactionNumber, "Serialization failure, retrying..."),
+					"::1")
+				continue
+			}
+
+			return ERR_INT_DB_UPDATE, err
+		}
+
+		// make sure the user is not already in the poll
+		isParticipant, err := database.ExistsParticipantTX(newUser.ID, pollID,
+			tx)
+		if err != nil {
+			tx.Rollback()
+			return ERR_INT_DB_GET, err
+		} else if isParticipant {
+			tx.Rollback()
+			return ERR_BAD_DUPLICATE_PARTICIPANT, nil
+		}
+
+		// add the user to the poll
+		newParticipant := &polly.Participant{PollID: pollID,
+			UserID: newUser.ID}
+		err = database.AddParticipantTX(newParticipant, tx)
+		if err != nil {
+			tx.Rollback()
+			if pqErr, ok := err.(*pq.Error); ok &&
+				pqErr.Code == database.ERR_SERIALIZATION_FAILURE {
+				server.logger.Log(tag, fmt.Sprintf("%d: %s",
+					transactionNumber, "Serialization failure, retrying..."),
+					"::1")
+				continue
+			}
+
+			return ERR_INT_DB_ADD, err
+		}
+
+		// run the additional work
+		if hook != nil {
+			errCode, err := hook(tx)
+			if errCode != NO_ERR {
+				tx.Rollback()
+				if pqErr, ok := err.(*pq.Error); ok &&
+					pqErr.Code == database.ERR_SERIALIZATION_FAILURE {
+					server.logger.Log(tag, fmt.Sprintf("%d: %s",
+						transactionNumber,
+						"Serialization failure, retrying..."), "::1")
+					continue
+				}
+
+				return errCode, err
+			}
+		}
+
+		// commit the transaction
+		err = tx.Commit()
+		if err != nil {
+			tx.Rollback()
+			return ERR_INT_DB_TX_COMMIT, err
+		}
+
+		return NO_ERR, nil
+	}
+}
+
+// DELETE /v0.1/participant.json
+func (server *sServer) RemoveParticipant(writer http.ResponseWriter,
+	request *http.Request, _ httprouter.Params) {
+
+	// authenticate the user
+	user, errCode := server.authenticateRequest(request)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cRemoveParticipantTag, writer,
+			request)
+		return
+	}
+
+	// parse the provided poll and user ids to integers
+	query := request.URL.Query()
+	if len(query.Get(cPollID)) == 0 || len(query.Get(cUserID)) == 0 {
+		server.respondWithError(ERR_BAD_NO_ID, nil, cRemoveParticipantTag,
+			writer, request)
+		return
+	}
+
+	pollID, err := strconv.ParseInt(query.Get(cPollID), 10, 64)
+	if err != nil {
+		server.respondWithError(ERR_BAD_ID, err, cRemoveParticipantTag, writer,
+			request)
+		return
+	}
+
+	removedUserID, err := strconv.ParseInt(query.Get(cUserID), 10, 64)
+	if err != nil {
+		server.respondWithError(ERR_BAD_ID, err, cRemoveParticipantTag, writer,
+			request)
+		return
+	}
+
+	// retrieve the poll and make sure the user may remove participants
+	poll, question, errCode, err := server.getPollForAction(user, pollID,
+		cPermissionManageParticipants)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, err, cRemoveParticipantTag, writer,
+			request)
+		return
+	}
+
+	// make sure the poll hasn't closed yet
+	currentTime := time.Now().UnixNano() / 1000000
+	if currentTime > poll.ClosingDate {
+		server.respondWithError(ERR_ILL_POLL_CLOSED, nil, cRemoveParticipantTag,
+			writer, request)
+		return
+	}
+
+	// retrieve the participant to remove
+	participant, err := server.db.GetParticipant(removedUserID, pollID)
+	if err != nil {
+		server.respondWithError(ERR_BAD_NO_PARTICIPANT, err,
+			cRemoveParticipantTag, writer, request)
+		return
+	}
+
+	removedUser, err := server.db.GetUserByID(removedUserID)
+	if err != nil {
+		server.respondWithError(ERR_INT_DB_GET, err, cRemoveParticipantTag,
+			writer, request)
+		return
+	}
+
+	// only the owner can remove admins, nobody can remove the owner or
+	// themselves, leaving is done through LeavePoll
+	userParticipant, _ := server.db.GetParticipant(user.ID, pollID)
+	if participant.Role >= userParticipant.Role {
+		server.respondWithError(ERR_ILL_ROLE, nil, cRemoveParticipantTag,
+			writer, request)
+		return
+	}
+
+	// remove the participant and his or her votes, guests are removed entirely
+	errCode, err = server.updatePollByUser(pollID,
+		polly.EVENT_TYPE_PARTICIPANT_LEFT, removedUser, question.Title,
+		cRemoveParticipantTag, func(tx *gorp.Transaction) (int, error) {
+			err := database.DeleteVotesForUserTX(removedUser.ID, pollID, tx)
+			if err == nil {
+				err = database.DeleteParticipantTX(removedUser.ID, pollID, tx)
+			}
+			if err == nil && removedUser.GuestPollID != 0 {
+				err = database.DeleteWebSessionsForUserTX(removedUser.ID, tx)
+				if err == nil {
+					err = database.DeleteUserTX(removedUser.ID, tx)
+				}
+			}
+			if err != nil {
+				return ERR_INT_DB_DELETE, err
+			}
+
+			return NO_ERR, nil
+		})
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, err, cRemoveParticipantTag, writer,
+			request)
+		return
+	}
+
+	// notify the removed user and the remaining participants
+	err = server.pushClient.NotifyForRemovedParticipant(&server.db, user,
+		pollID, question.Title, removedUser)
+	if err != nil {
+		// TODO neaten up
+		server.logger.Log(cRemoveParticipantTag, "Error notifying: "+
+			err.Error(), "::1")
+	}
+
+	// respond with 200 ok
+	server.respondOkay(writer, request)
+}
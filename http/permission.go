@@ -0,0 +1,68 @@
+package http
+
+import (
+	"github.com/roxot/polly"
+)
+
+const (
+	cPermissionView = iota
+	cPermissionVote
+	cPermissionManageParticipants
+	cPermissionClose
+	cPermissionEdit
+	cPermissionManageRoles
+	cPermissionNudge
+	cPermissionComment
+	cPermissionModerateComments
+	cPermissionReact
+)
+
+/* The actions each participant role is allowed to perform within a poll. */
+var vRolePermissions = map[int]map[int]bool{
+	polly.PARTICIPANT_ROLE_VIEWER: {
+		cPermissionView: true,
+	},
+	polly.PARTICIPANT_ROLE_VOTER: {
+		cPermissionView:    true,
+		cPermissionVote:    true,
+		cPermissionComment: true,
+		cPermissionReact:   true,
+	},
+	polly.PARTICIPANT_ROLE_ADMIN: {
+		cPermissionView:               true,
+		cPermissionVote:               true,
+		cPermissionManageParticipants: true,
+		cPermissionClose:              true,
+		cPermissionComment:            true,
+		cPermissionReact:              true,
+	},
+	polly.PARTICIPANT_ROLE_OWNER: {
+		cPermissionView:               true,
+		cPermissionVote:               true,
+		cPermissionManageParticipants: true,
+		cPermissionClose:              true,
+		cPermissionEdit:               true,
+		cPermissionManageRoles:        true,
+		cPermissionNudge:              true,
+		cPermissionComment:            true,
+		cPermissionModerateComments:   true,
+		cPermissionReact:              true,
+	},
+}
+
+/*
+ * Makes sure the given user participates in the given poll with a role that
+ * grants the given permission. Returns the participant and an API error code.
+ */
+func (server *sServer) authorizePollAction(userID, pollID int64,
+	permission int) (*polly.Participant, int) {
+
+	participant, err := server.db.GetParticipant(userID, pollID)
+	if err != nil {
+		return nil, ERR_ILL_POLL_ACCESS
+	} else if !vRolePermissions[participant.Role][permission] {
+		return nil, ERR_ILL_ROLE
+	}
+
+	return participant, NO_ERR
+}
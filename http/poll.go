@@ -13,6 +13,7 @@ import (
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/lib/pq"
+	"gopkg.in/gorp.v1"
 )
 
 const (
@@ -42,11 +43,49 @@ func (server *sServer) PostPoll(writer http.ResponseWriter, request *http.Reques
 		return
 	}
 
+	// create the poll
+	errCode, err = server.createPoll(user, &pollMsg, cPostPollTag)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, err, cPostPollTag, writer, request)
+		return
+	}
+
+	// marshall the response
+	responseBody, err := json.MarshalIndent(pollMsg, "", "\t")
+	if err != nil {
+		server.respondWithError(ERR_INT_MARSHALL, err, cPostPollTag, writer,
+			request)
+		return
+	}
+
+	// send the response
+	err = server.respondWithJSONBody(writer, responseBody)
+	if err != nil {
+		server.respondWithError(ERR_INT_WRITE, err, cPostPollTag, writer,
+			request)
+	}
+}
+
+/*
+ * Validates and inserts the given poll message on behalf of the given user
+ * along with the closing of the poll and its reminders, and notifies the
+ * participants. The tag is used for logging. Returns an API error code and the
+ * underlying error.
+ */
+func (server *sServer) createPoll(user *polly.PrivateUser,
+	pollMsg *polly.PollMessage, tag string) (int, error) {
+
 	// validate the poll
-	if errCode = isValidPollMessage(&server.db, &pollMsg, user.ID); errCode !=
+	if errCode := isValidPollMessage(&server.db, pollMsg, user.ID); errCode !=
 		NO_ERR {
-		server.respondWithError(errCode, nil, cPostPollTag, writer, request)
-		return
+		return errCode, nil
+	}
+
+	// fill in the metadata of the movies
+	if isMovieQuestion(pollMsg.Question.Type) {
+		for idx := range pollMsg.Options {
+			server.resolveNewMovieOption(&pollMsg.Options[idx], tag)
+		}
 	}
 
 	// insert poll
@@ -56,11 +95,34 @@ func (server *sServer) PostPoll(writer http.ResponseWriter, request *http.Reques
 	pollMsg.MetaData.LastEventUserID = user.ID
 	pollMsg.MetaData.LastEventTitle = pollMsg.Question.Title
 	pollMsg.Votes = make([]polly.Vote, 0)
-	err = server.db.InsertPollMessage(&pollMsg)
-	if err != nil {
-		server.respondWithError(ERR_INT_DB_ADD, err, cPostPollTag, writer,
-			request)
-		return
+
+	// schedule the closing and the reminders along with inserting the poll
+	schedulerErrCode := NO_ERR
+	err := server.db.InsertPollMessage(pollMsg,
+		func(tx *gorp.Transaction) error {
+			pollID := pollMsg.MetaData.ID
+			closingDate := pollMsg.MetaData.ClosingDate
+			err := server.scheduleClosePollTX(pollID, pollMsg.Question.Title,
+				closingDate, tx)
+			if err != nil {
+				schedulerErrCode = ERR_INT_CP_SCHEDULER
+				return err
+			}
+
+			err = server.scheduleRemindersTX(pollID, closingDate, tx)
+			if err != nil {
+				schedulerErrCode = ERR_INT_REMINDER_SCHEDULER
+				return err
+			}
+
+			return nil
+		})
+	if schedulerErrCode != NO_ERR {
+		return schedulerErrCode, err
+	} else if err == database.ErrImageAttached {
+		return ERR_BAD_OPTION_IMAGE, err
+	} else if err != nil {
+		return ERR_INT_DB_ADD, err
 	}
 
 	// notify the poll participants of the creation of the poll
@@ -68,33 +130,10 @@ func (server *sServer) PostPoll(writer http.ResponseWriter, request *http.Reques
 		pollMsg.MetaData.ID, pollMsg.Question.Title)
 	if err != nil {
 		// TODO neaten up
-		server.logger.Log(cPostPollTag, "Error notifying: "+err.Error(), "::1")
-	}
-
-	// schedule the closing of the poll
-	closingDate := time.Unix(0, 1000000*pollMsg.MetaData.ClosingDate)
-	pollToClose := tPollToClose{pollMsg.MetaData.ID, pollMsg.Question.Title}
-	_, err = server.cpScheduler.Schedule(0, closingDate, &pollToClose)
-	if err != nil {
-		server.respondWithError(ERR_INT_CP_SCHEDULER, err, cPostPollTag, writer,
-			request)
-		return
+		server.logger.Log(tag, "Error notifying: "+err.Error(), "::1")
 	}
 
-	// marshall the response
-	responseBody, err := json.MarshalIndent(pollMsg, "", "\t")
-	if err != nil {
-		server.respondWithError(ERR_INT_MARSHALL, err, cPostPollTag, writer,
-			request)
-		return
-	}
-
-	// send the response
-	err = server.respondWithJSONBody(writer, responseBody)
-	if err != nil {
-		server.respondWithError(ERR_INT_WRITE, err, cPostPollTag, writer,
-			request)
-	}
+	return NO_ERR, nil
 }
 
 func (server *sServer) GetPollBulk(writer http.ResponseWriter,
@@ -115,6 +154,21 @@ func (server *sServer) GetPollBulk(writer http.ResponseWriter,
 		return
 	}
 
+	// the options of location polls can be sorted by their distance to a point
+	var near bool
+	var latitude, longitude float64
+	if nearStrings := request.URL.Query()[cNear]; len(nearStrings) > 0 {
+		var err error
+		latitude, longitude, err = parseLocation(nearStrings[0])
+		if err != nil {
+			server.respondWithError(ERR_BAD_LOCATION, err, cGetPollBulkTag,
+				writer, request)
+			return
+		}
+
+		near = true
+	}
+
 	// construct the PollBulk object
 	pollBulkMsg := polly.PollBulkMessage{}
 	pollBulkMsg.Polls = make([]polly.PollMessage, len(ids))
@@ -136,13 +190,25 @@ func (server *sServer) GetPollBulk(writer http.ResponseWriter,
 		}
 
 		// construct the poll message
-		pollMsg, err := server.db.ConstructPollMessage(id)
+		pollMsg, err := server.db.ConstructPollMessage(id, user.ID)
 		if err != nil {
 			server.respondWithError(ERR_BAD_NO_POLL, err, cGetPollBulkTag,
 				writer, request)
 			return
 		}
 
+		if near && pollMsg.Question.Type == polly.QUESTION_TYPE_LOCATION {
+			sortOptionsByDistance(pollMsg.Options, latitude, longitude)
+		}
+
+		// the user has now seen the poll as returned
+		_, err = server.db.MarkPollSeen(user.ID, id,
+			pollMsg.MetaData.SequenceNumber)
+		if err != nil {
+			server.logger.Log(cGetPollBulkTag, fmt.Sprintf(
+				"Error marking poll %d as seen: %s", id, err), "::1")
+		}
+
 		pollBulkMsg.Polls[idx] = *pollMsg
 	}
 
@@ -237,7 +303,7 @@ func (server *sServer) LeavePoll(writer http.ResponseWriter,
 		// update the poll last updated and seq number
 		err = database.UpdatePollTX(pollID, currentTime,
 			polly.EVENT_TYPE_PARTICIPANT_LEFT, user.DisplayName, user.ID,
-			question.Title, tx)
+			question.Title, user.ID, tx)
 		if err != nil {
 			if pqErr, ok := err.(*pq.Error); ok &&
 				pqErr.Code == database.ERR_SERIALIZATION_FAILURE {
@@ -270,6 +336,23 @@ func (server *sServer) LeavePoll(writer http.ResponseWriter,
 			}
 		}
 
+		// hand the poll over to another participant when its owner leaves
+		err = handOverPollTX(user.ID, pollID, tx)
+		if err != nil {
+			tx.Rollback()
+			if pqErr, ok := err.(*pq.Error); ok &&
+				pqErr.Code == database.ERR_SERIALIZATION_FAILURE {
+				server.logger.Log(cLeavePollTag, fmt.Sprintf("%d: %s",
+					transactionNumber, "Serialization failure, retrying..."),
+					"::1")
+				continue
+			}
+
+			server.respondWithError(ERR_INT_DB_UPDATE, err, cLeavePollTag,
+				writer, request)
+			return
+		}
+
 		// delete the participant from the poll
 		err = server.db.DeleteParticipant(user.ID, pollID)
 		if err != nil { // TODO what if internal?
@@ -0,0 +1,399 @@
+package http
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/roxot/polly"
+	"github.com/roxot/polly/cron"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	cPostTemplateTag   = "POST/TEMPLATE"
+	cGetTemplatesTag   = "GET/TEMPLATES"
+	cDeleteTemplateTag = "DELETE/TEMPLATE"
+	cPollTemplateEvent = "poll_template_event"
+)
+
+type tTemplateToRun struct {
+	ID      int64
+	RunDate int64
+}
+
+func (server *sServer) PostTemplate(writer http.ResponseWriter,
+	request *http.Request, _ httprouter.Params) {
+
+	// authenticate the user
+	user, errCode := server.authenticateRequest(request)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cPostTemplateTag, writer, request)
+		return
+	}
+
+	// decode the template
+	var templateMsg polly.PollTemplateMessage
+	decoder := json.NewDecoder(request.Body)
+	err := decoder.Decode(&templateMsg)
+	if err != nil {
+		server.respondWithError(ERR_BAD_JSON, err, cPostTemplateTag, writer,
+			request)
+		return
+	}
+
+	// validate the template
+	errCode = isValidPollTemplateMessage(&server.db, &templateMsg, user.ID)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cPostTemplateTag, writer, request)
+		return
+	}
+
+	// fill in the metadata of the movies
+	if isMovieQuestion(templateMsg.MetaData.QuestionType) {
+		server.resolveTemplateMovieOptions(&templateMsg, cPostTemplateTag)
+	}
+
+	// insert the template
+	err = server.db.InsertPollTemplateMessage(&templateMsg)
+	if err != nil {
+		server.respondWithError(ERR_INT_DB_ADD, err, cPostTemplateTag, writer,
+			request)
+		return
+	}
+
+	// schedule the first poll
+	err = server.scheduleTemplateRun(&templateMsg.MetaData, time.Now())
+	if err != nil {
+		server.respondWithError(ERR_INT_TEMPLATE_SCHEDULER, err,
+			cPostTemplateTag, writer, request)
+		return
+	}
+
+	// marshall the response
+	responseBody, err := json.MarshalIndent(templateMsg, "", "\t")
+	if err != nil {
+		server.respondWithError(ERR_INT_MARSHALL, err, cPostTemplateTag, writer,
+			request)
+		return
+	}
+
+	// send the response
+	err = server.respondWithJSONBody(writer, responseBody)
+	if err != nil {
+		server.respondWithError(ERR_INT_WRITE, err, cPostTemplateTag, writer,
+			request)
+	}
+}
+
+func (server *sServer) GetTemplates(writer http.ResponseWriter,
+	request *http.Request, _ httprouter.Params) {
+
+	// authenticate the user
+	user, errCode := server.authenticateRequest(request)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cGetTemplatesTag, writer, request)
+		return
+	}
+
+	// retrieve the templates created by the user
+	templates, err := server.db.GetPollTemplatesByCreatorID(user.ID)
+	if err != nil {
+		server.respondWithError(ERR_INT_DB_GET, err, cGetTemplatesTag, writer,
+			request)
+		return
+	}
+
+	// construct the PollTemplateList object
+	templateListMsg := polly.PollTemplateListMessage{}
+	templateListMsg.Templates = make([]polly.PollTemplateMessage,
+		len(templates))
+	for idx, template := range templates {
+		templateMsg, err := server.db.ConstructPollTemplateMessage(template.ID)
+		if err != nil {
+			server.respondWithError(ERR_INT_DB_GET, err, cGetTemplatesTag,
+				writer, request)
+			return
+		}
+
+		templateListMsg.Templates[idx] = *templateMsg
+	}
+
+	// marshall the response
+	responseBody, err := json.MarshalIndent(templateListMsg, "", "\t")
+	if err != nil {
+		server.respondWithError(ERR_INT_MARSHALL, err, cGetTemplatesTag, writer,
+			request)
+		return
+	}
+
+	// send the response
+	err = server.respondWithJSONBody(writer, responseBody)
+	if err != nil {
+		server.respondWithError(ERR_INT_WRITE, err, cGetTemplatesTag, writer,
+			request)
+	}
+}
+
+func (server *sServer) DeleteTemplate(writer http.ResponseWriter,
+	request *http.Request, _ httprouter.Params) {
+
+	// authenticate the user
+	user, errCode := server.authenticateRequest(request)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cDeleteTemplateTag, writer,
+			request)
+		return
+	}
+
+	// convert the id to an integer
+	ids := request.URL.Query()[cID]
+	if len(ids) == 0 {
+		server.respondWithError(ERR_BAD_NO_ID, nil, cDeleteTemplateTag, writer,
+			request)
+		return
+	}
+
+	// parse the provided template id to an integer
+	templateID, err := strconv.ParseInt(ids[0], 10, 64)
+	if err != nil {
+		server.respondWithError(ERR_BAD_ID, err, cDeleteTemplateTag, writer,
+			request)
+		return
+	}
+
+	// make sure the user created the template
+	template, err := server.db.GetPollTemplateByID(templateID)
+	if err != nil {
+		server.respondWithError(ERR_BAD_NO_TEMPLATE, err, cDeleteTemplateTag,
+			writer, request)
+		return
+	} else if template.CreatorID != user.ID {
+		server.respondWithError(ERR_ILL_TEMPLATE_ACCESS, nil,
+			cDeleteTemplateTag, writer, request)
+		return
+	}
+
+	// stop the recurrence, polls created earlier are left untouched
+	err = server.cancelTemplateRun(template)
+	if err != nil {
+		server.respondWithError(ERR_INT_TEMPLATE_SCHEDULER, err,
+			cDeleteTemplateTag, writer, request)
+		return
+	}
+
+	// delete the template
+	err = server.db.DeletePollTemplate(templateID)
+	if err != nil {
+		server.respondWithError(ERR_INT_DB_DELETE, err, cDeleteTemplateTag,
+			writer, request)
+		return
+	}
+
+	// respond with 200 OK
+	server.respondOkay(writer, request)
+}
+
+/*
+ * Instantiates a poll from a template through the same path as PostPoll. The
+ * next run is scheduled first, so a poll that can't be created, for example
+ * because its creator left the group, doesn't end the recurrence.
+ */
+func (server *sServer) RunPollTemplate(templateToRun *tTemplateToRun) error {
+
+	// the template may have been deleted in the meantime
+	templateMsg, err := server.db.ConstructPollTemplateMessage(
+		templateToRun.ID)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	// ignore runs that have been replaced by another one
+	template := &templateMsg.MetaData
+	if template.NextRun != templateToRun.RunDate {
+		return nil
+	}
+
+	// schedule the next run
+	now := time.Now()
+	runDate := time.Unix(0, templateToRun.RunDate*1000000)
+	if runDate.After(now) {
+		now = runDate
+	}
+
+	err = server.scheduleTemplateRun(template, now)
+	if err != nil {
+		server.logger.Log(cPollTemplateEvent, fmt.Sprintf(
+			"Error scheduling template %d: %s", template.ID, err), "::1")
+	}
+
+	// create the poll on behalf of the template's creator
+	creator, err := server.db.GetUserByID(template.CreatorID)
+	if err != nil {
+		return err
+	}
+
+	pollMsg := newPollMessageFromTemplate(templateMsg, time.Now())
+	errCode, err := server.createPoll(creator, pollMsg, cPollTemplateEvent)
+	if errCode != NO_ERR {
+		server.logger.Log(cPollTemplateEvent, fmt.Sprintf(
+			"Error creating poll from template %d (%d): %v", template.ID,
+			errCode, err), "::1")
+	}
+
+	return nil
+}
+
+/* Decodes the template of a template job. */
+func (server *sServer) runPollTemplateJob(data []byte) error {
+	var templateToRun tTemplateToRun
+	err := json.Unmarshal(data, &templateToRun)
+	if err != nil {
+		return err
+	}
+
+	return server.RunPollTemplate(&templateToRun)
+}
+
+/*
+ * Schedules the first run of the given template after the given time and
+ * remembers the job so it can be cancelled later on.
+ */
+func (server *sServer) scheduleTemplateRun(template *polly.PollTemplate,
+	after time.Time) error {
+
+	next, err := nextTemplateRun(template, after)
+	if err != nil {
+		return err
+	}
+
+	templateToRun := tTemplateToRun{template.ID, next.UnixNano() / 1000000}
+	jobID, err := server.scheduler.Schedule(cPollTemplatesJobs, next,
+		&templateToRun)
+	if err != nil {
+		return err
+	}
+
+	template.NextRun = templateToRun.RunDate
+	template.JobID = jobID
+	return server.db.UpdateTemplateSchedule(template.ID, template.NextRun,
+		template.JobID)
+}
+
+/* Cancels the next run of the given template, if it is still pending. */
+func (server *sServer) cancelTemplateRun(template *polly.PollTemplate) error {
+	if len(template.JobID) == 0 {
+		return nil
+	}
+
+	return server.scheduler.Cancel(template.JobID)
+}
+
+/*
+ * Returns the first run of the given template after the given time. Daily and
+ * weekly templates repeat the time of day of their start date in the
+ * template's time zone, cron rules are evaluated in that time zone as well.
+ */
+func nextTemplateRun(template *polly.PollTemplate, after time.Time) (
+	time.Time, error) {
+
+	location, err := time.LoadLocation(template.TimeZone)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	after = after.In(location)
+	if template.Recurrence == polly.RECURRENCE_CRON {
+		schedule, err := cron.Parse(template.CronRule)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		next := schedule.Next(after)
+		if next.IsZero() {
+			return next, errors.New("Cron rule never matches.")
+		}
+
+		return next, nil
+	}
+
+	if template.StartDate <= 0 {
+		return time.Time{}, errors.New("No start date.")
+	}
+
+	days := 1
+	if template.Recurrence == polly.RECURRENCE_WEEKLY {
+		days = 7
+	}
+
+	start := time.Unix(0, template.StartDate*1000000).In(location)
+	if start.After(after) {
+		return start, nil
+	}
+
+	// skip the periods that have passed, adding calendar days keeps the time
+	// of day when daylight saving time starts or ends
+	periods := int(after.Sub(start).Hours()/24)/days - 1
+	if periods < 0 {
+		periods = 0
+	}
+
+	next := start.AddDate(0, 0, periods*days)
+	for !next.After(after) {
+		next = next.AddDate(0, 0, days)
+	}
+
+	return next, nil
+}
+
+/* Creates the poll message for a poll instantiated at the given time. */
+func newPollMessageFromTemplate(templateMsg *polly.PollTemplateMessage,
+	now time.Time) *polly.PollMessage {
+
+	template := &templateMsg.MetaData
+	pollMsg := polly.PollMessage{}
+	pollMsg.MetaData.ClosingDate = now.UnixNano()/1000000 +
+		template.OpenDuration
+	pollMsg.MetaData.GroupID = template.GroupID
+	pollMsg.MetaData.AllowGuests = template.AllowGuests
+	pollMsg.MetaData.Anonymous = template.Anonymous
+	pollMsg.MetaData.ResultsVisibility = template.ResultsVisibility
+	pollMsg.Question.Type = template.QuestionType
+	pollMsg.Question.Title = template.Title
+
+	pollMsg.Options = make([]polly.Option, len(templateMsg.Options))
+	for idx, option := range templateMsg.Options {
+		pollMsg.Options[idx].Value = option.Value
+		pollMsg.Options[idx].MovieID = option.MovieID
+		pollMsg.Options[idx].MovieTitle = option.MovieTitle
+		pollMsg.Options[idx].MovieYear = option.MovieYear
+		pollMsg.Options[idx].PosterURL = option.PosterURL
+		pollMsg.Options[idx].Runtime = option.Runtime
+		pollMsg.Options[idx].Latitude = option.Latitude
+		pollMsg.Options[idx].Longitude = option.Longitude
+		pollMsg.Options[idx].Address = option.Address
+	}
+
+	pollMsg.Participants = make([]polly.PublicUser,
+		len(templateMsg.Participants))
+	copy(pollMsg.Participants, templateMsg.Participants)
+
+	// templates stored with the creator as owner would fail validation, the
+	// creator becomes the owner again once the poll is validated
+	for idx := range pollMsg.Participants {
+		participant := &pollMsg.Participants[idx]
+		if participant.ID == template.CreatorID &&
+			participant.Role == polly.PARTICIPANT_ROLE_OWNER {
+			participant.Role = polly.PARTICIPANT_ROLE_VOTER
+		}
+	}
+
+	return &pollMsg
+}
@@ -0,0 +1,309 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/roxot/polly"
+	"github.com/roxot/polly/database"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/lib/pq"
+	"gopkg.in/gorp.v1"
+)
+
+const (
+	cAddReactionTag    = "POST/REACTION"
+	cRemoveReactionTag = "DELETE/REACTION"
+)
+
+/*
+ * Adds an emoji reaction to an option or a comment. Reactions don't count as
+ * votes and don't notify anyone, but they do bump the poll so that syncing
+ * clients pick them up. Adding a reaction twice has no effect.
+ */
+// POST /v0.1/reaction.json
+func (server *sServer) AddReaction(writer http.ResponseWriter,
+	request *http.Request, _ httprouter.Params) {
+
+	// authenticate the user
+	user, errCode := server.authenticateRequest(request)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cAddReactionTag, writer, request)
+		return
+	}
+
+	// decode the reaction message
+	var reactionMsg polly.ReactionMessage
+	decoder := json.NewDecoder(request.Body)
+	err := decoder.Decode(&reactionMsg)
+	if err != nil {
+		server.respondWithError(ERR_BAD_JSON, err, cAddReactionTag, writer,
+			request)
+		return
+	}
+
+	// resolve the reaction and the poll it belongs to
+	reaction, poll, errCode, err := server.getReactionForAction(user,
+		reactionMsg.OptionID, reactionMsg.CommentID, reactionMsg.Emoji)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, err, cAddReactionTag, writer, request)
+		return
+	}
+
+	// reacting with the same emoji again leaves the poll untouched
+	exists, err := server.db.ExistsReaction(reaction)
+	if err != nil {
+		server.respondWithError(ERR_INT_DB_GET, err, cAddReactionTag, writer,
+			request)
+		return
+	} else if exists {
+		server.respondWithReactions(user, poll.ID, nil, cAddReactionTag,
+			writer, request)
+		return
+	}
+
+	// insert the reaction along with bumping the poll
+	reaction.CreationDate = time.Now().UnixNano() / 1000000
+
+	var snapshot *polly.PollSnapshot
+	duplicate := false
+	errCode, err = server.updatePollByActor(poll.ID, polly.EVENT_TYPE_REACTION,
+		user.ID, reactionEventUser(user, poll), reaction.Emoji, cAddReactionTag,
+		func(tx *gorp.Transaction) (int, error) {
+			reaction.ID = 0
+			err := database.AddReactionTX(reaction, tx)
+			if pqErr, ok := err.(*pq.Error); ok &&
+				pqErr.Code == database.ERR_UNIQUE_VIOLATION {
+
+				// a concurrent request added it, undo bumping the poll
+				duplicate = true
+				return ERR_INT_DB_ADD, nil
+			} else if err != nil {
+				return ERR_INT_DB_ADD, err
+			}
+
+			snapshot, err = database.GetPollSnapshotTX(poll.ID, tx)
+			if err != nil {
+				return ERR_INT_DB_GET, err
+			}
+
+			return NO_ERR, nil
+		})
+	if duplicate {
+		server.respondWithReactions(user, poll.ID, nil, cAddReactionTag,
+			writer, request)
+		return
+	} else if errCode != NO_ERR {
+		server.respondWithError(errCode, err, cAddReactionTag, writer, request)
+		return
+	}
+
+	server.respondWithReactions(user, poll.ID, snapshot, cAddReactionTag,
+		writer, request)
+}
+
+/* Removing a reaction that doesn't exist has no effect. */
+// DELETE /v0.1/reaction.json?option_id=&comment_id=&emoji=
+func (server *sServer) RemoveReaction(writer http.ResponseWriter,
+	request *http.Request, _ httprouter.Params) {
+
+	// authenticate the user
+	user, errCode := server.authenticateRequest(request)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cRemoveReactionTag, writer,
+			request)
+		return
+	}
+
+	// parse the target of the reaction
+	query := request.URL.Query()
+	var optionID, commentID int64
+	var err error
+	if len(query.Get(cOptionID)) > 0 {
+		optionID, err = strconv.ParseInt(query.Get(cOptionID), 10, 64)
+	}
+	if err == nil && len(query.Get(cCommentID)) > 0 {
+		commentID, err = strconv.ParseInt(query.Get(cCommentID), 10, 64)
+	}
+	if err != nil {
+		server.respondWithError(ERR_BAD_ID, err, cRemoveReactionTag, writer,
+			request)
+		return
+	}
+
+	// resolve the reaction and the poll it belongs to
+	reaction, poll, errCode, err := server.getReactionForAction(user,
+		optionID, commentID, query.Get(cEmoji))
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, err, cRemoveReactionTag, writer,
+			request)
+		return
+	}
+
+	// removing a missing reaction leaves the poll untouched
+	exists, err := server.db.ExistsReaction(reaction)
+	if err != nil {
+		server.respondWithError(ERR_INT_DB_GET, err, cRemoveReactionTag,
+			writer, request)
+		return
+	} else if !exists {
+		server.respondWithReactions(user, poll.ID, nil, cRemoveReactionTag,
+			writer, request)
+		return
+	}
+
+	// delete the reaction along with bumping the poll
+	var snapshot *polly.PollSnapshot
+	errCode, err = server.updatePollByActor(poll.ID,
+		polly.EVENT_TYPE_REACTION_REMOVED, user.ID,
+		reactionEventUser(user, poll), reaction.Emoji, cRemoveReactionTag,
+		func(tx *gorp.Transaction) (int, error) {
+			_, err := database.DeleteReactionTX(reaction, tx)
+			if err != nil {
+				return ERR_INT_DB_DELETE, err
+			}
+
+			snapshot, err = database.GetPollSnapshotTX(poll.ID, tx)
+			if err != nil {
+				return ERR_INT_DB_GET, err
+			}
+
+			return NO_ERR, nil
+		})
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, err, cRemoveReactionTag, writer,
+			request)
+		return
+	}
+
+	server.respondWithReactions(user, poll.ID, snapshot, cRemoveReactionTag,
+		writer, request)
+}
+
+/*
+ * Builds the reaction of the user on either the given option or the given
+ * comment and makes sure the user may react within its poll. Returns the
+ * reaction, its poll, an API error code and the underlying error.
+ */
+func (server *sServer) getReactionForAction(user *polly.PrivateUser,
+	optionID, commentID int64, emoji string) (*polly.Reaction, *polly.Poll,
+	int, error) {
+
+	if !isValidEmoji(emoji) {
+		return nil, nil, ERR_BAD_EMOJI, nil
+	}
+
+	reaction := polly.Reaction{}
+	reaction.UserID = user.ID
+	reaction.Emoji = emoji
+
+	// a reaction belongs to exactly one option or comment
+	switch {
+	case optionID != 0 && commentID != 0, optionID == 0 && commentID == 0:
+		return nil, nil, ERR_BAD_REACTION_TARGET, nil
+	case optionID != 0:
+		option, err := server.db.GetOptionByID(optionID)
+		if err != nil {
+			return nil, nil, ERR_BAD_NO_OPTION, err
+		}
+
+		reaction.OptionID = option.ID
+		reaction.PollID = option.PollID
+	default:
+		comment, err := server.db.GetCommentByID(commentID)
+		if err != nil {
+			return nil, nil, ERR_BAD_NO_COMMENT, err
+		}
+
+		reaction.CommentID = comment.ID
+		reaction.PollID = comment.PollID
+	}
+
+	// make sure the user may react within the poll
+	poll, _, errCode, err := server.getPollForAction(user, reaction.PollID,
+		cPermissionReact)
+	if errCode != NO_ERR {
+		return nil, nil, errCode, err
+	}
+
+	return &reaction, poll, NO_ERR, nil
+}
+
+/*
+ * Responds with the reaction counts of the poll. Without a snapshot the
+ * current one is sent.
+ */
+func (server *sServer) respondWithReactions(user *polly.PrivateUser,
+	pollID int64, snapshot *polly.PollSnapshot, tag string,
+	writer http.ResponseWriter, request *http.Request) {
+
+	var err error
+	if snapshot == nil {
+		snapshot, err = server.db.GetPollSnapshot(pollID)
+		if err != nil {
+			server.respondWithError(ERR_INT_DB_GET, err, tag, writer, request)
+			return
+		}
+	}
+
+	// construct the response message
+	response := polly.ReactionResponseMessage{}
+	response.Poll = *snapshot
+	response.Reactions, err = server.db.GetReactionCountsByPollID(pollID,
+		user.ID)
+	if err != nil {
+		server.respondWithError(ERR_INT_DB_GET, err, tag, writer, request)
+		return
+	} else if response.Reactions == nil {
+		response.Reactions = []polly.ReactionCount{}
+	}
+
+	// marshall the response
+	responseBody, err := json.MarshalIndent(response, "", "\t")
+	if err != nil {
+		server.respondWithError(ERR_INT_MARSHALL, err, tag, writer, request)
+		return
+	}
+
+	// send the response
+	err = server.respondWithJSONBody(writer, responseBody)
+	if err != nil {
+		server.respondWithError(ERR_INT_WRITE, err, tag, writer, request)
+	}
+}
+
+/* Anonymous polls don't record who reacted as the last event user. */
+func reactionEventUser(user *polly.PrivateUser,
+	poll *polly.Poll) *polly.PrivateUser {
+
+	eventUser := polly.PrivateUser{}
+	eventUser.DisplayName, eventUser.ID = eventUserForPoll(user, poll)
+	return &eventUser
+}
+
+/*
+ * Accepts a single emoji, possibly built from several code points such as
+ * flags, keycaps and skin tones. Letters and whitespace are refused.
+ */
+func isValidEmoji(emoji string) bool {
+	length := utf8.RuneCountInString(emoji)
+	if length == 0 || length > cMaxEmojiLen || !utf8.ValidString(emoji) {
+		return false
+	}
+
+	hasSymbol := false
+	for _, r := range emoji {
+		if unicode.IsSpace(r) || unicode.IsControl(r) || unicode.IsLetter(r) {
+			return false
+		} else if r >= utf8.RuneSelf {
+			hasSymbol = true
+		}
+	}
+
+	return hasSymbol
+}
@@ -0,0 +1,135 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/roxot/polly"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	cMarkPollReadTag = "POST/READ"
+	cResetBadgeTag   = "POST/BADGE_RESET"
+)
+
+/*
+ * Marks a poll as seen by the user up to the given sequence number, or up to
+ * the current one if none is given. Polls are also marked as seen when they
+ * are fetched. Seen sequence numbers never go back, so devices syncing in any
+ * order agree on the unread state.
+ */
+// POST /v0.1/read.json
+func (server *sServer) MarkPollRead(writer http.ResponseWriter,
+	request *http.Request, _ httprouter.Params) {
+
+	// authenticate the user
+	user, errCode := server.authenticateRequest(request)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cMarkPollReadTag, writer, request)
+		return
+	}
+
+	// decode the read message
+	var readMsg polly.ReadMessage
+	decoder := json.NewDecoder(request.Body)
+	err := decoder.Decode(&readMsg)
+	if err != nil {
+		server.respondWithError(ERR_BAD_JSON, err, cMarkPollReadTag, writer,
+			request)
+		return
+	}
+
+	// make sure the user participates in the poll
+	_, errCode = server.authorizePollAction(user.ID, readMsg.PollID,
+		cPermissionView)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cMarkPollReadTag, writer, request)
+		return
+	}
+
+	// mark the poll as seen
+	if readMsg.SequenceNumber <= 0 {
+		snapshot, err := server.db.GetPollSnapshot(readMsg.PollID)
+		if err != nil {
+			server.respondWithError(ERR_INT_DB_GET, err, cMarkPollReadTag,
+				writer, request)
+			return
+		}
+
+		readMsg.SequenceNumber = snapshot.SequenceNumber
+	}
+
+	readMsg.SequenceNumber, err = server.db.MarkPollSeen(user.ID,
+		readMsg.PollID, readMsg.SequenceNumber)
+	if err != nil {
+		server.respondWithError(ERR_INT_DB_UPDATE, err, cMarkPollReadTag,
+			writer, request)
+		return
+	}
+
+	// marshall the response
+	responseBody, err := json.MarshalIndent(readMsg, "", "\t")
+	if err != nil {
+		server.respondWithError(ERR_INT_MARSHALL, err, cMarkPollReadTag, writer,
+			request)
+		return
+	}
+
+	// send the response
+	err = server.respondWithJSONBody(writer, responseBody)
+	if err != nil {
+		server.respondWithError(ERR_INT_WRITE, err, cMarkPollReadTag, writer,
+			request)
+	}
+}
+
+/*
+ * Resets the badge count of the user, which apps do when they're opened. Push
+ * notifications only count polls updated after the reset in their badge.
+ * Responds with the badge count after the reset.
+ */
+// POST /v0.1/badge_reset.json
+func (server *sServer) ResetBadge(writer http.ResponseWriter,
+	request *http.Request, _ httprouter.Params) {
+
+	// authenticate the user
+	user, errCode := server.authenticateRequest(request)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cResetBadgeTag, writer, request)
+		return
+	}
+
+	// reset the badge
+	err := server.db.ResetBadge(user.ID, time.Now().UnixNano()/1000000)
+	if err != nil {
+		server.respondWithError(ERR_INT_DB_UPDATE, err, cResetBadgeTag, writer,
+			request)
+		return
+	}
+
+	badgeMsg := polly.BadgeMessage{}
+	badgeMsg.Badge, err = server.db.CountBadgeForUser(user.ID)
+	if err != nil {
+		server.respondWithError(ERR_INT_DB_GET, err, cResetBadgeTag, writer,
+			request)
+		return
+	}
+
+	// marshall the response
+	responseBody, err := json.MarshalIndent(badgeMsg, "", "\t")
+	if err != nil {
+		server.respondWithError(ERR_INT_MARSHALL, err, cResetBadgeTag, writer,
+			request)
+		return
+	}
+
+	// send the response
+	err = server.respondWithJSONBody(writer, responseBody)
+	if err != nil {
+		server.respondWithError(ERR_INT_WRITE, err, cResetBadgeTag, writer,
+			request)
+	}
+}
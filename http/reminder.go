@@ -0,0 +1,221 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/roxot/polly"
+	"github.com/roxot/polly/database"
+
+	"github.com/julienschmidt/httprouter"
+	"gopkg.in/gorp.v1"
+)
+
+const (
+	cNudgeTag          = "POST/NUDGE"
+	cPollReminderEvent = "poll_reminder_event"
+	cJobIDSeparator    = ","
+)
+
+type tPollToRemind struct {
+	ID          int64
+	ClosingDate int64
+}
+
+// POST /v0.1/nudge.json
+func (server *sServer) Nudge(writer http.ResponseWriter,
+	request *http.Request, _ httprouter.Params) {
+
+	// authenticate the user
+	user, errCode := server.authenticateRequest(request)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cNudgeTag, writer, request)
+		return
+	}
+
+	// decode the nudge message
+	var nudgeMsg polly.NudgeMessage
+	decoder := json.NewDecoder(request.Body)
+	err := decoder.Decode(&nudgeMsg)
+	if err != nil {
+		server.respondWithError(ERR_BAD_JSON, err, cNudgeTag, writer, request)
+		return
+	}
+
+	// retrieve the poll and make sure the user may nudge its participants
+	poll, question, errCode, err := server.getPollForAction(user,
+		nudgeMsg.PollID, cPermissionNudge)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, err, cNudgeTag, writer, request)
+		return
+	}
+
+	// make sure the poll hasn't closed yet
+	now := time.Now()
+	currentTime := now.UnixNano() / 1000000
+	if currentTime > poll.ClosingDate {
+		server.respondWithError(ERR_ILL_POLL_CLOSED, nil, cNudgeTag, writer,
+			request)
+		return
+	}
+
+	// only nudge once per interval
+	earliest := now.Add(-cMinNudgeInterval).UnixNano() / 1000000
+	updated, err := server.db.UpdateLastNudge(poll.ID, currentTime, earliest)
+	if err != nil {
+		server.respondWithError(ERR_INT_DB_UPDATE, err, cNudgeTag, writer,
+			request)
+		return
+	} else if !updated {
+		server.respondWithError(ERR_ILL_NUDGE_TOO_SOON, nil, cNudgeTag, writer,
+			request)
+		return
+	}
+
+	// notify the participants that haven't voted yet
+	err = server.pushClient.NotifyForReminder(&server.db, user, poll.ID,
+		question.Title)
+	if err != nil {
+		server.respondWithError(ERR_INT_NOTIFICATION, err, cNudgeTag, writer,
+			request)
+		return
+	}
+
+	// respond with 200 OK
+	server.respondOkay(writer, request)
+}
+
+/*
+ * Reminds the participants that haven't voted yet that the poll closes soon.
+ * Reminders for a closing date that has changed since are skipped.
+ */
+func (server *sServer) RemindPoll(poll *tPollToRemind) error {
+	dbPoll, err := server.db.GetPollByID(poll.ID)
+	if err != nil {
+		return err
+	} else if dbPoll.ClosingDate != poll.ClosingDate {
+		return nil
+	}
+
+	question, err := server.db.GetQuestionByPollID(poll.ID)
+	if err != nil {
+		return err
+	}
+
+	err = server.pushClient.NotifyForReminder(&server.db, nil, poll.ID,
+		question.Title)
+	if err != nil {
+		// TODO neaten up
+		server.logger.Log(cPollReminderEvent, "Error notifying: "+
+			err.Error(), "::1")
+	}
+
+	return nil
+}
+
+/* Decodes the poll of a reminder job. */
+func (server *sServer) remindPollJob(data []byte) error {
+	var poll tPollToRemind
+	err := json.Unmarshal(data, &poll)
+	if err != nil {
+		return err
+	}
+
+	return server.RemindPoll(&poll)
+}
+
+/*
+ * Schedules the configured reminders before the given closing date and
+ * remembers the jobs so they can be rescheduled later on.
+ */
+func (server *sServer) scheduleReminders(pollID, closingDate int64) error {
+	jobIDs, err := server.scheduleReminderJobs(pollID, closingDate,
+		func(runAt time.Time, data interface{}) (string, error) {
+			return server.scheduler.Schedule(cPollRemindersJobs, runAt, data)
+		})
+	if err != nil {
+		return err
+	}
+
+	return server.db.UpdateReminderJobIDs(pollID, jobIDs)
+}
+
+/* Schedules the reminders within the transaction that inserts the poll. */
+func (server *sServer) scheduleRemindersTX(pollID, closingDate int64,
+	tx *gorp.Transaction) error {
+
+	jobIDs, err := server.scheduleReminderJobs(pollID, closingDate,
+		func(runAt time.Time, data interface{}) (string, error) {
+			return server.scheduler.ScheduleTX(cPollRemindersJobs, runAt, data,
+				tx)
+		})
+	if err != nil {
+		return err
+	}
+
+	return database.UpdateReminderJobIDsTX(pollID, jobIDs, tx)
+}
+
+/*
+ * Schedules a reminder job for every configured offset using the given
+ * function. Reminders that would be sent in the past are left out. Returns
+ * the joined identifiers of the jobs.
+ */
+func (server *sServer) scheduleReminderJobs(pollID, closingDate int64,
+	schedule func(runAt time.Time, data interface{}) (string, error)) (
+	string, error) {
+
+	now := time.Now()
+	closingTime := time.Unix(0, 1000000*closingDate)
+	pollToRemind := tPollToRemind{pollID, closingDate}
+
+	jobIDs := make([]string, 0, len(server.reminderOffsets))
+	for _, offset := range server.reminderOffsets {
+		reminderTime := closingTime.Add(-offset)
+		if !reminderTime.After(now) {
+			continue
+		}
+
+		jobID, err := schedule(reminderTime, &pollToRemind)
+		if err != nil {
+			return "", err
+		}
+
+		jobIDs = append(jobIDs, jobID)
+	}
+
+	return strings.Join(jobIDs, cJobIDSeparator), nil
+}
+
+/* Cancels the pending reminders of the given poll. */
+func (server *sServer) cancelReminders(pollID int64) error {
+	poll, err := server.db.GetPollByID(pollID)
+	if err != nil {
+		return err
+	} else if len(poll.ReminderJobIDs) == 0 {
+		return nil
+	}
+
+	for _, jobID := range strings.Split(poll.ReminderJobIDs,
+		cJobIDSeparator) {
+
+		err = server.scheduler.Cancel(jobID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/* Replaces the reminders of the given poll after its closing date changed. */
+func (server *sServer) rescheduleReminders(pollID, closingDate int64) error {
+	err := server.cancelReminders(pollID)
+	if err != nil {
+		return err
+	}
+
+	return server.scheduleReminders(pollID, closingDate)
+}
@@ -49,14 +49,29 @@ const (
 	ERR_INT_NOTIFICATION       = BASE_INT + iota // 112
 	ERR_INT_CP_SCHEDULER       = BASE_INT + iota // 113
 	ERR_INT_PARSE_INT          = BASE_INT + iota // 114
+	ERR_INT_TEMPLATE_SCHEDULER = BASE_INT + iota // 115
+	ERR_INT_REMINDER_SCHEDULER = BASE_INT + iota // 116
+	ERR_INT_MOVIE_PROVIDER     = BASE_INT + iota // 117
+	ERR_INT_BLOB_STORE         = BASE_INT + iota // 118
 )
 
 const (
-	ERR_ILL_POLL_ACCESS  = BASE_ILL + iota // 200
-	ERR_ILL_ADD_OPTION   = BASE_ILL + iota // 201
-	ERR_ILL_TOO_MANY_IDS = BASE_ILL + iota // 202
-	ERR_ILL_POLL_CLOSED  = BASE_ILL + iota // 203
-	ERR_ILL_NOT_CREATOR  = BASE_ILL + iota // 204
+	ERR_ILL_POLL_ACCESS        = BASE_ILL + iota // 200
+	ERR_ILL_ADD_OPTION         = BASE_ILL + iota // 201
+	ERR_ILL_TOO_MANY_IDS       = BASE_ILL + iota // 202
+	ERR_ILL_POLL_CLOSED        = BASE_ILL + iota // 203
+	ERR_ILL_NOT_CREATOR        = BASE_ILL + iota // 204
+	ERR_ILL_GROUP_ACCESS       = BASE_ILL + iota // 205
+	ERR_ILL_INVITE_REVOKED     = BASE_ILL + iota // 206
+	ERR_ILL_INVITE_EXPIRED     = BASE_ILL + iota // 207
+	ERR_ILL_INVITE_USED_UP     = BASE_ILL + iota // 208
+	ERR_ILL_GUESTS_NOT_ALLOWED = BASE_ILL + iota // 209
+	ERR_ILL_POLL_NOT_CLOSED    = BASE_ILL + iota // 210
+	ERR_ILL_ROLE               = BASE_ILL + iota // 211
+	ERR_ILL_TEMPLATE_ACCESS    = BASE_ILL + iota // 212
+	ERR_ILL_NUDGE_TOO_SOON     = BASE_ILL + iota // 213
+	ERR_ILL_POLL_UNDECIDED     = BASE_ILL + iota // 214
+	ERR_ILL_COMMENT_ACCESS     = BASE_ILL + iota // 215
 )
 
 const (
@@ -79,6 +94,37 @@ const (
 	ERR_BAD_CLOSING_DATE          = BASE_BAD + iota // 316
 	ERR_BAD_NO_ID                 = BASE_BAD + iota // 317
 	ERR_BAD_NO_DISPLAY_NAME       = BASE_BAD + iota // 318
+	ERR_BAD_NO_GROUP              = BASE_BAD + iota // 319
+	ERR_BAD_EMPTY_GROUP_NAME      = BASE_BAD + iota // 320
+	ERR_BAD_NO_INVITE             = BASE_BAD + iota // 321
+	ERR_BAD_INVITE_TOKEN          = BASE_BAD + iota // 322
+	ERR_BAD_EXPIRATION_DATE       = BASE_BAD + iota // 323
+	ERR_BAD_MAX_USES              = BASE_BAD + iota // 324
+	ERR_BAD_NO_GUEST              = BASE_BAD + iota // 325
+	ERR_BAD_ROLE                  = BASE_BAD + iota // 326
+	ERR_BAD_NO_PARTICIPANT        = BASE_BAD + iota // 327
+	ERR_BAD_RESULTS_VISIBILITY    = BASE_BAD + iota // 328
+	ERR_BAD_NO_TEMPLATE           = BASE_BAD + iota // 329
+	ERR_BAD_RECURRENCE            = BASE_BAD + iota // 330
+	ERR_BAD_OPEN_DURATION         = BASE_BAD + iota // 331
+	ERR_BAD_TIME_ZONE             = BASE_BAD + iota // 332
+	ERR_BAD_OPTION_DATE           = BASE_BAD + iota // 333
+	ERR_BAD_NOT_DATE_POLL         = BASE_BAD + iota // 334
+	ERR_BAD_NO_MOVIE              = BASE_BAD + iota // 335
+	ERR_BAD_NO_QUERY              = BASE_BAD + iota // 336
+	ERR_BAD_LOCATION              = BASE_BAD + iota // 337
+	ERR_BAD_IMAGE                 = BASE_BAD + iota // 338
+	ERR_BAD_IMAGE_TYPE            = BASE_BAD + iota // 339
+	ERR_BAD_IMAGE_TOO_LARGE       = BASE_BAD + iota // 340
+	ERR_BAD_NO_IMAGE              = BASE_BAD + iota // 341
+	ERR_BAD_OPTION_IMAGE          = BASE_BAD + iota // 342
+	ERR_BAD_NO_COMMENT            = BASE_BAD + iota // 343
+	ERR_BAD_COMMENT               = BASE_BAD + iota // 344
+	ERR_BAD_EMOJI                 = BASE_BAD + iota // 345
+	ERR_BAD_REACTION_TARGET       = BASE_BAD + iota // 346
+	ERR_BAD_PAGE_SIZE             = BASE_BAD + iota // 347
+	ERR_BAD_POLL_FILTER           = BASE_BAD + iota // 348
+	ERR_BAD_CURSOR                = BASE_BAD + iota // 349
 )
 
 const (
@@ -87,6 +133,11 @@ const (
 	ERR_AUT_BAD_TOKEN          = BASE_AUT + iota // 402
 	ERR_AUT_NO_FACEBOOK_TOKEN  = BASE_AUT + iota // 403
 	ERR_AUT_BAD_FACEBOOK_TOKEN = BASE_AUT + iota // 404
+	ERR_AUT_GUEST              = BASE_AUT + iota // 405
+	ERR_AUT_NO_SESSION         = BASE_AUT + iota // 406
+	ERR_AUT_BAD_SESSION        = BASE_AUT + iota // 407
+	ERR_AUT_BAD_CSRF_TOKEN     = BASE_AUT + iota // 408
+	ERR_AUT_BAD_CALENDAR_TOKEN = BASE_AUT + iota // 409
 )
 
 var vAPICodeMessages = map[int]string{
@@ -107,12 +158,27 @@ var vAPICodeMessages = map[int]string{
 	ERR_INT_NOTIFICATION:       "Failed to send notifications.",
 	ERR_INT_CP_SCHEDULER:       "Failed to schedule poll closing event.",
 	ERR_INT_PARSE_INT:          "Failed to parse integer.",
-
-	ERR_ILL_POLL_ACCESS:  "No access to poll.",
-	ERR_ILL_ADD_OPTION:   "Not allowed to add options.",
-	ERR_ILL_TOO_MANY_IDS: "Too many identifiers provided.",
-	ERR_ILL_POLL_CLOSED:  "Poll closed.",
-	ERR_ILL_NOT_CREATOR:  "No creator access to poll.",
+	ERR_INT_TEMPLATE_SCHEDULER: "Failed to schedule poll template.",
+	ERR_INT_REMINDER_SCHEDULER: "Failed to schedule poll reminders.",
+	ERR_INT_MOVIE_PROVIDER:     "Failed to reach the movie provider.",
+	ERR_INT_BLOB_STORE:         "Failed to access the image storage.",
+
+	ERR_ILL_POLL_ACCESS:        "No access to poll.",
+	ERR_ILL_ADD_OPTION:         "Not allowed to add options.",
+	ERR_ILL_TOO_MANY_IDS:       "Too many identifiers provided.",
+	ERR_ILL_POLL_CLOSED:        "Poll closed.",
+	ERR_ILL_NOT_CREATOR:        "No creator access to poll.",
+	ERR_ILL_GROUP_ACCESS:       "No access to group.",
+	ERR_ILL_INVITE_REVOKED:     "Invite link revoked.",
+	ERR_ILL_INVITE_EXPIRED:     "Invite link expired.",
+	ERR_ILL_INVITE_USED_UP:     "Invite link used up.",
+	ERR_ILL_GUESTS_NOT_ALLOWED: "Guests not allowed.",
+	ERR_ILL_POLL_NOT_CLOSED:    "Poll not closed.",
+	ERR_ILL_ROLE:               "Role does not permit this action.",
+	ERR_ILL_TEMPLATE_ACCESS:    "No access to poll template.",
+	ERR_ILL_NUDGE_TOO_SOON:     "Participants were nudged too recently.",
+	ERR_ILL_POLL_UNDECIDED:     "Poll has no single winning option.",
+	ERR_ILL_COMMENT_ACCESS:     "Not allowed to change this comment.",
 
 	ERR_BAD_JSON:                  "Bad JSON.",
 	ERR_BAD_NO_USER:               "No such user.",
@@ -133,12 +199,48 @@ var vAPICodeMessages = map[int]string{
 	ERR_BAD_CLOSING_DATE:          "Bad closing date.",
 	ERR_BAD_NO_ID:                 "No ID provided.",
 	ERR_BAD_NO_DISPLAY_NAME:       "No display name provided.",
+	ERR_BAD_NO_GROUP:              "No such group.",
+	ERR_BAD_EMPTY_GROUP_NAME:      "Empty group name.",
+	ERR_BAD_NO_INVITE:             "No such invite link.",
+	ERR_BAD_INVITE_TOKEN:          "Bad invite token.",
+	ERR_BAD_EXPIRATION_DATE:       "Bad expiration date.",
+	ERR_BAD_MAX_USES:              "Bad maximum number of uses.",
+	ERR_BAD_NO_GUEST:              "No such guest.",
+	ERR_BAD_ROLE:                  "Bad role.",
+	ERR_BAD_NO_PARTICIPANT:        "No such participant.",
+	ERR_BAD_RESULTS_VISIBILITY:    "Bad results visibility.",
+	ERR_BAD_NO_TEMPLATE:           "Poll template does not exist.",
+	ERR_BAD_RECURRENCE:            "Bad recurrence rule.",
+	ERR_BAD_OPEN_DURATION:         "Bad open duration.",
+	ERR_BAD_TIME_ZONE:             "Bad time zone.",
+	ERR_BAD_OPTION_DATE:           "Bad option date.",
+	ERR_BAD_NOT_DATE_POLL:         "Poll is not a date poll.",
+	ERR_BAD_NO_MOVIE:              "No such movie.",
+	ERR_BAD_NO_QUERY:              "No search query.",
+	ERR_BAD_LOCATION:              "Invalid location.",
+	ERR_BAD_IMAGE:                 "Invalid image.",
+	ERR_BAD_IMAGE_TYPE:            "Only JPEG and PNG images are supported.",
+	ERR_BAD_IMAGE_TOO_LARGE:       "Image too large.",
+	ERR_BAD_NO_IMAGE:              "No such image.",
+	ERR_BAD_OPTION_IMAGE:          "Image not found or already in use.",
+	ERR_BAD_NO_COMMENT:            "No such comment.",
+	ERR_BAD_COMMENT:               "Empty or too long comment.",
+	ERR_BAD_EMOJI:                 "Invalid emoji.",
+	ERR_BAD_REACTION_TARGET:       "A reaction needs either an option or a comment.",
+	ERR_BAD_PAGE_SIZE:             "Bad page size.",
+	ERR_BAD_POLL_FILTER:           "Unknown poll filter or sort order.",
+	ERR_BAD_CURSOR:                "Bad cursor.",
 
 	ERR_AUT_NO_AUTH:            "No authentication provided.",
 	ERR_AUT_NO_USER:            "No such user.",
 	ERR_AUT_BAD_TOKEN:          "Bad token.",
 	ERR_AUT_NO_FACEBOOK_TOKEN:  "No Facebook token provided.",
 	ERR_AUT_BAD_FACEBOOK_TOKEN: "Bad Facebook token.",
+	ERR_AUT_GUEST:              "Not available to guests.",
+	ERR_AUT_NO_SESSION:         "No session.",
+	ERR_AUT_BAD_SESSION:        "Bad session.",
+	ERR_AUT_BAD_CSRF_TOKEN:     "Bad CSRF token.",
+	ERR_AUT_BAD_CALENDAR_TOKEN: "Bad calendar token.",
 }
 
 var vAPICodeHTTPStatuses = map[int]int{
@@ -159,12 +261,27 @@ var vAPICodeHTTPStatuses = map[int]int{
 	ERR_INT_NOTIFICATION:       http.StatusInternalServerError,
 	ERR_INT_CP_SCHEDULER:       http.StatusInternalServerError,
 	ERR_INT_PARSE_INT:          http.StatusInternalServerError,
-
-	ERR_ILL_POLL_ACCESS:  http.StatusForbidden,
-	ERR_ILL_ADD_OPTION:   http.StatusForbidden,
-	ERR_ILL_TOO_MANY_IDS: http.StatusForbidden,
-	ERR_ILL_POLL_CLOSED:  http.StatusForbidden,
-	ERR_ILL_NOT_CREATOR:  http.StatusForbidden,
+	ERR_INT_TEMPLATE_SCHEDULER: http.StatusInternalServerError,
+	ERR_INT_REMINDER_SCHEDULER: http.StatusInternalServerError,
+	ERR_INT_MOVIE_PROVIDER:     http.StatusInternalServerError,
+	ERR_INT_BLOB_STORE:         http.StatusInternalServerError,
+
+	ERR_ILL_POLL_ACCESS:        http.StatusForbidden,
+	ERR_ILL_ADD_OPTION:         http.StatusForbidden,
+	ERR_ILL_TOO_MANY_IDS:       http.StatusForbidden,
+	ERR_ILL_POLL_CLOSED:        http.StatusForbidden,
+	ERR_ILL_NOT_CREATOR:        http.StatusForbidden,
+	ERR_ILL_GROUP_ACCESS:       http.StatusForbidden,
+	ERR_ILL_INVITE_REVOKED:     http.StatusForbidden,
+	ERR_ILL_INVITE_EXPIRED:     http.StatusForbidden,
+	ERR_ILL_INVITE_USED_UP:     http.StatusForbidden,
+	ERR_ILL_GUESTS_NOT_ALLOWED: http.StatusForbidden,
+	ERR_ILL_POLL_NOT_CLOSED:    http.StatusForbidden,
+	ERR_ILL_ROLE:               http.StatusForbidden,
+	ERR_ILL_TEMPLATE_ACCESS:    http.StatusForbidden,
+	ERR_ILL_NUDGE_TOO_SOON:     http.StatusTooManyRequests,
+	ERR_ILL_POLL_UNDECIDED:     http.StatusForbidden,
+	ERR_ILL_COMMENT_ACCESS:     http.StatusForbidden,
 
 	ERR_BAD_JSON:                  http.StatusBadRequest,
 	ERR_BAD_NO_USER:               http.StatusBadRequest,
@@ -185,12 +302,48 @@ var vAPICodeHTTPStatuses = map[int]int{
 	ERR_BAD_CLOSING_DATE:          http.StatusBadRequest,
 	ERR_BAD_NO_ID:                 http.StatusBadRequest,
 	ERR_BAD_NO_DISPLAY_NAME:       http.StatusBadRequest,
+	ERR_BAD_NO_GROUP:              http.StatusBadRequest,
+	ERR_BAD_EMPTY_GROUP_NAME:      http.StatusBadRequest,
+	ERR_BAD_NO_INVITE:             http.StatusBadRequest,
+	ERR_BAD_INVITE_TOKEN:          http.StatusBadRequest,
+	ERR_BAD_EXPIRATION_DATE:       http.StatusBadRequest,
+	ERR_BAD_MAX_USES:              http.StatusBadRequest,
+	ERR_BAD_NO_GUEST:              http.StatusBadRequest,
+	ERR_BAD_ROLE:                  http.StatusBadRequest,
+	ERR_BAD_NO_PARTICIPANT:        http.StatusBadRequest,
+	ERR_BAD_RESULTS_VISIBILITY:    http.StatusBadRequest,
+	ERR_BAD_NO_TEMPLATE:           http.StatusBadRequest,
+	ERR_BAD_RECURRENCE:            http.StatusBadRequest,
+	ERR_BAD_OPEN_DURATION:         http.StatusBadRequest,
+	ERR_BAD_TIME_ZONE:             http.StatusBadRequest,
+	ERR_BAD_OPTION_DATE:           http.StatusBadRequest,
+	ERR_BAD_NOT_DATE_POLL:         http.StatusBadRequest,
+	ERR_BAD_NO_MOVIE:              http.StatusBadRequest,
+	ERR_BAD_NO_QUERY:              http.StatusBadRequest,
+	ERR_BAD_LOCATION:              http.StatusBadRequest,
+	ERR_BAD_IMAGE:                 http.StatusBadRequest,
+	ERR_BAD_IMAGE_TYPE:            http.StatusUnsupportedMediaType,
+	ERR_BAD_IMAGE_TOO_LARGE:       http.StatusRequestEntityTooLarge,
+	ERR_BAD_NO_IMAGE:              http.StatusNotFound,
+	ERR_BAD_OPTION_IMAGE:          http.StatusBadRequest,
+	ERR_BAD_NO_COMMENT:            http.StatusBadRequest,
+	ERR_BAD_COMMENT:               http.StatusBadRequest,
+	ERR_BAD_EMOJI:                 http.StatusBadRequest,
+	ERR_BAD_REACTION_TARGET:       http.StatusBadRequest,
+	ERR_BAD_PAGE_SIZE:             http.StatusBadRequest,
+	ERR_BAD_POLL_FILTER:           http.StatusBadRequest,
+	ERR_BAD_CURSOR:                http.StatusBadRequest,
 
 	ERR_AUT_NO_AUTH:            http.StatusUnauthorized,
 	ERR_AUT_NO_USER:            http.StatusForbidden,
 	ERR_AUT_BAD_TOKEN:          http.StatusForbidden,
 	ERR_AUT_NO_FACEBOOK_TOKEN:  http.StatusBadRequest,
 	ERR_AUT_BAD_FACEBOOK_TOKEN: http.StatusForbidden,
+	ERR_AUT_GUEST:              http.StatusForbidden,
+	ERR_AUT_NO_SESSION:         http.StatusUnauthorized,
+	ERR_AUT_BAD_SESSION:        http.StatusUnauthorized,
+	ERR_AUT_BAD_CSRF_TOKEN:     http.StatusForbidden,
+	ERR_AUT_BAD_CALENDAR_TOKEN: http.StatusForbidden,
 }
 
 var vAPICodeHeaderHandler = map[int]fHeaderHandler{
@@ -211,12 +364,27 @@ var vAPICodeHeaderHandler = map[int]fHeaderHandler{
 	ERR_INT_NOTIFICATION:       setJSONContentTypeHeader,
 	ERR_INT_CP_SCHEDULER:       setJSONContentTypeHeader,
 	ERR_INT_PARSE_INT:          setJSONContentTypeHeader,
-
-	ERR_ILL_POLL_ACCESS:  setJSONContentTypeHeader,
-	ERR_ILL_ADD_OPTION:   setJSONContentTypeHeader,
-	ERR_ILL_TOO_MANY_IDS: setJSONContentTypeHeader,
-	ERR_ILL_POLL_CLOSED:  setJSONContentTypeHeader,
-	ERR_ILL_NOT_CREATOR:  setJSONContentTypeHeader,
+	ERR_INT_TEMPLATE_SCHEDULER: setJSONContentTypeHeader,
+	ERR_INT_REMINDER_SCHEDULER: setJSONContentTypeHeader,
+	ERR_INT_MOVIE_PROVIDER:     setJSONContentTypeHeader,
+	ERR_INT_BLOB_STORE:         setJSONContentTypeHeader,
+
+	ERR_ILL_POLL_ACCESS:        setJSONContentTypeHeader,
+	ERR_ILL_ADD_OPTION:         setJSONContentTypeHeader,
+	ERR_ILL_TOO_MANY_IDS:       setJSONContentTypeHeader,
+	ERR_ILL_POLL_CLOSED:        setJSONContentTypeHeader,
+	ERR_ILL_NOT_CREATOR:        setJSONContentTypeHeader,
+	ERR_ILL_GROUP_ACCESS:       setJSONContentTypeHeader,
+	ERR_ILL_INVITE_REVOKED:     setJSONContentTypeHeader,
+	ERR_ILL_INVITE_EXPIRED:     setJSONContentTypeHeader,
+	ERR_ILL_INVITE_USED_UP:     setJSONContentTypeHeader,
+	ERR_ILL_GUESTS_NOT_ALLOWED: setJSONContentTypeHeader,
+	ERR_ILL_POLL_NOT_CLOSED:    setJSONContentTypeHeader,
+	ERR_ILL_ROLE:               setJSONContentTypeHeader,
+	ERR_ILL_TEMPLATE_ACCESS:    setJSONContentTypeHeader,
+	ERR_ILL_NUDGE_TOO_SOON:     setJSONContentTypeHeader,
+	ERR_ILL_POLL_UNDECIDED:     setJSONContentTypeHeader,
+	ERR_ILL_COMMENT_ACCESS:     setJSONContentTypeHeader,
 
 	ERR_BAD_JSON:                  setJSONContentTypeHeader,
 	ERR_BAD_NO_USER:               setJSONContentTypeHeader,
@@ -237,12 +405,48 @@ var vAPICodeHeaderHandler = map[int]fHeaderHandler{
 	ERR_BAD_CLOSING_DATE:          setJSONContentTypeHeader,
 	ERR_BAD_NO_ID:                 setJSONContentTypeHeader,
 	ERR_BAD_NO_DISPLAY_NAME:       setJSONContentTypeHeader,
+	ERR_BAD_NO_GROUP:              setJSONContentTypeHeader,
+	ERR_BAD_EMPTY_GROUP_NAME:      setJSONContentTypeHeader,
+	ERR_BAD_NO_INVITE:             setJSONContentTypeHeader,
+	ERR_BAD_INVITE_TOKEN:          setJSONContentTypeHeader,
+	ERR_BAD_EXPIRATION_DATE:       setJSONContentTypeHeader,
+	ERR_BAD_MAX_USES:              setJSONContentTypeHeader,
+	ERR_BAD_NO_GUEST:              setJSONContentTypeHeader,
+	ERR_BAD_ROLE:                  setJSONContentTypeHeader,
+	ERR_BAD_NO_PARTICIPANT:        setJSONContentTypeHeader,
+	ERR_BAD_RESULTS_VISIBILITY:    setJSONContentTypeHeader,
+	ERR_BAD_NO_TEMPLATE:           setJSONContentTypeHeader,
+	ERR_BAD_RECURRENCE:            setJSONContentTypeHeader,
+	ERR_BAD_OPEN_DURATION:         setJSONContentTypeHeader,
+	ERR_BAD_TIME_ZONE:             setJSONContentTypeHeader,
+	ERR_BAD_OPTION_DATE:           setJSONContentTypeHeader,
+	ERR_BAD_NOT_DATE_POLL:         setJSONContentTypeHeader,
+	ERR_BAD_NO_MOVIE:              setJSONContentTypeHeader,
+	ERR_BAD_NO_QUERY:              setJSONContentTypeHeader,
+	ERR_BAD_LOCATION:              setJSONContentTypeHeader,
+	ERR_BAD_IMAGE:                 setJSONContentTypeHeader,
+	ERR_BAD_IMAGE_TYPE:            setJSONContentTypeHeader,
+	ERR_BAD_IMAGE_TOO_LARGE:       setJSONContentTypeHeader,
+	ERR_BAD_NO_IMAGE:              setJSONContentTypeHeader,
+	ERR_BAD_OPTION_IMAGE:          setJSONContentTypeHeader,
+	ERR_BAD_NO_COMMENT:            setJSONContentTypeHeader,
+	ERR_BAD_COMMENT:               setJSONContentTypeHeader,
+	ERR_BAD_EMOJI:                 setJSONContentTypeHeader,
+	ERR_BAD_REACTION_TARGET:       setJSONContentTypeHeader,
+	ERR_BAD_PAGE_SIZE:             setJSONContentTypeHeader,
+	ERR_BAD_POLL_FILTER:           setJSONContentTypeHeader,
+	ERR_BAD_CURSOR:                setJSONContentTypeHeader,
 
 	ERR_AUT_NO_AUTH:            setAuthenticationChallengeHeaders,
 	ERR_AUT_NO_USER:            setJSONContentTypeHeader,
 	ERR_AUT_BAD_TOKEN:          setJSONContentTypeHeader,
 	ERR_AUT_NO_FACEBOOK_TOKEN:  setJSONContentTypeHeader,
 	ERR_AUT_BAD_FACEBOOK_TOKEN: setJSONContentTypeHeader,
+	ERR_AUT_GUEST:              setJSONContentTypeHeader,
+	ERR_AUT_NO_SESSION:         setJSONContentTypeHeader,
+	ERR_AUT_BAD_SESSION:        setJSONContentTypeHeader,
+	ERR_AUT_BAD_CSRF_TOKEN:     setJSONContentTypeHeader,
+	ERR_AUT_BAD_CALENDAR_TOKEN: setJSONContentTypeHeader,
 }
 
 var vAPICodeShouldLog = map[int]bool{
@@ -263,12 +467,27 @@ var vAPICodeShouldLog = map[int]bool{
 	ERR_INT_NOTIFICATION:       true,
 	ERR_INT_CP_SCHEDULER:       true,
 	ERR_INT_PARSE_INT:          true,
-
-	ERR_ILL_POLL_ACCESS:  true,
-	ERR_ILL_ADD_OPTION:   true,
-	ERR_ILL_TOO_MANY_IDS: true,
-	ERR_ILL_POLL_CLOSED:  true,
-	ERR_ILL_NOT_CREATOR:  true,
+	ERR_INT_TEMPLATE_SCHEDULER: true,
+	ERR_INT_REMINDER_SCHEDULER: true,
+	ERR_INT_MOVIE_PROVIDER:     true,
+	ERR_INT_BLOB_STORE:         true,
+
+	ERR_ILL_POLL_ACCESS:        true,
+	ERR_ILL_ADD_OPTION:         true,
+	ERR_ILL_TOO_MANY_IDS:       true,
+	ERR_ILL_POLL_CLOSED:        true,
+	ERR_ILL_NOT_CREATOR:        true,
+	ERR_ILL_GROUP_ACCESS:       true,
+	ERR_ILL_INVITE_REVOKED:     true,
+	ERR_ILL_INVITE_EXPIRED:     true,
+	ERR_ILL_INVITE_USED_UP:     true,
+	ERR_ILL_GUESTS_NOT_ALLOWED: true,
+	ERR_ILL_POLL_NOT_CLOSED:    true,
+	ERR_ILL_ROLE:               true,
+	ERR_ILL_TEMPLATE_ACCESS:    true,
+	ERR_ILL_NUDGE_TOO_SOON:     true,
+	ERR_ILL_POLL_UNDECIDED:     true,
+	ERR_ILL_COMMENT_ACCESS:     true,
 
 	ERR_BAD_JSON:                  true,
 	ERR_BAD_NO_USER:               true,
@@ -289,12 +508,48 @@ var vAPICodeShouldLog = map[int]bool{
 	ERR_BAD_CLOSING_DATE:          true,
 	ERR_BAD_NO_ID:                 true,
 	ERR_BAD_NO_DISPLAY_NAME:       true,
+	ERR_BAD_NO_GROUP:              true,
+	ERR_BAD_EMPTY_GROUP_NAME:      true,
+	ERR_BAD_NO_INVITE:             true,
+	ERR_BAD_INVITE_TOKEN:          true,
+	ERR_BAD_EXPIRATION_DATE:       true,
+	ERR_BAD_MAX_USES:              true,
+	ERR_BAD_NO_GUEST:              true,
+	ERR_BAD_ROLE:                  true,
+	ERR_BAD_NO_PARTICIPANT:        true,
+	ERR_BAD_RESULTS_VISIBILITY:    true,
+	ERR_BAD_NO_TEMPLATE:           true,
+	ERR_BAD_RECURRENCE:            true,
+	ERR_BAD_OPEN_DURATION:         true,
+	ERR_BAD_TIME_ZONE:             true,
+	ERR_BAD_OPTION_DATE:           true,
+	ERR_BAD_NOT_DATE_POLL:         true,
+	ERR_BAD_NO_MOVIE:              true,
+	ERR_BAD_NO_QUERY:              true,
+	ERR_BAD_LOCATION:              true,
+	ERR_BAD_IMAGE:                 true,
+	ERR_BAD_IMAGE_TYPE:            true,
+	ERR_BAD_IMAGE_TOO_LARGE:       true,
+	ERR_BAD_NO_IMAGE:              true,
+	ERR_BAD_OPTION_IMAGE:          true,
+	ERR_BAD_NO_COMMENT:            true,
+	ERR_BAD_COMMENT:               true,
+	ERR_BAD_EMOJI:                 true,
+	ERR_BAD_REACTION_TARGET:       true,
+	ERR_BAD_PAGE_SIZE:             true,
+	ERR_BAD_POLL_FILTER:           true,
+	ERR_BAD_CURSOR:                true,
 
 	ERR_AUT_NO_AUTH:            false,
 	ERR_AUT_NO_USER:            true,
 	ERR_AUT_BAD_TOKEN:          true,
 	ERR_AUT_NO_FACEBOOK_TOKEN:  true,
 	ERR_AUT_BAD_FACEBOOK_TOKEN: true,
+	ERR_AUT_GUEST:              true,
+	ERR_AUT_NO_SESSION:         false,
+	ERR_AUT_BAD_SESSION:        true,
+	ERR_AUT_BAD_CSRF_TOKEN:     true,
+	ERR_AUT_BAD_CALENDAR_TOKEN: true,
 }
 
 func setJSONContentTypeHeader(writer http.ResponseWriter) {
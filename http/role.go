@@ -0,0 +1,168 @@
+package http
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"github.com/roxot/polly"
+	"github.com/roxot/polly/database"
+
+	"github.com/julienschmidt/httprouter"
+	"gopkg.in/gorp.v1"
+)
+
+const (
+	cUpdateRoleTag = "PUT/ROLE"
+)
+
+// PUT /v0.1/role.json
+func (server *sServer) UpdateRole(writer http.ResponseWriter,
+	request *http.Request, _ httprouter.Params) {
+
+	// authenticate the user
+	user, errCode := server.authenticateRequest(request)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cUpdateRoleTag, writer, request)
+		return
+	}
+
+	// decode the role message
+	var roleMsg polly.RoleMessage
+	decoder := json.NewDecoder(request.Body)
+	err := decoder.Decode(&roleMsg)
+	if err != nil {
+		server.respondWithError(ERR_BAD_JSON, err, cUpdateRoleTag, writer,
+			request)
+		return
+	}
+
+	// retrieve the poll and make sure the user may hand out roles
+	poll, question, errCode, err := server.getPollForAction(user,
+		roleMsg.PollID, cPermissionManageRoles)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, err, cUpdateRoleTag, writer, request)
+		return
+	}
+
+	// the owner can't change his or her own role, only transfer ownership
+	if roleMsg.UserID == user.ID {
+		server.respondWithError(ERR_BAD_ROLE, nil, cUpdateRoleTag, writer,
+			request)
+		return
+	}
+
+	// make sure the other user participates in the poll
+	_, err = server.db.GetParticipant(roleMsg.UserID, poll.ID)
+	if err != nil {
+		server.respondWithError(ERR_BAD_NO_PARTICIPANT, err, cUpdateRoleTag,
+			writer, request)
+		return
+	}
+
+	changedUser, err := server.db.GetUserByID(roleMsg.UserID)
+	if err != nil {
+		server.respondWithError(ERR_INT_DB_GET, err, cUpdateRoleTag, writer,
+			request)
+		return
+	}
+
+	// guests can only vote or watch
+	transfer := roleMsg.Role == polly.PARTICIPANT_ROLE_OWNER
+	if !transfer && !isValidRole(roleMsg.Role) ||
+		changedUser.GuestPollID != 0 &&
+			roleMsg.Role != polly.PARTICIPANT_ROLE_VOTER &&
+			roleMsg.Role != polly.PARTICIPANT_ROLE_VIEWER {
+
+		server.respondWithError(ERR_BAD_ROLE, nil, cUpdateRoleTag, writer,
+			request)
+		return
+	}
+
+	// update the role, the previous owner stays on as an admin
+	errCode, err = server.updatePollByUser(poll.ID,
+		polly.EVENT_TYPE_ROLE_CHANGED, changedUser, question.Title,
+		cUpdateRoleTag, func(tx *gorp.Transaction) (int, error) {
+			if !transfer {
+				err := database.UpdateParticipantRoleTX(changedUser.ID, poll.ID,
+					roleMsg.Role, tx)
+				if err != nil {
+					return ERR_INT_DB_UPDATE, err
+				}
+
+				return NO_ERR, nil
+			}
+
+			err := database.UpdateParticipantRoleTX(user.ID, poll.ID,
+				polly.PARTICIPANT_ROLE_ADMIN, tx)
+			if err == nil {
+				err = transferOwnershipTX(changedUser.ID, poll.ID, tx)
+			}
+			if err != nil {
+				return ERR_INT_DB_UPDATE, err
+			}
+
+			return NO_ERR, nil
+		})
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, err, cUpdateRoleTag, writer, request)
+		return
+	}
+
+	// notify the poll participants
+	err = server.pushClient.NotifyForChangedRole(&server.db, user, poll.ID,
+		question.Title, changedUser)
+	if err != nil {
+		// TODO neaten up
+		server.logger.Log(cUpdateRoleTag, "Error notifying: "+err.Error(),
+			"::1")
+	}
+
+	// respond with the updated poll
+	pollMsg, err := server.db.ConstructPollMessage(poll.ID, user.ID)
+	if err != nil {
+		server.respondWithError(ERR_INT_DB_GET, err, cUpdateRoleTag, writer,
+			request)
+		return
+	}
+
+	server.respondWithPollMessage(pollMsg, cUpdateRoleTag, writer, request)
+}
+
+/* Makes the given participant the owner, and thereby creator, of the poll. */
+func transferOwnershipTX(newOwnerID, pollID int64,
+	tx *gorp.Transaction) error {
+
+	err := database.UpdateParticipantRoleTX(newOwnerID, pollID,
+		polly.PARTICIPANT_ROLE_OWNER, tx)
+	if err != nil {
+		return err
+	}
+
+	return database.UpdatePollCreatorTX(pollID, newOwnerID, tx)
+}
+
+/*
+ * Transfers the ownership of the poll to the best suited remaining participant
+ * if the given user owns it. Polls without any other registered participants
+ * are left as is.
+ */
+func handOverPollTX(userID, pollID int64, tx *gorp.Transaction) error {
+	participant, err := database.GetParticipantTX(userID, pollID, tx)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return err
+	} else if participant.Role != polly.PARTICIPANT_ROLE_OWNER {
+		return nil
+	}
+
+	successor, err := database.GetSuccessorTX(pollID, userID, tx)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	return transferOwnershipTX(successor.UserID, pollID, tx)
+}
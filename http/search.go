@@ -0,0 +1,106 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/roxot/polly"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	cSearchPollsTag = "GET/SEARCH"
+)
+
+/*
+ * Searches the question titles, option values and comments of the polls the
+ * user participates in. Results match all words of the query, the last word
+ * of "pizza mar" for instance also matches "March".
+ */
+// GET /v0.1/search.json?q=&page=
+func (server *sServer) SearchPolls(writer http.ResponseWriter,
+	request *http.Request, _ httprouter.Params) {
+
+	// authenticate the user
+	user, errCode := server.authenticateRequest(request)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cSearchPollsTag, writer, request)
+		return
+	}
+
+	// split the search query into words
+	words := searchWords(request.URL.Query().Get(cSearchQuery))
+	if len(words) == 0 {
+		server.respondWithError(ERR_BAD_NO_QUERY, nil, cSearchPollsTag, writer,
+			request)
+		return
+	}
+
+	// retrieve the page argument
+	page := 1
+	var err error
+	if pageStr := request.URL.Query().Get(cPage); len(pageStr) > 0 {
+		page, err = strconv.Atoi(pageStr)
+		if err != nil || page < 1 {
+			server.respondWithError(ERR_BAD_PAGE, err, cSearchPollsTag, writer,
+				request)
+			return
+		}
+	}
+
+	// search the polls
+	results, err := server.db.SearchPolls(user.ID, words, cSearchResultMax,
+		(page-1)*cSearchResultMax)
+	if err != nil {
+		server.respondWithError(ERR_INT_DB_GET, err, cSearchPollsTag, writer,
+			request)
+		return
+	}
+
+	// construct the Search object
+	searchMsg := polly.SearchMessage{}
+	searchMsg.Results = results
+	if searchMsg.Results == nil {
+		searchMsg.Results = []polly.SearchResult{}
+	}
+
+	searchMsg.Page = page
+	searchMsg.PageSize = cSearchResultMax
+	searchMsg.NumResults = len(results)
+
+	// marshall the response
+	responseBody, err := json.MarshalIndent(searchMsg, "", "\t")
+	if err != nil {
+		server.respondWithError(ERR_INT_MARSHALL, err, cSearchPollsTag, writer,
+			request)
+		return
+	}
+
+	// send the response
+	err = server.respondWithJSONBody(writer, responseBody)
+	if err != nil {
+		server.respondWithError(ERR_INT_WRITE, err, cSearchPollsTag, writer,
+			request)
+	}
+}
+
+/*
+ * Returns the lowercased words of a search query, at most cSearchWordsMax.
+ * Anything but letters and digits separates words, which keeps the query
+ * syntax of the database out of reach.
+ */
+func searchWords(query string) []string {
+	words := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	if len(words) > cSearchWordsMax {
+		words = words[:cSearchWordsMax]
+	}
+
+	return words
+}
@@ -3,20 +3,33 @@ package http
 import (
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
-	"github.com/albrow/jobs"
+	"github.com/roxot/polly"
+	"github.com/roxot/polly/blob"
 	"github.com/roxot/polly/database"
 	"github.com/roxot/polly/log"
+	"github.com/roxot/polly/movie"
 	"github.com/roxot/polly/push"
+	"github.com/roxot/polly/scheduler"
 
+	"github.com/dchest/uniuri"
 	"github.com/julienschmidt/httprouter"
 )
 
 const (
-	cHTTPServerTag   = "HTTPSERVER"
-	cAPIVersion      = "v0.1"
-	cEndpointFormat  = "/%s/%s.json"
-	cClosedPollsJobs = "CLOSED_POLLS"
+	cHTTPServerTag     = "HTTPSERVER"
+	cAPIVersion        = "v0.1"
+	cEndpointFormat    = "/%s/%s.json"
+	cClosedPollsJobs   = "CLOSED_POLLS"
+	cClosedPushJobs    = "CLOSED_POLL_PUSHES"
+	cPollTemplatesJobs = "POLL_TEMPLATES"
+	cPollRemindersJobs = "POLL_REMINDERS"
+	cImageCleanupJobs  = "IMAGE_CLEANUP"
+	cMovieCacheTTL     = 24 * time.Hour
+	cDefaultPublicURL  = "https://api.getpollyapp.com"
+	cDefaultBlobDir    = "blobs/"
 	// cEndpointWithVarFormat = cEndpointFormat + ":%s"
 )
 
@@ -26,12 +39,18 @@ type IServer interface {
 }
 
 type sServer struct {
-	db          database.Database
-	router      httprouter.Router
-	logger      log.ILogger
-	pushClient  push.IPushClient
-	cpScheduler jobs.Type
-	port        string
+	db              database.Database
+	router          httprouter.Router
+	logger          log.ILogger
+	pushClient      push.IPushClient
+	scheduler       scheduler.IScheduler
+	movieProvider   movie.IProvider
+	blobStore       blob.IStore
+	publicURL       string
+	port            string
+	inviteSecret    []byte
+	facebookAppID   string
+	reminderOffsets []time.Duration
 }
 
 func NewServer(config *Config) (IServer, error) {
@@ -55,6 +74,11 @@ func NewServer(config *Config) (IServer, error) {
 		return nil, err
 	}
 
+	err = db.Migrate()
+	if err != nil {
+		return nil, err
+	}
+
 	pushClient, err := push.NewClient()
 	if err != nil {
 		return nil, err
@@ -65,6 +89,28 @@ func NewServer(config *Config) (IServer, error) {
 	server.db = *db
 	server.router = *httprouter.New()
 	server.port = config.Port
+	server.inviteSecret = []byte(config.InviteSecret)
+
+	// without a configured secret invite links only last until a restart
+	if len(server.inviteSecret) == 0 {
+		server.logger.Log(cHTTPServerTag, "InviteSecret not configured, "+
+			"invite links won't survive a restart", "::1")
+		server.inviteSecret = []byte(uniuri.NewLen(cInviteSecretLength))
+	}
+	server.facebookAppID = config.FacebookAppID
+
+	// parse the reminder offsets, e.g. "24h" before the closing date
+	server.reminderOffsets = vDefaultReminderOffsets
+	if config.ReminderOffsets != nil {
+		server.reminderOffsets = make([]time.Duration,
+			len(config.ReminderOffsets))
+		for idx, offset := range config.ReminderOffsets {
+			server.reminderOffsets[idx], err = time.ParseDuration(offset)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
 
 	// start the push notification server's error logging
 	err = pushClient.StartErrorLogger(server.logger)
@@ -72,26 +118,115 @@ func NewServer(config *Config) (IServer, error) {
 		return nil, err
 	}
 
-	// register the closed poll scheduler
-	cpScheduler, err := jobs.RegisterType(cClosedPollsJobs,
-		config.ClosedPollPushRetries, server.ClosePoll)
+	// create the configured scheduler
+	switch config.Scheduler {
+	case "", scheduler.BACKEND_REDIS:
+		server.scheduler = scheduler.NewRedisScheduler()
+
+		// keep running the jobs scheduled before job data was JSON encoded
+		err = scheduler.RegisterLegacyRedisType(cClosedPollsJobs,
+			config.ClosedPollPushRetries, server.ClosePoll)
+		if err == nil {
+			err = scheduler.RegisterLegacyRedisType(cPollTemplatesJobs, 0,
+				server.RunPollTemplate)
+		}
+		if err == nil {
+			err = scheduler.RegisterLegacyRedisType(cPollRemindersJobs, 0,
+				server.RemindPoll)
+		}
+		if err != nil {
+			return nil, err
+		}
+	case scheduler.BACKEND_POSTGRES:
+		server.scheduler = scheduler.NewPostgresScheduler(&server.db,
+			server.logger)
+	default:
+		return nil, fmt.Errorf("Unknown scheduler %s.", config.Scheduler)
+	}
+
+	// create the configured movie provider, the stub needs no API access
+	var movieProvider movie.IProvider
+	switch config.MovieProvider {
+	case "", movie.PROVIDER_STUB:
+		movieProvider = movie.NewStubProvider()
+	case movie.PROVIDER_OMDB:
+		movieProvider = movie.NewOMDbProvider(config.MovieAPIURL,
+			config.MovieAPIKey)
+	default:
+		return nil, fmt.Errorf("Unknown movie provider %s.",
+			config.MovieProvider)
+	}
+
+	server.movieProvider = movie.NewCachedProvider(movieProvider,
+		cMovieCacheTTL)
+
+	// the URLs of stored images start with the public URL of the server
+	server.publicURL = strings.TrimSuffix(config.PublicURL, "/")
+	if len(server.publicURL) == 0 {
+		server.publicURL = cDefaultPublicURL
+	}
+
+	// create the configured blob store, by default below the polly home
+	switch config.BlobStore {
+	case "", blob.BACKEND_LOCAL:
+		blobDir := config.BlobDir
+		if len(blobDir) == 0 {
+			pollyHome, err := polly.GetPollyHome()
+			if err != nil {
+				return nil, err
+			}
+
+			blobDir = pollyHome + cDefaultBlobDir
+		}
+
+		server.blobStore, err = blob.NewLocalStore(blobDir)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("Unknown blob store %s.", config.BlobStore)
+	}
+
+	// register the closed poll jobs
+	err = server.scheduler.RegisterType(cClosedPollsJobs,
+		config.ClosedPollPushRetries, server.closePollJob)
+	if err != nil {
+		return nil, err
+	}
+
+	// register the jobs retrying the pushes of closed polls
+	err = server.scheduler.RegisterType(cClosedPushJobs,
+		config.ClosedPollPushRetries, server.notifyClosedPollJob)
 	if err != nil {
 		return nil, err
 	}
 
-	// create a job pool for the closed poll scheduler TODO pass configuration s
-	pool, err := jobs.NewPool(nil)
+	// register the poll template jobs, retrying could create a poll twice
+	err = server.scheduler.RegisterType(cPollTemplatesJobs, 0,
+		server.runPollTemplateJob)
 	if err != nil {
 		return nil, err
 	}
 
-	// start the job pool for the closed poll scheduler
-	err = pool.Start()
+	// register the reminder jobs
+	err = server.scheduler.RegisterType(cPollRemindersJobs, 0,
+		server.remindPollJob)
 	if err != nil {
 		return nil, err
 	}
 
-	server.cpScheduler = *cpScheduler
+	// register the jobs cleaning up unused images
+	err = server.scheduler.RegisterType(cImageCleanupJobs, 2,
+		server.cleanUpImageJob)
+	if err != nil {
+		return nil, err
+	}
+
+	// start running the jobs
+	err = server.scheduler.Start()
+	if err != nil {
+		return nil, err
+	}
 
 	return &server, nil
 }
@@ -125,6 +260,98 @@ func (server *sServer) Start() error {
 		server.LeavePoll)
 	server.router.POST(fmt.Sprintf(cEndpointFormat, cAPIVersion, "adduser"),
 		server.AddUser)
+	server.router.PUT(fmt.Sprintf(cEndpointFormat, cAPIVersion, "poll"),
+		server.EditPoll)
+	server.router.POST(fmt.Sprintf(cEndpointFormat, cAPIVersion, "close"),
+		server.ClosePollEarly)
+	server.router.POST(fmt.Sprintf(cEndpointFormat, cAPIVersion, "reopen"),
+		server.ReopenPoll)
+	server.router.DELETE(fmt.Sprintf(cEndpointFormat, cAPIVersion,
+		"participant"), server.RemoveParticipant)
+	server.router.POST(fmt.Sprintf(cEndpointFormat, cAPIVersion, "nudge"),
+		server.Nudge)
+	server.router.PUT(fmt.Sprintf(cEndpointFormat, cAPIVersion, "role"),
+		server.UpdateRole)
+	server.router.POST(fmt.Sprintf(cEndpointFormat, cAPIVersion, "group"),
+		server.PostGroup)
+	server.router.GET(fmt.Sprintf(cEndpointFormat, cAPIVersion, "groups"),
+		server.GetGroups)
+	server.router.PUT(fmt.Sprintf(cEndpointFormat, cAPIVersion, "group"),
+		server.UpdateGroup)
+	server.router.DELETE(fmt.Sprintf(cEndpointFormat, cAPIVersion, "group"),
+		server.DeleteGroup)
+	server.router.POST(fmt.Sprintf(cEndpointFormat, cAPIVersion, "template"),
+		server.PostTemplate)
+	server.router.GET(fmt.Sprintf(cEndpointFormat, cAPIVersion, "templates"),
+		server.GetTemplates)
+	server.router.DELETE(fmt.Sprintf(cEndpointFormat, cAPIVersion,
+		"template"), server.DeleteTemplate)
+	server.router.GET(fmt.Sprintf("/%s/poll/:%s", cAPIVersion, cID),
+		server.PollCalendar)
+	server.router.POST(fmt.Sprintf(cEndpointFormat, cAPIVersion, "calendar"),
+		server.PostCalendarToken)
+	server.router.POST(fmt.Sprintf(cEndpointFormat, cAPIVersion,
+		"profile_pic"), server.UploadProfilePic)
+	server.router.POST(fmt.Sprintf(cEndpointFormat, cAPIVersion, "image"),
+		server.UploadImage)
+	server.router.POST(fmt.Sprintf(cEndpointFormat, cAPIVersion, "comment"),
+		server.PostComment)
+	server.router.PUT(fmt.Sprintf(cEndpointFormat, cAPIVersion, "comment"),
+		server.EditComment)
+	server.router.DELETE(fmt.Sprintf(cEndpointFormat, cAPIVersion,
+		"comment"), server.DeleteComment)
+	server.router.GET(fmt.Sprintf(cEndpointFormat, cAPIVersion, "comments"),
+		server.GetComments)
+	server.router.POST(fmt.Sprintf(cEndpointFormat, cAPIVersion, "reaction"),
+		server.AddReaction)
+	server.router.DELETE(fmt.Sprintf(cEndpointFormat, cAPIVersion,
+		"reaction"), server.RemoveReaction)
+	server.router.GET(fmt.Sprintf(cEndpointFormat, cAPIVersion, "movies"),
+		server.SearchMovies)
+	server.router.GET(fmt.Sprintf(cEndpointFormat, cAPIVersion, "search"),
+		server.SearchPolls)
+	server.router.POST(fmt.Sprintf(cEndpointFormat, cAPIVersion, "read"),
+		server.MarkPollRead)
+	server.router.POST(fmt.Sprintf(cEndpointFormat, cAPIVersion,
+		"badge_reset"), server.ResetBadge)
+	server.router.POST(fmt.Sprintf(cEndpointFormat, cAPIVersion, "invite"),
+		server.PostInviteLink)
+	server.router.GET(fmt.Sprintf(cEndpointFormat, cAPIVersion, "invites"),
+		server.GetInviteLinks)
+	server.router.DELETE(fmt.Sprintf(cEndpointFormat, cAPIVersion, "invite"),
+		server.RevokeInviteLink)
+	server.router.POST(fmt.Sprintf(cEndpointFormat, cAPIVersion, "join"),
+		server.RedeemInviteLink)
+	server.router.PUT(fmt.Sprintf(cEndpointFormat, cAPIVersion, "guests"),
+		server.UpdateGuestSettings)
+	server.router.DELETE(fmt.Sprintf(cEndpointFormat, cAPIVersion, "guest"),
+		server.RemoveGuest)
+
+	// the public web pages for guests
+	server.router.GET("/guest/:token", server.GuestPage)
+	server.router.POST("/guest/:token/join", server.JoinAsGuest)
+	server.router.POST("/guest/:token/vote", server.GuestVote)
+	server.router.POST("/guest/:token/unvote", server.GuestUndoVote)
+	server.router.GET("/guest/:token/snapshot.json", server.GuestPollSnapshot)
+
+	// the stored images, which are public
+	server.router.GET(cImagesPath+"*"+cImagePathParam, server.GetImage)
+
+	// the calendar feeds, authenticated by their token
+	server.router.GET("/calendar/:"+cTokenParam, server.CalendarFeed)
+
+	// the web client for registered users
+	server.router.GET("/web/", server.WebIndex)
+	server.router.GET("/web/login", server.WebLoginPage)
+	server.router.POST("/web/login", server.WebLogin)
+	server.router.POST("/web/logout", server.WebLogout)
+	server.router.GET("/web/polls", server.WebPolls)
+	server.router.GET("/web/poll/:id", server.WebPoll)
+	server.router.GET("/web/poll/:id/snapshot.json", server.WebPollSnapshot)
+	server.router.POST("/web/poll/:id/vote", server.WebVote)
+	server.router.POST("/web/poll/:id/unvote", server.WebUndoVote)
+	server.router.GET("/web/new", server.WebNewPollPage)
+	server.router.POST("/web/new", server.WebNewPoll)
 	server.logger.Log(cHTTPServerTag, "Starting HTTP server", "::1")
 	err = http.ListenAndServe(server.port, &server.router)
 	return err
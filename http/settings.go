@@ -4,8 +4,22 @@ import "time"
 
 const (
 	cPollListMax        = 20
+	cPollPageSizeMax    = 100
+	cClosingSoonWindow  = time.Hour * 24
 	cBulkPollMax        = cPollListMax
 	cBulkUserMax        = cBulkPollMax
 	cMinPollClosingTime = time.Second * 10
 	cMaxPollClosingTime = time.Hour * 168
+	cWebSessionDuration = time.Hour * 24 * 30
+	cMaxDisplayNameLen  = 64
+	cMinNudgeInterval   = time.Hour * 4
+	cCommentListMax     = 50
+	cMaxCommentLen      = 2000
+	cEventTitleLen      = 100
+	cMaxEmojiLen        = 16
+	cSearchResultMax    = 20
+	cSearchWordsMax     = 8
 )
+
+/* The reminders sent before a poll closes, unless configured otherwise. */
+var vDefaultReminderOffsets = []time.Duration{time.Hour * 24, time.Hour}
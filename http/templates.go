@@ -0,0 +1,244 @@
+package http
+
+import (
+	"html/template"
+	"strings"
+)
+
+const (
+	cErrorTemplate   = "error"
+	cGuestTemplate   = "guest"
+	cLoginTemplate   = "login"
+	cPollsTemplate   = "polls"
+	cPollTemplate    = "poll"
+	cNewPollTemplate = "newpoll"
+)
+
+const cLayoutHTML = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{template "title" .}} - Polly</title>
+<style>
+body { font-family: sans-serif; max-width: 40em; margin: 0 auto; padding: 1em; }
+table { border-collapse: collapse; width: 100%; }
+td { padding: 0.4em; border-bottom: 1px solid #ddd; }
+.count { text-align: right; font-weight: bold; }
+.voters { color: #777; font-size: 0.9em; }
+.error { color: #b00; }
+form.inline { display: inline; }
+</style>
+</head>
+<body>
+{{template "content" .}}
+</body>
+</html>
+{{define "nav"}}
+<p>{{.User.DisplayName}} &middot; <a href="/web/polls">Polls</a> &middot;
+<a href="/web/new">New poll</a> &middot;
+<form class="inline" method="post" action="/web/logout">
+<input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+<button>Log out</button>
+</form></p>
+{{end}}
+{{define "poll"}}
+<div id="poll" data-snapshot-url="{{.ActionPrefix}}/snapshot.json"
+data-sequence-number="{{.Poll.SequenceNumber}}">
+<h1>{{.Poll.Title}}</h1>
+<p>{{if .Poll.Closed}}Closed on{{else}}Closes on{{end}} {{.Poll.ClosingDate}}</p>
+{{if .Poll.ResultsHidden}}<p class="voters">Results are hidden for now.</p>{{end}}
+<table>
+{{range .Poll.Options}}
+<tr>
+<td>{{.Value}}<div class="voters">{{join .Voters ", "}}</div></td>
+<td class="count">{{if $.Poll.ResultsHidden}}?{{else}}{{.Votes}}{{end}}</td>
+<td>
+{{if not $.Poll.Closed}}
+{{if .MyVoteID}}
+<form class="inline" method="post" action="{{$.ActionPrefix}}/unvote">
+<input type="hidden" name="csrf_token" value="{{$.CSRFToken}}">
+<input type="hidden" name="vote_id" value="{{.MyVoteID}}">
+<button>Undo</button>
+</form>
+{{else}}
+<form class="inline" method="post" action="{{$.ActionPrefix}}/vote">
+<input type="hidden" name="csrf_token" value="{{$.CSRFToken}}">
+<input type="hidden" name="option_id" value="{{.ID}}">
+<button>Vote</button>
+</form>
+{{end}}
+{{end}}
+</td>
+</tr>
+{{end}}
+</table>
+{{if and .Poll.AddOptions (not .Poll.Closed)}}
+<form method="post" action="{{.ActionPrefix}}/vote">
+<input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+<input name="value" placeholder="Add an option" required>
+<button>Vote</button>
+</form>
+{{end}}
+<h2>Participants</h2>
+<ul>
+{{range .Poll.Participants}}<li>{{.DisplayName}}{{if .Guest}} (guest){{end}}</li>
+{{end}}
+</ul>
+</div>
+{{if not .Poll.Closed}}
+<script>
+(function() {
+	var poll = document.getElementById("poll");
+	setInterval(function() {
+		var request = new XMLHttpRequest();
+		request.onload = function() {
+			if (request.status != 200) {
+				return;
+			}
+
+			var snapshot = JSON.parse(request.responseText);
+			if (String(snapshot.sequence_number) !=
+				poll.dataset.sequenceNumber) {
+				location.reload();
+			}
+		};
+		request.open("GET", poll.dataset.snapshotUrl);
+		request.send();
+	}, 5000);
+})();
+</script>
+{{end}}
+{{end}}`
+
+const cErrorHTML = `{{define "title"}}Error{{end}}
+{{define "content"}}
+<h1>Something went wrong</h1>
+<p class="error">{{.Message}} ({{.Code}})</p>
+{{end}}`
+
+const cGuestHTML = `{{define "title"}}{{if .Poll}}{{.Poll.Title}}{{else}}Join poll{{end}}{{end}}
+{{define "content"}}
+{{if .Guest}}
+<p>Voting as <strong>{{.Guest.DisplayName}}</strong>.</p>
+{{template "poll" .}}
+{{else}}
+<h1>You're invited to a poll</h1>
+<form method="post" action="/guest/{{.Token}}/join">
+<label>Your name <input name="display_name" maxlength="64" required></label>
+<button>Join</button>
+</form>
+{{end}}
+{{end}}`
+
+const cLoginHTML = `{{define "title"}}Log in{{end}}
+{{define "content"}}
+<h1>Log in to Polly</h1>
+<form id="login" method="post" action="/web/login">
+{{if .FacebookAppID}}
+<input type="hidden" name="access_token" id="access_token">
+<button type="button" id="facebook">Log in with Facebook</button>
+{{else}}
+<label>Facebook access token <input name="access_token" required></label>
+<button>Log in</button>
+{{end}}
+</form>
+{{if .FacebookAppID}}
+<script src="https://connect.facebook.net/en_US/sdk.js"></script>
+<script>
+FB.init({appId: "{{.FacebookAppID}}", version: "v2.8"});
+document.getElementById("facebook").onclick = function() {
+	FB.login(function(response) {
+		if (response.authResponse) {
+			document.getElementById("access_token").value =
+				response.authResponse.accessToken;
+			document.getElementById("login").submit();
+		}
+	});
+};
+</script>
+{{end}}
+{{end}}`
+
+const cPollsHTML = `{{define "title"}}Your polls{{end}}
+{{define "content"}}
+{{template "nav" .}}
+<h1>Your polls</h1>
+<table>
+{{range .Polls}}
+<tr>
+<td><a href="/web/poll/{{.ID}}">{{.Title}}</a></td>
+<td>{{if .Closed}}Closed{{else}}Closes {{.ClosingDate}}{{end}}</td>
+</tr>
+{{else}}
+<tr><td>No polls yet.</td></tr>
+{{end}}
+</table>
+<p>
+{{if .PrevPage}}<a href="/web/polls?page={{.PrevPage}}">Newer</a>{{end}}
+{{if .NextPage}}<a href="/web/polls?page={{.NextPage}}">Older</a>{{end}}
+</p>
+{{end}}`
+
+const cPollHTML = `{{define "title"}}{{.Poll.Title}}{{end}}
+{{define "content"}}
+{{template "nav" .}}
+{{template "poll" .}}
+{{end}}`
+
+const cNewPollHTML = `{{define "title"}}New poll{{end}}
+{{define "content"}}
+{{template "nav" .}}
+<h1>New poll</h1>
+<form method="post" action="/web/new">
+<input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+<input type="hidden" name="tz_offset" id="tz_offset" value="0">
+<p><label>Question <input name="title" required></label></p>
+<p><label>Options, one per line<br>
+<textarea name="options" rows="5" cols="40"></textarea></label></p>
+<p><label><input type="checkbox" name="open" value="1">
+Participants may add options</label></p>
+<p><label>Closes at
+<input type="datetime-local" name="closing_date" required></label></p>
+{{if .Groups}}
+<p><label>Group <select name="group_id">
+<option value="0">None</option>
+{{range .Groups}}<option value="{{.ID}}">{{.Name}}</option>
+{{end}}
+</select></label></p>
+{{end}}
+<p><label><input type="checkbox" name="allow_guests" value="1">
+Allow guests</label></p>
+<p><label><input type="checkbox" name="anonymous" value="1">
+Secret ballot</label></p>
+<p><label>Show results <select name="results_visibility">
+<option value="0">Always</option>
+<option value="1">After voting</option>
+<option value="2">After closing</option>
+</select></label></p>
+<button>Create</button>
+</form>
+<script>
+document.getElementById("tz_offset").value = new Date().getTimezoneOffset();
+</script>
+{{end}}`
+
+var vWebTemplateFuncs = template.FuncMap{
+	"join": strings.Join,
+}
+
+var vWebTemplates = map[string]*template.Template{
+	cErrorTemplate:   newWebTemplate(cErrorHTML),
+	cGuestTemplate:   newWebTemplate(cGuestHTML),
+	cLoginTemplate:   newWebTemplate(cLoginHTML),
+	cPollsTemplate:   newWebTemplate(cPollsHTML),
+	cPollTemplate:    newWebTemplate(cPollHTML),
+	cNewPollTemplate: newWebTemplate(cNewPollHTML),
+}
+
+/* Parses the given page on top of the shared layout. */
+func newWebTemplate(page string) *template.Template {
+	layout := template.Must(template.New("layout").Funcs(vWebTemplateFuncs).
+		Parse(cLayoutHTML))
+	return template.Must(layout.Parse(page))
+}
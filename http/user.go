@@ -126,15 +126,22 @@ func (server *sServer) UpdateUser(writer http.ResponseWriter,
 		}
 	}
 
-	// update profile pic
-	if updateUserMsg.ProfilePic != nil {
+	// update profile pic, an uploaded picture that is replaced is deleted
+	if updateUserMsg.ProfilePic != nil &&
+		*(updateUserMsg.ProfilePic) != user.ProfilePic {
+
+		previousKey := user.ProfilePicKey
 		user.ProfilePic = *(updateUserMsg.ProfilePic)
-		err = server.db.UpdateProfilePic(user.ID, user.ProfilePic)
+		user.ProfilePicKey = ""
+		err = server.db.UpdateProfilePic(user.ID, user.ProfilePic,
+			user.ProfilePicKey)
 		if err != nil {
 			server.respondWithError(ERR_INT_DB_UPDATE, err, cUpdateUserTag,
 				writer, request)
 			return
 		}
+
+		server.deleteStoredImage(previousKey)
 	}
 
 	// create the response body
@@ -175,18 +182,11 @@ func (server *sServer) AddUser(writer http.ResponseWriter,
 		return
 	}
 
-	// retrieve the creator of the poll
-	creatorID, err := server.db.GetPollCreatorID(addUserMsg.PollID)
-	if err != nil {
-		server.respondWithError(ERR_INT_DB_GET, err, cAddUserTag, writer,
-			request)
-		return
-	}
-
-	// make sure the user is the creator of the poll
-	if creatorID != user.ID {
-		server.respondWithError(ERR_ILL_NOT_CREATOR, nil, cAddUserTag, writer,
-			request)
+	// make sure the user is allowed to manage the participants
+	_, errCode = server.authorizePollAction(user.ID, addUserMsg.PollID,
+		cPermissionManageParticipants)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cAddUserTag, writer, request)
 		return
 	}
 
@@ -247,7 +247,7 @@ func (server *sServer) AddUser(writer http.ResponseWriter,
 		// update the poll last updated and seq number
 		err = database.UpdatePollTX(addUserMsg.PollID, currentTime,
 			polly.EVENT_TYPE_NEW_PARTICIPANT, newUser.DisplayName, newUser.ID,
-			question.Title, tx)
+			question.Title, user.ID, tx)
 		if err != nil {
 			if pqErr, ok := err.(*pq.Error); ok &&
 				pqErr.Code == database.ERR_SERIALIZATION_FAILURE {
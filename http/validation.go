@@ -25,22 +25,39 @@ func isValidPollMessage(db *database.Database, pollMsg *polly.PollMessage,
 	pollMsg.MetaData.LastUpdated = nowMillis
 
 	// validate the closing time
-	closingDate := time.Unix(pollMsg.MetaData.ClosingDate/1000, 0)
-	openDuration := closingDate.Sub(now)
-	if openDuration < cMinPollClosingTime ||
-		openDuration > cMaxPollClosingTime {
-
+	if !isValidClosingDate(pollMsg.MetaData.ClosingDate, now) {
 		return ERR_BAD_CLOSING_DATE
 	}
 
+	// validate when the results are shown
+	if !isValidResultsVisibility(pollMsg.MetaData.ResultsVisibility) {
+		return ERR_BAD_RESULTS_VISIBILITY
+	}
+
 	// validate question type has fitting options
 	switch pollMsg.Question.Type {
+	case polly.QUESTION_TYPE_DATE:
+		for _, option := range pollMsg.Options {
+			if option.StartDate <= 0 || option.Duration < 0 {
+				return ERR_BAD_OPTION_DATE
+			}
+		}
+		fallthrough
 	case polly.QUESTION_TYPE_MOVIE_MC:
 		fallthrough
 	case polly.QUESTION_TYPE_MC:
 		if pollMsg.Options == nil || len(pollMsg.Options) == 0 {
 			return ERR_BAD_EMPTY_POLL
 		}
+	case polly.QUESTION_TYPE_LOCATION:
+		for idx := range pollMsg.Options {
+			option := &pollMsg.Options[idx]
+			if !isValidLocation(option.Latitude, option.Longitude) {
+				return ERR_BAD_LOCATION
+			}
+
+			option.Address = strings.TrimSpace(option.Address)
+		}
 	case polly.QUESTION_TYPE_MOVIE_OPEN:
 		fallthrough
 	case polly.QUESTION_TYPE_OPEN:
@@ -57,6 +74,7 @@ func isValidPollMessage(db *database.Database, pollMsg *polly.PollMessage,
 
 	// don't accept empty option values and set the option sequence numbers
 	pollSequenceNumber := 0
+	imageIDs := make(map[int64]bool)
 	numOptions := len(pollMsg.Options)
 	for i := 0; i < numOptions; i++ {
 		pollMsg.Options[i].Value = strings.TrimSpace(pollMsg.Options[i].Value)
@@ -64,6 +82,17 @@ func isValidPollMessage(db *database.Database, pollMsg *polly.PollMessage,
 			return ERR_BAD_EMPTY_OPTION
 		}
 
+		// an image can only be used by one option
+		imageID := pollMsg.Options[i].ImageID
+		if imageID != 0 && imageIDs[imageID] {
+			return ERR_BAD_OPTION_IMAGE
+		} else if errCode := isValidOptionImage(db, &pollMsg.Options[i],
+			creatorID); errCode != NO_ERR {
+			return errCode
+		}
+
+		imageIDs[imageID] = true
+
 		pollMsg.Options[i].SequenceNumber = pollSequenceNumber
 		pollSequenceNumber++
 	}
@@ -96,19 +125,220 @@ func isValidPollMessage(db *database.Database, pollMsg *polly.PollMessage,
 			containsCreator = true
 		}
 
+		// only the creator owns the poll, the other roles can be handed out
+		if !isValidRole(pollMsg.Participants[i].Role) {
+			return ERR_BAD_ROLE
+		}
+
 		// add participant to map of particpants
 		participantsMap[pollMsg.Participants[i].ID] = true
 	}
 
+	// expand the referenced group into participants
+	if pollMsg.MetaData.GroupID != 0 {
+		group, err := db.GetGroupByID(pollMsg.MetaData.GroupID)
+		if err != nil {
+			return ERR_BAD_NO_GROUP
+		} else if group.OwnerID != creatorID {
+			return ERR_ILL_GROUP_ACCESS
+		}
+
+		members, err := db.GetGroupMembersByGroupID(group.ID)
+		if err != nil {
+			return ERR_INT_DB_GET
+		}
+
+		for _, member := range members {
+
+			// members that were also listed explicitly are not duplicates
+			if participantsMap[member.UserID] {
+				continue
+			}
+
+			user, err := db.GetPublicUserByID(member.UserID)
+			if err != nil {
+				return ERR_BAD_NO_USER
+			}
+
+			if user.ID == creatorID {
+				containsCreator = true
+			}
+
+			pollMsg.Participants = append(pollMsg.Participants, *user)
+			participantsMap[user.ID] = true
+		}
+	}
+
 	// make sure user is a participant
 	if !containsCreator {
 		return ERR_BAD_NO_CREATOR
 	}
 
+	// make the creator the owner of the poll
+	for i := range pollMsg.Participants {
+		if pollMsg.Participants[i].ID == creatorID {
+			pollMsg.Participants[i].Role = polly.PARTICIPANT_ROLE_OWNER
+		}
+	}
+
 	return NO_ERR
 }
 
+/*
+ * Validates a poll template message by checking its recurrence and open
+ * duration. The question, options and participants are validated like a poll
+ * created from the template right now. The members of the group are looked up
+ * again for every poll, so they aren't added to the template participants.
+ */
+func isValidPollTemplateMessage(db *database.Database,
+	templateMsg *polly.PollTemplateMessage, creatorID int64) int {
+
+	now := time.Now()
+	template := &templateMsg.MetaData
+	template.CreatorID = creatorID
+
+	// validate the time zone the recurrence rule is evaluated in
+	if _, err := time.LoadLocation(template.TimeZone); err != nil {
+		return ERR_BAD_TIME_ZONE
+	}
+
+	// validate the recurrence rule, it has to produce a next run
+	if template.Recurrence != polly.RECURRENCE_DAILY &&
+		template.Recurrence != polly.RECURRENCE_WEEKLY &&
+		template.Recurrence != polly.RECURRENCE_CRON {
+		return ERR_BAD_RECURRENCE
+	} else if _, err := nextTemplateRun(template, now); err != nil {
+		return ERR_BAD_RECURRENCE
+	}
+
+	// validate how long the polls stay open
+	openDuration := time.Duration(template.OpenDuration) * time.Millisecond
+	if openDuration < cMinPollClosingTime ||
+		openDuration > cMaxPollClosingTime {
+		return ERR_BAD_OPEN_DURATION
+	}
+
+	// validate the poll the template describes
+	pollMsg := newPollMessageFromTemplate(templateMsg, now)
+	if errCode := isValidPollMessage(db, pollMsg, creatorID); errCode !=
+		NO_ERR {
+		return errCode
+	}
+
+	// take over the trimmed values and display names, but not the owner role
+	// of the creator, which the polls of the template would refuse
+	template.Title = pollMsg.Question.Title
+	for i := range templateMsg.Options {
+		templateMsg.Options[i].Value = pollMsg.Options[i].Value
+		templateMsg.Options[i].Address = pollMsg.Options[i].Address
+	}
+
+	for i := range templateMsg.Participants {
+		role := templateMsg.Participants[i].Role
+		templateMsg.Participants[i] = pollMsg.Participants[i]
+		templateMsg.Participants[i].Role = role
+	}
+
+	return NO_ERR
+}
+
+/*
+ * Validates a group message by checking its name and members. The display
+ * names and profile pictures of the members are set in this function and the
+ * owner is added to the members if he or she wasn't listed.
+ */
+func isValidGroupMessage(db *database.Database, groupMsg *polly.GroupMessage,
+	ownerID int64) int {
+
+	// don't accept empty group names
+	groupMsg.MetaData.Name = strings.TrimSpace(groupMsg.MetaData.Name)
+	if len(groupMsg.MetaData.Name) == 0 {
+		return ERR_BAD_EMPTY_GROUP_NAME
+	}
+
+	containsOwner := false
+	membersMap := make(map[int64]bool)
+	numMembers := len(groupMsg.Members)
+	for i := 0; i < numMembers; i++ {
+
+		// check for duplicate members
+		if membersMap[groupMsg.Members[i].ID] {
+			return ERR_BAD_DUPLICATE_PARTICIPANT
+		}
+
+		// check if user exists
+		dbUser, err := db.GetUserByID(groupMsg.Members[i].ID)
+		if err != nil {
+			return ERR_BAD_NO_USER
+		}
+
+		groupMsg.Members[i].DisplayName = dbUser.DisplayName
+		groupMsg.Members[i].ProfilePic = dbUser.ProfilePic
+
+		if groupMsg.Members[i].ID == ownerID {
+			containsOwner = true
+		}
+
+		membersMap[groupMsg.Members[i].ID] = true
+	}
+
+	// make sure the owner is a member
+	if !containsOwner {
+		owner, err := db.GetPublicUserByID(ownerID)
+		if err != nil {
+			return ERR_BAD_NO_USER
+		}
+
+		groupMsg.Members = append(groupMsg.Members, *owner)
+	}
+
+	groupMsg.MetaData.OwnerID = ownerID
+	return NO_ERR
+}
+
+/* Checks whether a poll closing at the given date stays open long enough. */
+func isValidClosingDate(closingDate int64, now time.Time) bool {
+	openDuration := time.Unix(closingDate/1000, 0).Sub(now)
+	return openDuration >= cMinPollClosingTime &&
+		openDuration <= cMaxPollClosingTime
+}
+
+/* Checks whether the given role can be handed out, ownership is transferred. */
+func isValidRole(role int) bool {
+	return role == polly.PARTICIPANT_ROLE_VOTER ||
+		role == polly.PARTICIPANT_ROLE_VIEWER ||
+		role == polly.PARTICIPANT_ROLE_ADMIN
+}
+
+func isValidResultsVisibility(resultsVisibility int) bool {
+	return resultsVisibility == polly.RESULTS_VISIBILITY_ALWAYS ||
+		resultsVisibility == polly.RESULTS_VISIBILITY_AFTER_VOTING ||
+		resultsVisibility == polly.RESULTS_VISIBILITY_AFTER_CLOSE
+}
+
 func isValidDeviceType(deviceType int) bool {
 	return (deviceType == polly.DEVICE_TYPE_ANDROID ||
 		deviceType == polly.DEVICE_TYPE_IPHONE)
 }
+
+/*
+ * Validates the image the given option refers to, which has to be an upload of
+ * the given user that isn't used by another option yet. The image is set on
+ * the option.
+ */
+func isValidOptionImage(db *database.Database, option *polly.Option,
+	userID int64) int {
+
+	option.Image = nil
+	if option.ImageID == 0 {
+		return NO_ERR
+	}
+
+	image, err := db.GetImageByID(option.ImageID)
+	if err != nil || image.UserID != userID || image.OptionID != 0 {
+		return ERR_BAD_OPTION_IMAGE
+	}
+
+	option.Image = image
+	return NO_ERR
+}
@@ -6,6 +6,7 @@ import (
 	"math/rand"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/roxot/polly"
@@ -40,73 +41,125 @@ func (server *sServer) Vote(writer http.ResponseWriter, request *http.Request,
 		return
 	}
 
+	// cast the vote
+	response, errCode, err := server.castVote(user, &voteMsg, cVoteTag)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, err, cVoteTag, writer, request)
+		return
+	}
+
+	// marshal the response body
+	responseBody, err := json.MarshalIndent(response, "", "\t")
+	if err != nil {
+		server.respondWithError(ERR_INT_MARSHALL, err, cVoteTag, writer,
+			request)
+		return
+	}
+
+	// send the response message
+	err = server.respondWithJSONBody(writer, responseBody)
+	if err != nil {
+		server.respondWithError(ERR_INT_WRITE, err, cVoteTag, writer, request)
+		return
+	}
+
+}
+
+/*
+ * Casts a vote for the given user, creating a new option first if the vote
+ * message asks for it. Any earlier votes of the user in the poll are removed.
+ * The tag is used for logging. Returns the response message, an API error code
+ * and the underlying error.
+ */
+func (server *sServer) castVote(user *polly.PrivateUser,
+	voteMsg *polly.VoteMessage, tag string) (*polly.VoteResponseMessage, int,
+	error) {
+
+	var err error
+
 	// retrieve the poll id belonging to the option or question id
 	var pollID int64
 	var optionTitle string
+	var option polly.Option
 	switch voteMsg.Type {
 	case polly.VOTE_TYPE_NEW:
 		question, err := server.db.GetQuestionByID(voteMsg.ID)
 		if err != nil {
-			server.respondWithError(ERR_BAD_NO_QUESTION, err, cVoteTag, writer,
-				request)
-			return
-
+			return nil, ERR_BAD_NO_QUESTION, err
 		}
 
-		pollID = question.ID
+		pollID = question.PollID
 		if question.Type != polly.QUESTION_TYPE_OPEN &&
-			question.Type != polly.QUESTION_TYPE_MOVIE_OPEN {
-
-			server.respondWithError(ERR_ILL_ADD_OPTION, nil, cVoteTag, writer,
-				request)
-			return
-		} else if len(voteMsg.Value) == 0 {
-			server.respondWithError(ERR_BAD_EMPTY_OPTION, nil, cVoteTag, writer,
-				request)
-			return
+			question.Type != polly.QUESTION_TYPE_MOVIE_OPEN &&
+			question.Type != polly.QUESTION_TYPE_LOCATION {
+
+			return nil, ERR_ILL_ADD_OPTION, nil
+		} else if len(voteMsg.Value) == 0 && len(voteMsg.MovieID) == 0 {
+			return nil, ERR_BAD_EMPTY_OPTION, nil
 		}
 
-		optionTitle = voteMsg.Value
+		// the image has to be an unused upload of the user
+		option.ImageID = voteMsg.ImageID
+		errCode := isValidOptionImage(&server.db, &option, user.ID)
+		if errCode != NO_ERR {
+			return nil, errCode, nil
+		}
+
+		// new movies have to be known to the movie provider
+		option.Value = voteMsg.Value
+		if question.Type == polly.QUESTION_TYPE_MOVIE_OPEN {
+			option.MovieID = voteMsg.MovieID
+			errCode, err := server.resolveMovieOption(&option)
+			if errCode != NO_ERR {
+				return nil, errCode, err
+			}
+		}
+
+		// new locations have to be on the map
+		if question.Type == polly.QUESTION_TYPE_LOCATION {
+			if !isValidLocation(voteMsg.Latitude, voteMsg.Longitude) {
+				return nil, ERR_BAD_LOCATION, nil
+			}
+
+			option.Latitude = voteMsg.Latitude
+			option.Longitude = voteMsg.Longitude
+			option.Address = strings.TrimSpace(voteMsg.Address)
+		}
+
+		optionTitle = option.Value
 	case polly.VOTE_TYPE_UPVOTE:
 		option, err := server.db.GetOptionByID(voteMsg.ID)
 		if err != nil {
-			server.respondWithError(ERR_BAD_NO_OPTION, err, cVoteTag, writer,
-				request)
-			return
+			return nil, ERR_BAD_NO_OPTION, err
 		}
 
 		pollID, optionTitle = option.PollID, option.Value
 	default:
-		server.respondWithError(ERR_BAD_VOTE_TYPE, err, cVoteTag, writer,
-			request)
-		return
+		return nil, ERR_BAD_VOTE_TYPE, err
 	}
 
-	// make sure the user is allowed to vote
-	if !server.hasPollAccess(user.ID, pollID) {
-		server.respondWithError(ERR_ILL_POLL_ACCESS, nil, cVoteTag, writer,
-			request)
-		return
+	// make sure the user is allowed to vote, viewers can only watch
+	_, errCode := server.authorizePollAction(user.ID, pollID, cPermissionVote)
+	if errCode != NO_ERR {
+		return nil, errCode, nil
 	}
 
-	// retrieve the closing date
-	closingDate, err := server.db.GetClosingDate(pollID)
+	// retrieve the poll
+	poll, err := server.db.GetPollByID(pollID)
 	if err != nil {
-		server.respondWithError(ERR_INT_DB_GET, err, cVoteTag, writer,
-			request)
-		return
+		return nil, ERR_INT_DB_GET, err
 	}
 
 	// make sure the poll hasn't closed yet
 	currentTime := time.Now().UnixNano() / 1000000
-	if currentTime > closingDate {
-		server.respondWithError(ERR_ILL_POLL_CLOSED, nil, cVoteTag, writer,
-			request)
-		return
+	if currentTime > poll.ClosingDate {
+		return nil, ERR_ILL_POLL_CLOSED, nil
 	}
 
+	// anonymous polls don't record the voter as the last event user
+	eventUser, eventUserID := eventUserForPoll(user, poll)
+
 	var optionID int64
-	var option polly.Option
 	var snapshot *polly.PollSnapshot
 	var vote polly.Vote
 	retryTransaction := true
@@ -117,37 +170,31 @@ func (server *sServer) Vote(writer http.ResponseWriter, request *http.Request,
 		tx, err := server.db.Begin()
 		if err != nil {
 			tx.Rollback()
-			server.respondWithError(ERR_INT_DB_TX_BEGIN, err, cVoteTag, writer,
-				request)
-			return
+			return nil, ERR_INT_DB_TX_BEGIN, err
 		}
 
 		// set the transaction isolation level
 		_, err = tx.Exec("set transaction isolation level serializable;")
 		if err != nil {
 			tx.Rollback()
-			server.respondWithError(ERR_INT_DB_TX_SET_TX_LEVEL, err,
-				cVoteTag, writer, request)
-			return
+			return nil, ERR_INT_DB_TX_SET_TX_LEVEL, err
 		}
 
 		// update the poll last updated and seq number
 		// user, optionID, voteMsg.Type
 		err = database.UpdatePollTX(pollID, currentTime, voteMsg.Type,
-			user.DisplayName, user.ID, optionTitle, tx)
+			eventUser, eventUserID, optionTitle, user.ID, tx)
 		if err != nil {
 			if pqErr, ok := err.(*pq.Error); ok &&
 				pqErr.Code == database.ERR_SERIALIZATION_FAILURE {
-				server.logger.Log(cVoteTag, fmt.Sprintf("%d: %s",
+				server.logger.Log(tag, fmt.Sprintf("%d: %s",
 					transactionNumber, "Serialization failure, retrying..."),
 					"::1")
 				continue
 			} else {
 
 				tx.Rollback()
-				server.respondWithError(ERR_INT_DB_UPDATE, err, cVoteTag,
-					writer, request)
-				return
+				return nil, ERR_INT_DB_UPDATE, err
 			}
 		}
 
@@ -155,8 +202,7 @@ func (server *sServer) Vote(writer http.ResponseWriter, request *http.Request,
 		snapshot, err = database.GetPollSnapshotTX(pollID, tx)
 		if err != nil {
 			tx.Rollback()
-			server.respondWithError(ERR_INT_DB_GET, err, cVoteTag, writer, request)
-			return
+			return nil, ERR_INT_DB_GET, err
 		}
 
 		// remove all existing votes of the user
@@ -164,15 +210,13 @@ func (server *sServer) Vote(writer http.ResponseWriter, request *http.Request,
 		if err != nil {
 			if pqErr, ok := err.(*pq.Error); ok &&
 				pqErr.Code == database.ERR_SERIALIZATION_FAILURE {
-				server.logger.Log(cVoteTag, fmt.Sprintf("%d: %s",
+				server.logger.Log(tag, fmt.Sprintf("%d: %s",
 					transactionNumber, "Serialization failure, retrying..."),
 					"::1")
 				continue
 			} else {
 				tx.Rollback()
-				server.respondWithError(ERR_INT_DB_DELETE, err, cVoteTag, writer,
-					request)
-				return
+				return nil, ERR_INT_DB_DELETE, err
 			}
 		}
 
@@ -185,15 +229,13 @@ func (server *sServer) Vote(writer http.ResponseWriter, request *http.Request,
 			if err != nil {
 				if pqErr, ok := err.(*pq.Error); ok &&
 					pqErr.Code == database.ERR_SERIALIZATION_FAILURE {
-					server.logger.Log(cVoteTag, fmt.Sprintf("%d: %s",
+					server.logger.Log(tag, fmt.Sprintf("%d: %s",
 						transactionNumber,
 						"Serialization failure, retrying..."), "::1")
 					continue
 				} else {
 					tx.Rollback()
-					server.respondWithError(ERR_INT_DB_UPDATE, err, cVoteTag,
-						writer, request)
-					return
+					return nil, ERR_INT_DB_UPDATE, err
 				}
 			}
 
@@ -203,14 +245,24 @@ func (server *sServer) Vote(writer http.ResponseWriter, request *http.Request,
 			questionID := voteMsg.ID
 			option.PollID = pollID
 			option.QuestionID = questionID
-			option.Value = voteMsg.Value
 			option.SequenceNumber = snapshot.SequenceNumber
 			err = database.AddOptionTX(&option, tx)
 			if err != nil {
 				tx.Rollback()
-				server.respondWithError(ERR_INT_DB_ADD, err, cVoteTag, writer,
-					request)
-				return
+				return nil, ERR_INT_DB_ADD, err
+			}
+
+			// attach the image of the option
+			if option.ImageID != 0 {
+				attached, err := database.AttachImageTX(option.ImageID,
+					option.ID, tx)
+				if err != nil {
+					tx.Rollback()
+					return nil, ERR_INT_DB_UPDATE, err
+				} else if !attached {
+					tx.Rollback()
+					return nil, ERR_BAD_OPTION_IMAGE, nil
+				}
 			}
 
 			optionID = option.ID
@@ -225,28 +277,27 @@ func (server *sServer) Vote(writer http.ResponseWriter, request *http.Request,
 		err = database.AddVoteTX(&vote, tx)
 		if err != nil {
 			tx.Rollback()
-			server.respondWithError(ERR_INT_DB_ADD, err, cVoteTag, writer, request)
-			return
+			return nil, ERR_INT_DB_ADD, err
 		}
 
 		// commit the transaction
 		err = tx.Commit()
 		if err != nil {
 			tx.Rollback()
-			server.respondWithError(ERR_INT_DB_TX_COMMIT, err, cVoteTag, writer,
-				request)
-			return
+			return nil, ERR_INT_DB_TX_COMMIT, err
 		}
 
 		retryTransaction = false
 	}
 
-	// send a notification to other participants
+	// send a notification to other participants, revealing the vote only if
+	// the results are visible to all of them
+	resultsHidden := !database.AreResultsVisible(poll, false)
 	err = server.pushClient.NotifyForVote(&server.db, user, optionTitle, pollID,
-		voteMsg.Type)
+		voteMsg.Type, poll.Anonymous, resultsHidden)
 	if err != nil {
 		// TODO neaten up
-		server.logger.Log(cVoteTag, "Error notifying: "+err.Error(), "::1")
+		server.logger.Log(tag, "Error notifying: "+err.Error(), "::1")
 	}
 
 	// construct the response message
@@ -257,21 +308,15 @@ func (server *sServer) Vote(writer http.ResponseWriter, request *http.Request,
 		response.Option = &option
 	}
 
-	// marshal the response body
-	responseBody, err := json.MarshalIndent(response, "", "\t")
-	if err != nil {
-		server.respondWithError(ERR_INT_MARSHALL, err, cVoteTag, writer,
-			request)
-		return
-	}
-
-	// send the response message
-	err = server.respondWithJSONBody(writer, responseBody)
-	if err != nil {
-		server.respondWithError(ERR_INT_WRITE, err, cVoteTag, writer, request)
-		return
+	// include the results if the vote revealed them
+	if database.AreResultsVisible(poll, true) {
+		response.Tallies, err = server.db.GetTalliesByPollID(pollID)
+		if err != nil {
+			return nil, ERR_INT_DB_GET, err
+		}
 	}
 
+	return &response, NO_ERR, nil
 }
 
 func (server *sServer) UndoVote(writer http.ResponseWriter,
@@ -299,35 +344,65 @@ func (server *sServer) UndoVote(writer http.ResponseWriter,
 		return
 	}
 
-	// retrieve the vote object
-	vote, err := server.db.GetVoteByID(id)
+	// undo the vote
+	snapshot, errCode, err := server.undoVote(user, id, cUndoVoteTag)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, err, cUndoVoteTag, writer, request)
+		return
+	}
+
+	// marshal the response body
+	responseBody, err := json.MarshalIndent(snapshot, "", "\t")
 	if err != nil {
-		server.respondWithError(ERR_BAD_NO_VOTE, err, cUndoVoteTag, writer,
+		server.respondWithError(ERR_INT_MARSHALL, err, cUndoVoteTag, writer,
 			request)
 		return
 	}
 
-	// retrieve the closing date
-	closingDate, err := server.db.GetClosingDate(vote.PollID)
+	// send the response message
+	err = server.respondWithJSONBody(writer, responseBody)
 	if err != nil {
-		server.respondWithError(ERR_INT_DB_GET, err, cUndoVoteTag, writer,
+		server.respondWithError(ERR_INT_WRITE, err, cUndoVoteTag, writer,
 			request)
 		return
 	}
+}
+
+/*
+ * Removes the vote with the given identifier on behalf of the given user. The
+ * tag is used for logging. Returns a snapshot of the updated poll, an API error
+ * code and the underlying error.
+ */
+func (server *sServer) undoVote(user *polly.PrivateUser, id int64,
+	tag string) (*polly.PollSnapshot, int, error) {
+
+	// retrieve the vote object
+	vote, err := server.db.GetVoteByID(id)
+	if err != nil {
+		return nil, ERR_BAD_NO_VOTE, err
+	} else if vote.UserID != user.ID {
+		return nil, ERR_BAD_NO_VOTE, nil
+	}
+
+	// retrieve the poll
+	poll, err := server.db.GetPollByID(vote.PollID)
+	if err != nil {
+		return nil, ERR_INT_DB_GET, err
+	}
 
 	// make sure the poll hasn't closed yet
 	currentTime := time.Now().UnixNano() / 1000000
-	if currentTime > closingDate {
-		server.respondWithError(ERR_ILL_POLL_CLOSED, nil, cUndoVoteTag, writer,
-			request)
-		return
+	if currentTime > poll.ClosingDate {
+		return nil, ERR_ILL_POLL_CLOSED, nil
 	}
 
+	// anonymous polls don't record the voter as the last event user
+	eventUser, eventUserID := eventUserForPoll(user, poll)
+
 	// retrieve the belonging option object
 	option, err := server.db.GetOptionByID(vote.OptionID)
 	if err != nil {
-		server.respondWithError(ERR_INT_DB_GET, err, cUndoVoteTag, writer, request)
-		return
+		return nil, ERR_INT_DB_GET, err
 	}
 
 	var snapshot *polly.PollSnapshot
@@ -339,36 +414,31 @@ func (server *sServer) UndoVote(writer http.ResponseWriter,
 		tx, err := server.db.Begin()
 		if err != nil {
 			tx.Rollback()
-			server.respondWithError(ERR_INT_DB_TX_BEGIN, err, cUndoVoteTag,
-				writer, request)
-			return
+			return nil, ERR_INT_DB_TX_BEGIN, err
 		}
 
 		// set the transaction isolation level
 		_, err = tx.Exec("set transaction isolation level serializable;")
 		if err != nil {
 			tx.Rollback()
-			server.respondWithError(ERR_INT_DB_TX_SET_TX_LEVEL, err,
-				cUndoVoteTag, writer, request)
-			return
+			return nil, ERR_INT_DB_TX_SET_TX_LEVEL, err
 		}
 
 		// update the poll last updated and seq number
 		err = database.UpdatePollTX(vote.PollID, currentTime,
-			polly.EVENT_TYPE_UNDONE_VOTE, user.DisplayName, user.ID, option.Value, tx)
+			polly.EVENT_TYPE_UNDONE_VOTE, eventUser, eventUserID, option.Value,
+			user.ID, tx)
 		if err != nil {
 			if pqErr, ok := err.(*pq.Error); ok &&
 				pqErr.Code == database.ERR_SERIALIZATION_FAILURE {
-				server.logger.Log(cUndoVoteTag, fmt.Sprintf("%d: %s",
+				server.logger.Log(tag, fmt.Sprintf("%d: %s",
 					transactionNumber, "Serialization failure, retrying..."),
 					"::1")
 				continue
 			} else {
 
 				tx.Rollback()
-				server.respondWithError(ERR_INT_DB_UPDATE, err, cUndoVoteTag,
-					writer, request)
-				return
+				return nil, ERR_INT_DB_UPDATE, err
 			}
 		}
 
@@ -376,9 +446,7 @@ func (server *sServer) UndoVote(writer http.ResponseWriter,
 		snapshot, err = database.GetPollSnapshotTX(vote.PollID, tx)
 		if err != nil {
 			tx.Rollback()
-			server.respondWithError(ERR_INT_DB_GET, err, cUndoVoteTag, writer,
-				request)
-			return
+			return nil, ERR_INT_DB_GET, err
 		}
 
 		// delete the vote
@@ -386,15 +454,13 @@ func (server *sServer) UndoVote(writer http.ResponseWriter,
 		if err != nil {
 			if pqErr, ok := err.(*pq.Error); ok &&
 				pqErr.Code == database.ERR_SERIALIZATION_FAILURE {
-				server.logger.Log(cUndoVoteTag, fmt.Sprintf("%d: %s",
+				server.logger.Log(tag, fmt.Sprintf("%d: %s",
 					transactionNumber, "Serialization failure, retrying..."),
 					"::1")
 				continue
 			} else {
 				tx.Rollback()
-				server.respondWithError(ERR_BAD_NO_VOTE, err, cUndoVoteTag,
-					writer, request)
-				return
+				return nil, ERR_BAD_NO_VOTE, err
 			}
 		}
 
@@ -402,35 +468,34 @@ func (server *sServer) UndoVote(writer http.ResponseWriter,
 		err = tx.Commit()
 		if err != nil {
 			tx.Rollback()
-			server.respondWithError(ERR_INT_DB_TX_COMMIT, err, cUndoVoteTag,
-				writer, request)
-			return
+			return nil, ERR_INT_DB_TX_COMMIT, err
 		}
 
 		retryTransaction = false
 	}
 
 	// notify the poll participants
+	resultsHidden := !database.AreResultsVisible(poll, false)
 	err = server.pushClient.NotifyForUndoneVote(&server.db, user, option.Value,
-		vote.PollID)
+		vote.PollID, poll.Anonymous, resultsHidden)
 	if err != nil {
 		// TODO neaten up
-		server.logger.Log(cUndoVoteTag, "Error notifying: "+err.Error(), "::1")
+		server.logger.Log(tag, "Error notifying: "+err.Error(), "::1")
 	}
 
-	// marshal the response body
-	responseBody, err := json.MarshalIndent(snapshot, "", "\t")
-	if err != nil {
-		server.respondWithError(ERR_INT_MARSHALL, err, cUndoVoteTag, writer,
-			request)
-		return
-	}
+	return snapshot, NO_ERR, nil
+}
 
-	// send the response message
-	err = server.respondWithJSONBody(writer, responseBody)
-	if err != nil {
-		server.respondWithError(ERR_INT_WRITE, err, cUndoVoteTag, writer,
-			request)
-		return
+/*
+ * Returns the display name and identifier to record as the last event user of
+ * a vote in the given poll, which are left empty for anonymous polls.
+ */
+func eventUserForPoll(user *polly.PrivateUser, poll *polly.Poll) (string,
+	int64) {
+
+	if poll.Anonymous {
+		return "", 0
 	}
+
+	return user.DisplayName, user.ID
 }
@@ -0,0 +1,253 @@
+package http
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/roxot/polly"
+
+	"github.com/dchest/uniuri"
+	"github.com/satori/go.uuid"
+)
+
+const (
+	cSessionCookie   = "polly_session"
+	cCSRFTokenField  = "csrf_token"
+	cCSRFTokenLength = 32
+	cHTMLContentType = "text/html; charset=utf-8"
+	cWebTimeFormat   = "Mon 2 Jan 15:04"
+	cRenderErrFmt    = "Error when rendering template %s: %s"
+)
+
+type sWebOption struct {
+	ID       int64
+	Value    string
+	Votes    int
+	Voters   []string
+	MyVoteID int64
+}
+
+type sWebPoll struct {
+	ID             int64
+	SequenceNumber int
+	Title          string
+	QuestionID     int64
+	AddOptions     bool
+	Closed         bool
+	ClosingDate    string
+	ResultsHidden  bool
+	Options        []sWebOption
+	Participants   []polly.PublicUser
+}
+
+/* The data shared by all pages that show a poll. */
+type sWebPollPage struct {
+	Poll         *sWebPoll
+	CSRFToken    string
+	ActionPrefix string
+	Token        string
+	Guest        *polly.PrivateUser
+	User         *polly.PrivateUser
+}
+
+type sWebPollListItem struct {
+	ID          int64
+	Title       string
+	Closed      bool
+	ClosingDate string
+}
+
+type sWebPollListPage struct {
+	User      *polly.PrivateUser
+	CSRFToken string
+	Polls     []sWebPollListItem
+	PrevPage  int
+	NextPage  int
+}
+
+type sWebNewPollPage struct {
+	User      *polly.PrivateUser
+	CSRFToken string
+	Groups    []polly.Group
+}
+
+type sWebLoginPage struct {
+	FacebookAppID string
+}
+
+type sWebError struct {
+	Code    int
+	Message string
+}
+
+/*
+ * Authenticates a request of the web client using its session cookie. Returns
+ * the user, the session and an API error code.
+ */
+func (server *sServer) authenticateWebRequest(request *http.Request) (
+	*polly.PrivateUser, *polly.WebSession, int) {
+
+	cookie, err := request.Cookie(cSessionCookie)
+	if err != nil || len(cookie.Value) == 0 {
+		return nil, nil, ERR_AUT_NO_SESSION
+	}
+
+	session, err := server.db.GetWebSessionByToken(cookie.Value)
+	if err != nil {
+		return nil, nil, ERR_AUT_BAD_SESSION
+	}
+
+	currentTime := time.Now().UnixNano() / 1000000
+	if currentTime > session.ExpirationDate {
+		return nil, nil, ERR_AUT_BAD_SESSION
+	}
+
+	user, err := server.db.GetUserByID(session.UserID)
+	if err != nil {
+		return nil, nil, ERR_AUT_NO_USER
+	}
+
+	return user, session, NO_ERR
+}
+
+/* Creates a new web session for the given user and sets its cookie. */
+func (server *sServer) startWebSession(userID int64,
+	writer http.ResponseWriter) (*polly.WebSession, error) {
+
+	now := time.Now()
+	session := polly.WebSession{}
+	session.UserID = userID
+	session.Token = uuid.NewV4().String()
+	session.CSRFToken = uniuri.NewLen(cCSRFTokenLength)
+	session.CreationDate = now.UnixNano() / 1000000
+	session.ExpirationDate = now.Add(cWebSessionDuration).UnixNano() / 1000000
+	err := server.db.AddWebSession(&session)
+	if err != nil {
+		return nil, err
+	}
+
+	http.SetCookie(writer, &http.Cookie{
+		Name:     cSessionCookie,
+		Value:    session.Token,
+		Path:     "/",
+		Expires:  now.Add(cWebSessionDuration),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return &session, nil
+}
+
+/* Makes sure a form was submitted from a page we rendered for the session. */
+func isValidCSRFToken(session *polly.WebSession, request *http.Request) bool {
+	return len(session.CSRFToken) > 0 &&
+		request.PostFormValue(cCSRFTokenField) == session.CSRFToken
+}
+
+func (server *sServer) renderTemplate(name string, data interface{},
+	tag string, writer http.ResponseWriter, request *http.Request) {
+
+	writer.Header().Set("Content-Type", cHTMLContentType)
+	err := vWebTemplates[name].Execute(writer, data)
+	if err != nil {
+		origin, _, _ := net.SplitHostPort(request.RemoteAddr)
+		server.logger.Log(tag, fmt.Sprintf(cRenderErrFmt, name, err), origin)
+	}
+}
+
+/* The web client counterpart of respondWithError. */
+func (server *sServer) renderError(errCode int, err error, tag string,
+	writer http.ResponseWriter, request *http.Request) {
+
+	origin, _, _ := net.SplitHostPort(request.RemoteAddr)
+
+	// get the corresponding error message
+	msg, ok := vAPICodeMessages[errCode]
+	if !ok {
+		msg = cDefaultMessage
+	}
+
+	// get the correct http status
+	httpStatus, ok := vAPICodeHTTPStatuses[errCode]
+	if !ok {
+		httpStatus = cDefaultHTTPStatus
+	}
+
+	// log the error if necessary
+	if vAPICodeShouldLog[errCode] {
+		server.logger.Log(tag, fmt.Sprintf(cErrLogFmt, errCode, msg, err),
+			origin)
+	}
+
+	writer.Header().Set("Content-Type", cHTMLContentType)
+	writer.WriteHeader(httpStatus)
+	err = vWebTemplates[cErrorTemplate].Execute(writer,
+		sWebError{Code: errCode, Message: msg})
+	if err != nil {
+		server.logger.Log(tag, fmt.Sprintf(cRenderErrFmt, cErrorTemplate,
+			err), origin)
+	}
+}
+
+/*
+ * Converts a poll message to the representation used by the web templates,
+ * listing the voters per option and marking the votes of the given user.
+ */
+func newWebPoll(pollMsg *polly.PollMessage, userID int64) *sWebPoll {
+	webPoll := sWebPoll{}
+	webPoll.ID = pollMsg.MetaData.ID
+	webPoll.SequenceNumber = pollMsg.MetaData.SequenceNumber
+	webPoll.Title = pollMsg.Question.Title
+	webPoll.QuestionID = pollMsg.Question.ID
+	webPoll.AddOptions = pollMsg.Question.Type == polly.QUESTION_TYPE_OPEN ||
+		pollMsg.Question.Type == polly.QUESTION_TYPE_MOVIE_OPEN
+	webPoll.Participants = pollMsg.Participants
+	webPoll.ResultsHidden = pollMsg.ResultsHidden
+
+	closingDate := time.Unix(0, pollMsg.MetaData.ClosingDate*1000000)
+	webPoll.Closed = pollMsg.MetaData.Closed || time.Now().After(closingDate)
+	webPoll.ClosingDate = closingDate.Format(cWebTimeFormat)
+
+	// map the participants to their display names
+	displayNames := make(map[int64]string)
+	for _, participant := range pollMsg.Participants {
+		displayNames[participant.ID] = participant.DisplayName
+	}
+
+	// copy the vote counts per option
+	optionIdx := make(map[int64]int)
+	webPoll.Options = make([]sWebOption, len(pollMsg.Options))
+	for idx, option := range pollMsg.Options {
+		webPoll.Options[idx] = sWebOption{ID: option.ID, Value: option.Value}
+		optionIdx[option.ID] = idx
+	}
+
+	for _, tally := range pollMsg.Tallies {
+		if idx, ok := optionIdx[tally.OptionID]; ok {
+			webPoll.Options[idx].Votes = tally.Votes
+		}
+	}
+
+	// anonymous polls only include the votes of the user
+	for _, vote := range pollMsg.Votes {
+		idx, ok := optionIdx[vote.OptionID]
+		if !ok {
+			continue
+		}
+
+		if name, ok := displayNames[vote.UserID]; ok &&
+			!pollMsg.MetaData.Anonymous {
+
+			webPoll.Options[idx].Voters = append(webPoll.Options[idx].Voters,
+				name)
+		}
+
+		if vote.UserID == userID {
+			webPoll.Options[idx].MyVoteID = vote.ID
+		}
+	}
+
+	return &webPoll
+}
@@ -0,0 +1,528 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/roxot/polly"
+	"github.com/roxot/polly/database"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+const (
+	cWebIndexTag        = "GET/WEB"
+	cWebLoginPageTag    = "GET/WEB/LOGIN"
+	cWebLoginTag        = "POST/WEB/LOGIN"
+	cWebLogoutTag       = "POST/WEB/LOGOUT"
+	cWebPollsTag        = "GET/WEB/POLLS"
+	cWebPollTag         = "GET/WEB/POLL"
+	cWebPollSnapshotTag = "GET/WEB/POLL/SNAPSHOT"
+	cWebVoteTag         = "POST/WEB/POLL/VOTE"
+	cWebUndoVoteTag     = "POST/WEB/POLL/UNVOTE"
+	cWebNewPollPageTag  = "GET/WEB/NEW"
+	cWebNewPollTag      = "POST/WEB/NEW"
+
+	cAccessTokenField = "access_token"
+	cTitleField       = "title"
+	cOptionsField     = "options"
+	cOpenField        = "open"
+	cClosingDateField = "closing_date"
+	cTZOffsetField    = "tz_offset"
+	cGroupIDField     = "group_id"
+	cAllowGuestsField = "allow_guests"
+	cAnonymousField   = "anonymous"
+	cVisibilityField  = "results_visibility"
+
+	cWebLoginURL      = "/web/login"
+	cWebPollsURL      = "/web/polls"
+	cWebPollURLFormat = "/web/poll/%d"
+	cDateTimeLocalFmt = "2006-01-02T15:04"
+)
+
+// GET /web/
+func (server *sServer) WebIndex(writer http.ResponseWriter,
+	request *http.Request, _ httprouter.Params) {
+
+	http.Redirect(writer, request, cWebPollsURL, http.StatusSeeOther)
+}
+
+// GET /web/login
+func (server *sServer) WebLoginPage(writer http.ResponseWriter,
+	request *http.Request, _ httprouter.Params) {
+
+	// users that are already logged in go straight to their polls
+	_, _, errCode := server.authenticateWebUser(request)
+	if errCode == NO_ERR {
+		http.Redirect(writer, request, cWebPollsURL, http.StatusSeeOther)
+		return
+	}
+
+	page := sWebLoginPage{FacebookAppID: server.facebookAppID}
+	server.renderTemplate(cLoginTemplate, &page, cWebLoginPageTag, writer,
+		request)
+}
+
+// POST /web/login
+func (server *sServer) WebLogin(writer http.ResponseWriter,
+	request *http.Request, _ httprouter.Params) {
+
+	// verify the Facebook access token
+	facebookID, errCode, err := verifyFacebookUser(
+		request.PostFormValue(cAccessTokenField))
+	if errCode != NO_ERR {
+		server.renderError(errCode, err, cWebLoginTag, writer, request)
+		return
+	}
+
+	// only users that registered through the app can log in
+	user, err := server.db.GetUserByID(facebookID)
+	if err != nil {
+		server.renderError(ERR_AUT_NO_USER, err, cWebLoginTag, writer,
+			request)
+		return
+	}
+
+	// start the user's session
+	_, err = server.startWebSession(user.ID, writer)
+	if err != nil {
+		server.renderError(ERR_INT_DB_ADD, err, cWebLoginTag, writer, request)
+		return
+	}
+
+	http.Redirect(writer, request, cWebPollsURL, http.StatusSeeOther)
+}
+
+// POST /web/logout
+func (server *sServer) WebLogout(writer http.ResponseWriter,
+	request *http.Request, _ httprouter.Params) {
+
+	// authenticate the user
+	_, session, errCode := server.authenticateWebUser(request)
+	if errCode != NO_ERR {
+		server.renderError(errCode, nil, cWebLogoutTag, writer, request)
+		return
+	} else if !isValidCSRFToken(session, request) {
+		server.renderError(ERR_AUT_BAD_CSRF_TOKEN, nil, cWebLogoutTag, writer,
+			request)
+		return
+	}
+
+	// end the session and clear its cookie
+	err := server.db.DeleteWebSession(session.ID)
+	if err != nil {
+		server.renderError(ERR_INT_DB_DELETE, err, cWebLogoutTag, writer,
+			request)
+		return
+	}
+
+	http.SetCookie(writer, &http.Cookie{
+		Name:     cSessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+
+	http.Redirect(writer, request, cWebLoginURL, http.StatusSeeOther)
+}
+
+// GET /web/polls
+func (server *sServer) WebPolls(writer http.ResponseWriter,
+	request *http.Request, _ httprouter.Params) {
+	var err error
+
+	// authenticate the user
+	user, session, errCode := server.authenticateWebUser(request)
+	if errCode != NO_ERR {
+		server.redirectToLogin(errCode, cWebPollsTag, writer, request)
+		return
+	}
+
+	// retrieve the page argument
+	page := 1
+	if pageStr := request.URL.Query().Get(cPage); len(pageStr) > 0 {
+		page, err = strconv.Atoi(pageStr)
+		if err != nil || page < 1 {
+			server.renderError(ERR_BAD_PAGE, err, cWebPollsTag, writer,
+				request)
+			return
+		}
+	}
+
+	// retrieve poll snapshots
+	offset := (page - 1) * cPollListMax
+	filter := database.PollFilter{}
+	pollPage, err := server.db.GetPollSnapshotsByUserID(user.ID, &filter,
+		nil, cPollListMax, offset)
+	if err != nil {
+		server.renderError(ERR_INT_DB_GET, err, cWebPollsTag, writer, request)
+		return
+	}
+
+	// construct the list items
+	listPage := sWebPollListPage{User: user, CSRFToken: session.CSRFToken}
+	now := time.Now()
+	for _, snapshot := range pollPage.Snapshots {
+		closingDate := time.Unix(0, snapshot.ClosingDate*1000000)
+		listPage.Polls = append(listPage.Polls, sWebPollListItem{
+			ID:          snapshot.ID,
+			Title:       snapshot.Title,
+			Closed:      snapshot.Closed || now.After(closingDate),
+			ClosingDate: closingDate.Format(cWebTimeFormat),
+		})
+	}
+
+	// link to the surrounding pages
+	if page > 1 {
+		listPage.PrevPage = page - 1
+	}
+	if int64(offset+len(pollPage.Snapshots)) < pollPage.Total {
+		listPage.NextPage = page + 1
+	}
+
+	server.renderTemplate(cPollsTemplate, &listPage, cWebPollsTag, writer,
+		request)
+}
+
+// GET /web/poll/:id
+func (server *sServer) WebPoll(writer http.ResponseWriter,
+	request *http.Request, params httprouter.Params) {
+
+	// authenticate the user
+	user, session, errCode := server.authenticateWebUser(request)
+	if errCode != NO_ERR {
+		server.redirectToLogin(errCode, cWebPollTag, writer, request)
+		return
+	}
+
+	// retrieve the poll
+	pollID, errCode := server.webPollID(user, params)
+	if errCode != NO_ERR {
+		server.renderError(errCode, nil, cWebPollTag, writer, request)
+		return
+	}
+
+	pollMsg, err := server.db.ConstructPollMessage(pollID, user.ID)
+	if err != nil {
+		server.renderError(ERR_INT_DB_GET, err, cWebPollTag, writer, request)
+		return
+	}
+
+	page := sWebPollPage{}
+	page.Poll = newWebPoll(pollMsg, user.ID)
+	page.User = user
+	page.CSRFToken = session.CSRFToken
+	page.ActionPrefix = fmt.Sprintf(cWebPollURLFormat, pollID)
+	server.renderTemplate(cPollTemplate, &page, cWebPollTag, writer, request)
+}
+
+// GET /web/poll/:id/snapshot.json
+func (server *sServer) WebPollSnapshot(writer http.ResponseWriter,
+	request *http.Request, params httprouter.Params) {
+
+	// authenticate the user
+	user, _, errCode := server.authenticateWebUser(request)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cWebPollSnapshotTag, writer,
+			request)
+		return
+	}
+
+	// retrieve the poll identifier
+	pollID, errCode := server.webPollID(user, params)
+	if errCode != NO_ERR {
+		server.respondWithError(errCode, nil, cWebPollSnapshotTag, writer,
+			request)
+		return
+	}
+
+	server.respondWithSnapshot(pollID, cWebPollSnapshotTag, writer, request)
+}
+
+// POST /web/poll/:id/vote
+func (server *sServer) WebVote(writer http.ResponseWriter,
+	request *http.Request, params httprouter.Params) {
+
+	// authenticate the user
+	user, pollID, errCode := server.authenticateWebPollForm(request, params)
+	if errCode != NO_ERR {
+		server.renderError(errCode, nil, cWebVoteTag, writer, request)
+		return
+	}
+
+	// construct the vote message from the form
+	voteMsg, errCode, err := server.voteMessageFromForm(pollID, request)
+	if errCode != NO_ERR {
+		server.renderError(errCode, err, cWebVoteTag, writer, request)
+		return
+	}
+
+	// cast the vote
+	_, errCode, err = server.castVote(user, voteMsg, cWebVoteTag)
+	if errCode != NO_ERR {
+		server.renderError(errCode, err, cWebVoteTag, writer, request)
+		return
+	}
+
+	http.Redirect(writer, request, fmt.Sprintf(cWebPollURLFormat, pollID),
+		http.StatusSeeOther)
+}
+
+// POST /web/poll/:id/unvote
+func (server *sServer) WebUndoVote(writer http.ResponseWriter,
+	request *http.Request, params httprouter.Params) {
+
+	// authenticate the user
+	user, pollID, errCode := server.authenticateWebPollForm(request, params)
+	if errCode != NO_ERR {
+		server.renderError(errCode, nil, cWebUndoVoteTag, writer, request)
+		return
+	}
+
+	// parse the provided vote id to an integer
+	voteID, err := strconv.ParseInt(request.PostFormValue(cVoteIDField), 10,
+		64)
+	if err != nil {
+		server.renderError(ERR_BAD_ID, err, cWebUndoVoteTag, writer, request)
+		return
+	}
+
+	// undo the vote
+	_, errCode, err = server.undoVote(user, voteID, cWebUndoVoteTag)
+	if errCode != NO_ERR {
+		server.renderError(errCode, err, cWebUndoVoteTag, writer, request)
+		return
+	}
+
+	http.Redirect(writer, request, fmt.Sprintf(cWebPollURLFormat, pollID),
+		http.StatusSeeOther)
+}
+
+// GET /web/new
+func (server *sServer) WebNewPollPage(writer http.ResponseWriter,
+	request *http.Request, _ httprouter.Params) {
+
+	// authenticate the user
+	user, session, errCode := server.authenticateWebUser(request)
+	if errCode != NO_ERR {
+		server.redirectToLogin(errCode, cWebNewPollPageTag, writer, request)
+		return
+	}
+
+	// retrieve the groups the poll can be sent to
+	groups, err := server.db.GetGroupsByOwnerID(user.ID)
+	if err != nil {
+		server.renderError(ERR_INT_DB_GET, err, cWebNewPollPageTag, writer,
+			request)
+		return
+	}
+
+	page := sWebNewPollPage{User: user, CSRFToken: session.CSRFToken,
+		Groups: groups}
+	server.renderTemplate(cNewPollTemplate, &page, cWebNewPollPageTag, writer,
+		request)
+}
+
+// POST /web/new
+func (server *sServer) WebNewPoll(writer http.ResponseWriter,
+	request *http.Request, _ httprouter.Params) {
+
+	// authenticate the user
+	user, session, errCode := server.authenticateWebUser(request)
+	if errCode != NO_ERR {
+		server.renderError(errCode, nil, cWebNewPollTag, writer, request)
+		return
+	} else if !isValidCSRFToken(session, request) {
+		server.renderError(ERR_AUT_BAD_CSRF_TOKEN, nil, cWebNewPollTag, writer,
+			request)
+		return
+	}
+
+	// construct the poll message from the form
+	pollMsg, errCode, err := pollMessageFromForm(user, request)
+	if errCode != NO_ERR {
+		server.renderError(errCode, err, cWebNewPollTag, writer, request)
+		return
+	}
+
+	// create the poll
+	errCode, err = server.createPoll(user, pollMsg, cWebNewPollTag)
+	if errCode != NO_ERR {
+		server.renderError(errCode, err, cWebNewPollTag, writer, request)
+		return
+	}
+
+	http.Redirect(writer, request, fmt.Sprintf(cWebPollURLFormat,
+		pollMsg.MetaData.ID), http.StatusSeeOther)
+}
+
+/*
+ * Authenticates a request of the web client and makes sure it was made by a
+ * registered user. Guests only have access to the page of their poll.
+ */
+func (server *sServer) authenticateWebUser(request *http.Request) (
+	*polly.PrivateUser, *polly.WebSession, int) {
+
+	user, session, errCode := server.authenticateWebRequest(request)
+	if errCode != NO_ERR {
+		return nil, nil, errCode
+	} else if user.GuestPollID != 0 {
+		return nil, nil, ERR_AUT_GUEST
+	}
+
+	return user, session, NO_ERR
+}
+
+/*
+ * Sends visitors without a valid session to the login page, all other errors
+ * are rendered.
+ */
+func (server *sServer) redirectToLogin(errCode int, tag string,
+	writer http.ResponseWriter, request *http.Request) {
+
+	if errCode == ERR_AUT_NO_SESSION || errCode == ERR_AUT_BAD_SESSION {
+		http.Redirect(writer, request, cWebLoginURL, http.StatusSeeOther)
+		return
+	}
+
+	server.renderError(errCode, nil, tag, writer, request)
+}
+
+/*
+ * Parses the poll identifier in the URL and makes sure the user participates
+ * in the poll. Returns the poll identifier and an API error code.
+ */
+func (server *sServer) webPollID(user *polly.PrivateUser,
+	params httprouter.Params) (int64, int) {
+
+	pollID, err := strconv.ParseInt(params.ByName(cID), 10, 64)
+	if err != nil {
+		return 0, ERR_BAD_ID
+	} else if !server.hasPollAccess(user.ID, pollID) {
+		return 0, ERR_ILL_POLL_ACCESS
+	}
+
+	return pollID, NO_ERR
+}
+
+/*
+ * Authenticates a form submitted from the page of a poll. Returns the user,
+ * the poll identifier and an API error code.
+ */
+func (server *sServer) authenticateWebPollForm(request *http.Request,
+	params httprouter.Params) (*polly.PrivateUser, int64, int) {
+
+	user, session, errCode := server.authenticateWebUser(request)
+	if errCode != NO_ERR {
+		return nil, 0, errCode
+	} else if !isValidCSRFToken(session, request) {
+		return nil, 0, ERR_AUT_BAD_CSRF_TOKEN
+	}
+
+	pollID, errCode := server.webPollID(user, params)
+	if errCode != NO_ERR {
+		return nil, 0, errCode
+	}
+
+	return user, pollID, NO_ERR
+}
+
+/*
+ * Responds with the snapshot of the given poll, the web pages poll these to
+ * refresh when the sequence number changes.
+ */
+func (server *sServer) respondWithSnapshot(pollID int64, tag string,
+	writer http.ResponseWriter, request *http.Request) {
+
+	// retrieve the snapshot
+	snapshot, err := server.db.GetPollSnapshot(pollID)
+	if err != nil {
+		server.respondWithError(ERR_BAD_NO_POLL, err, tag, writer, request)
+		return
+	}
+
+	// marshall the response
+	responseBody, err := json.MarshalIndent(snapshot, "", "\t")
+	if err != nil {
+		server.respondWithError(ERR_INT_MARSHALL, err, tag, writer, request)
+		return
+	}
+
+	// send the response
+	err = server.respondWithJSONBody(writer, responseBody)
+	if err != nil {
+		server.respondWithError(ERR_INT_WRITE, err, tag, writer, request)
+	}
+}
+
+/*
+ * Constructs a poll message from the new poll form. The closing date is
+ * entered in the browser's local time, which is converted using the timezone
+ * offset in minutes the page sends along.
+ */
+func pollMessageFromForm(user *polly.PrivateUser, request *http.Request) (
+	*polly.PollMessage, int, error) {
+
+	pollMsg := polly.PollMessage{}
+	pollMsg.Question.Title = request.PostFormValue(cTitleField)
+	pollMsg.Question.Type = polly.QUESTION_TYPE_MC
+	if len(request.PostFormValue(cOpenField)) > 0 {
+		pollMsg.Question.Type = polly.QUESTION_TYPE_OPEN
+	}
+
+	// one option per non-empty line
+	pollMsg.Options = []polly.Option{}
+	for _, line := range strings.Split(request.PostFormValue(cOptionsField),
+		"\n") {
+
+		if value := strings.TrimSpace(line); len(value) > 0 {
+			pollMsg.Options = append(pollMsg.Options,
+				polly.Option{Value: value})
+		}
+	}
+
+	// convert the local closing date to UTC
+	closingDate, err := time.Parse(cDateTimeLocalFmt,
+		request.PostFormValue(cClosingDateField))
+	if err != nil {
+		return nil, ERR_BAD_CLOSING_DATE, err
+	}
+
+	tzOffset, err := strconv.Atoi(request.PostFormValue(cTZOffsetField))
+	if err != nil {
+		return nil, ERR_BAD_CLOSING_DATE, err
+	}
+
+	closingDate = closingDate.Add(time.Duration(tzOffset) * time.Minute)
+	pollMsg.MetaData.ClosingDate = closingDate.UnixNano() / 1000000
+
+	// the group is expanded into participants during validation
+	if groupIDStr := request.PostFormValue(cGroupIDField); len(
+		groupIDStr) > 0 {
+
+		pollMsg.MetaData.GroupID, err = strconv.ParseInt(groupIDStr, 10, 64)
+		if err != nil {
+			return nil, ERR_BAD_ID, err
+		}
+	}
+
+	pollMsg.MetaData.AllowGuests = len(
+		request.PostFormValue(cAllowGuestsField)) > 0
+	pollMsg.MetaData.Anonymous = len(
+		request.PostFormValue(cAnonymousField)) > 0
+	if visibilityStr := request.PostFormValue(cVisibilityField); len(
+		visibilityStr) > 0 {
+
+		pollMsg.MetaData.ResultsVisibility, err = strconv.Atoi(visibilityStr)
+		if err != nil {
+			return nil, ERR_BAD_RESULTS_VISIBILITY, err
+		}
+	}
+	pollMsg.Participants = []polly.PublicUser{polly.PublicUser{ID: user.ID}}
+	return &pollMsg, NO_ERR, nil
+}
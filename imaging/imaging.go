@@ -0,0 +1,132 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+)
+
+const (
+	FORMAT_JPEG = "jpeg"
+	FORMAT_PNG  = "png"
+
+	cJPEGQuality = 85
+
+	// decoded images take 4 bytes per pixel, twice over while converting
+	cMaxPixels = 8000000
+)
+
+var (
+	ErrUnsupportedFormat = errors.New("Unsupported image format.")
+	ErrTooManyPixels     = errors.New("Image has too many pixels.")
+)
+
+/*
+ * Decodes a JPEG or PNG image and returns it with its format. JPEG images are
+ * turned according to their EXIF orientation, since the EXIF data doesn't
+ * survive encoding the image again. The dimensions are checked before
+ * decoding, so small files can't claim huge images.
+ */
+func Decode(data []byte) (*image.RGBA, string, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err == image.ErrFormat {
+		return nil, "", ErrUnsupportedFormat
+	} else if err != nil {
+		return nil, "", err
+	} else if format != FORMAT_JPEG && format != FORMAT_PNG {
+		return nil, "", ErrUnsupportedFormat
+	} else if config.Width*config.Height > cMaxPixels {
+		return nil, "", ErrTooManyPixels
+	}
+
+	decoded, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+
+	bounds := decoded.Bounds()
+	img := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(img, img.Bounds(), decoded, bounds.Min, draw.Src)
+
+	if format == FORMAT_JPEG {
+		img = orient(img, readOrientation(data))
+	}
+
+	return img, format, nil
+}
+
+/* Encodes the image in the given format, without any metadata. */
+func Encode(img image.Image, format string) ([]byte, error) {
+	var buffer bytes.Buffer
+	var err error
+	switch format {
+	case FORMAT_JPEG:
+		err = jpeg.Encode(&buffer, img, &jpeg.Options{Quality: cJPEGQuality})
+	case FORMAT_PNG:
+		err = png.Encode(&buffer, img)
+	default:
+		err = ErrUnsupportedFormat
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+/*
+ * Scales the image down so that neither side exceeds the given size, keeping
+ * its aspect ratio. Every pixel becomes the average of the pixels it covers.
+ * Images that already fit are returned as they are.
+ */
+func Fit(img *image.RGBA, size int) *image.RGBA {
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	if width <= size && height <= size {
+		return img
+	}
+
+	dstWidth, dstHeight := size, height*size/width
+	if height > width {
+		dstWidth, dstHeight = width*size/height, size
+	}
+
+	if dstWidth < 1 {
+		dstWidth = 1
+	}
+
+	if dstHeight < 1 {
+		dstHeight = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+	for y := 0; y < dstHeight; y++ {
+		srcY0, srcY1 := y*height/dstHeight, (y+1)*height/dstHeight
+		for x := 0; x < dstWidth; x++ {
+			srcX0, srcX1 := x*width/dstWidth, (x+1)*width/dstWidth
+
+			var sum [4]int
+			for srcY := srcY0; srcY < srcY1; srcY++ {
+				offset := img.PixOffset(srcX0, srcY)
+				for srcX := srcX0; srcX < srcX1; srcX++ {
+					for c := 0; c < 4; c++ {
+						sum[c] += int(img.Pix[offset+c])
+					}
+
+					offset += 4
+				}
+			}
+
+			count := (srcY1 - srcY0) * (srcX1 - srcX0)
+			offset := dst.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				dst.Pix[offset+c] = uint8(sum[c] / count)
+			}
+		}
+	}
+
+	return dst
+}
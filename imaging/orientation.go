@@ -0,0 +1,134 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+)
+
+const (
+	cMarkerStart      = 0xd8
+	cMarkerApp1       = 0xe1
+	cMarkerScan       = 0xda
+	cTagOrientation   = 0x0112
+	cIFDEntryLength   = 12
+	cOrientationFirst = 1
+	cOrientationLast  = 8
+)
+
+var vExifHeader = []byte("Exif\x00\x00")
+
+/*
+ * Returns the EXIF orientation of a JPEG image, from 1 (as stored) to 8.
+ * Images without a readable orientation are treated as stored.
+ */
+func readOrientation(data []byte) int {
+	if len(data) < 2 || data[0] != 0xff || data[1] != cMarkerStart {
+		return cOrientationFirst
+	}
+
+	// walk the segments up to the image data
+	offset := 2
+	for offset+4 <= len(data) && data[offset] == 0xff {
+		marker := data[offset+1]
+		length := int(binary.BigEndian.Uint16(data[offset+2:]))
+		if marker == cMarkerScan || offset+2+length > len(data) {
+			break
+		}
+
+		segment := data[offset+4 : offset+2+length]
+		if marker == cMarkerApp1 && bytes.HasPrefix(segment, vExifHeader) {
+			return readTIFFOrientation(segment[len(vExifHeader):])
+		}
+
+		offset += 2 + length
+	}
+
+	return cOrientationFirst
+}
+
+/* Reads the orientation from the first directory of the TIFF structure. */
+func readTIFFOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return cOrientationFirst
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return cOrientationFirst
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return cOrientationFirst
+	}
+
+	numEntries := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < numEntries; i++ {
+		entry := ifd + 2 + i*cIFDEntryLength
+		if entry+cIFDEntryLength > len(tiff) {
+			break
+		}
+
+		if order.Uint16(tiff[entry:]) == cTagOrientation {
+			orientation := int(order.Uint16(tiff[entry+8:]))
+			if orientation < cOrientationFirst ||
+				orientation > cOrientationLast {
+				return cOrientationFirst
+			}
+
+			return orientation
+		}
+	}
+
+	return cOrientationFirst
+}
+
+/* Turns and mirrors the image so it is displayed as intended. */
+func orient(img *image.RGBA, orientation int) *image.RGBA {
+	if orientation == cOrientationFirst {
+		return img
+	}
+
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	dstWidth, dstHeight := width, height
+	if orientation >= 5 {
+		dstWidth, dstHeight = height, width
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+	for y := 0; y < dstHeight; y++ {
+		for x := 0; x < dstWidth; x++ {
+
+			// find the source pixel of this destination pixel
+			var srcX, srcY int
+			switch orientation {
+			case 2: // mirrored horizontally
+				srcX, srcY = width-1-x, y
+			case 3: // turned 180 degrees
+				srcX, srcY = width-1-x, height-1-y
+			case 4: // mirrored vertically
+				srcX, srcY = x, height-1-y
+			case 5: // transposed
+				srcX, srcY = y, x
+			case 6: // needs turning 90 degrees clockwise
+				srcX, srcY = y, height-1-x
+			case 7: // transversed
+				srcX, srcY = width-1-y, height-1-x
+			case 8: // needs turning 90 degrees counterclockwise
+				srcX, srcY = width-1-y, x
+			}
+
+			srcOffset := img.PixOffset(srcX, srcY)
+			dstOffset := dst.PixOffset(x, y)
+			copy(dst.Pix[dstOffset:dstOffset+4], img.Pix[srcOffset:srcOffset+4])
+		}
+	}
+
+	return dst
+}
@@ -8,18 +8,47 @@ const (
 	QUESTION_TYPE_OPEN       = 1
 	QUESTION_TYPE_MOVIE_MC   = 2
 	QUESTION_TYPE_MOVIE_OPEN = 3
+	QUESTION_TYPE_DATE       = 4
+	QUESTION_TYPE_LOCATION   = 5
 
 	VOTE_TYPE_NEW    = 0
 	VOTE_TYPE_UPVOTE = 1
 
-	EVENT_TYPE_NEW_VOTE         = 0
-	EVENT_TYPE_UPVOTE           = 1
-	EVENT_TYPE_NEW_POLL         = 2
-	EVENT_TYPE_POLL_CLOSED      = 3
-	EVENT_TYPE_UNDONE_VOTE      = 4
-	EVENT_TYPE_PARTICIPANT_LEFT = 5
-	EVENT_TYPE_NEW_PARTICIPANT  = 6
-	EVENT_TYPE_ADDED_TO_POLL    = 7
+	EVENT_TYPE_NEW_VOTE          = 0
+	EVENT_TYPE_UPVOTE            = 1
+	EVENT_TYPE_NEW_POLL          = 2
+	EVENT_TYPE_POLL_CLOSED       = 3
+	EVENT_TYPE_UNDONE_VOTE       = 4
+	EVENT_TYPE_PARTICIPANT_LEFT  = 5
+	EVENT_TYPE_NEW_PARTICIPANT   = 6
+	EVENT_TYPE_ADDED_TO_POLL     = 7
+	EVENT_TYPE_POLL_EDITED       = 8
+	EVENT_TYPE_POLL_REOPENED     = 9
+	EVENT_TYPE_ROLE_CHANGED      = 10
+	EVENT_TYPE_REMOVED_FROM_POLL = 11
+	EVENT_TYPE_REMINDER          = 12
+	EVENT_TYPE_NEW_COMMENT       = 13
+	EVENT_TYPE_COMMENT_EDITED    = 14
+	EVENT_TYPE_COMMENT_DELETED   = 15
+	EVENT_TYPE_REACTION          = 16
+	EVENT_TYPE_REACTION_REMOVED  = 17
+
+	SEARCH_KIND_QUESTION = "question"
+	SEARCH_KIND_OPTION   = "option"
+	SEARCH_KIND_COMMENT  = "comment"
+
+	RESULTS_VISIBILITY_ALWAYS       = 0
+	RESULTS_VISIBILITY_AFTER_VOTING = 1
+	RESULTS_VISIBILITY_AFTER_CLOSE  = 2
+
+	PARTICIPANT_ROLE_VOTER  = 0
+	PARTICIPANT_ROLE_VIEWER = 1
+	PARTICIPANT_ROLE_ADMIN  = 2
+	PARTICIPANT_ROLE_OWNER  = 3
+
+	RECURRENCE_DAILY  = 0
+	RECURRENCE_WEEKLY = 1
+	RECURRENCE_CRON   = 2
 
 	NOTIFICATION_INFO_FIELD = "info"
 )
@@ -27,25 +56,37 @@ const (
 /* Polly primitives */
 
 type PrivateUser struct {
-	ID          int64  `json:"id"`
-	Token       string `json:"token"`
-	DisplayName string `db:"display_name" json:"display_name"`
-	DeviceType  int    `db:"device_type" json:"device_type"`
-	DeviceGUID  string `db:"device_guid" json:"device_guid"`
-	ProfilePic  string `db:"profile_pic" json:"profile_pic"`
+	ID            int64  `json:"id"`
+	Token         string `json:"token"`
+	DisplayName   string `db:"display_name" json:"display_name"`
+	DeviceType    int    `db:"device_type" json:"device_type"`
+	DeviceGUID    string `db:"device_guid" json:"device_guid"`
+	ProfilePic    string `db:"profile_pic" json:"profile_pic"`
+	GuestPollID   int64  `db:"guest_poll_id" json:"-"`
+	CalendarToken string `db:"calendar_token" json:"-"`
+	ProfilePicKey string `db:"profile_pic_key" json:"-"`
+	BadgeReset    int64  `db:"badge_reset" json:"-"`
 }
 
 type Poll struct {
-	ID              int64  `json:"poll_id"`
-	CreatorID       int64  `db:"creator_id" json:"creator_id"`
-	CreationDate    int64  `db:"creation_date" json:"creation_date"`
-	ClosingDate     int64  `db:"closing_date" json:"closing_date"`
-	LastUpdated     int64  `db:"last_updated" json:"last_updated"`
-	SequenceNumber  int    `db:"sequence_number" json:"sequence_number"`
-	LastEventUser   string `db:"last_event_user" json:"last_event_user"`
-	LastEventUserID int64  `db:"last_event_user_id" json:"last_event_user_id"`
-	LastEventTitle  string `db:"last_event_title" json:"last_event_title"`
-	LastEventType   int    `db:"last_event_type" json:"last_event_type"`
+	ID                int64  `json:"poll_id"`
+	CreatorID         int64  `db:"creator_id" json:"creator_id"`
+	CreationDate      int64  `db:"creation_date" json:"creation_date"`
+	ClosingDate       int64  `db:"closing_date" json:"closing_date"`
+	LastUpdated       int64  `db:"last_updated" json:"last_updated"`
+	SequenceNumber    int    `db:"sequence_number" json:"sequence_number"`
+	LastEventUser     string `db:"last_event_user" json:"last_event_user"`
+	LastEventUserID   int64  `db:"last_event_user_id" json:"last_event_user_id"`
+	LastEventTitle    string `db:"last_event_title" json:"last_event_title"`
+	LastEventType     int    `db:"last_event_type" json:"last_event_type"`
+	GroupID           int64  `db:"group_id" json:"group_id"`
+	AllowGuests       bool   `db:"allow_guests" json:"allow_guests"`
+	CloseJobID        string `db:"close_job_id" json:"-"`
+	Anonymous         bool   `json:"anonymous"`
+	ResultsVisibility int    `db:"results_visibility" json:"results_visibility"`
+	ReminderJobIDs    string `db:"reminder_job_ids" json:"-"`
+	LastNudge         int64  `db:"last_nudge" json:"-"`
+	Closed            bool   `json:"closed"`
 }
 
 type Question struct {
@@ -56,11 +97,84 @@ type Question struct {
 }
 
 type Option struct {
-	ID             int64  `json:"id"`
-	PollID         int64  `db:"poll_id" json:"-"`
-	QuestionID     int64  `db:"question_id" json:"question_id"`
-	Value          string `json:"value"`
-	SequenceNumber int    `db:"sequence_number" json:"sequence_number"`
+	ID             int64    `json:"id"`
+	PollID         int64    `db:"poll_id" json:"-"`
+	QuestionID     int64    `db:"question_id" json:"question_id"`
+	Value          string   `json:"value"`
+	SequenceNumber int      `db:"sequence_number" json:"sequence_number"`
+	Winner         bool     `json:"winner"`
+	StartDate      int64    `db:"start_date" json:"start_date"`
+	Duration       int64    `json:"duration"`
+	MovieID        string   `db:"movie_id" json:"movie_id,omitempty"`
+	MovieTitle     string   `db:"movie_title" json:"movie_title,omitempty"`
+	MovieYear      int      `db:"movie_year" json:"movie_year,omitempty"`
+	PosterURL      string   `db:"poster_url" json:"poster_url,omitempty"`
+	Runtime        int      `json:"runtime,omitempty"`
+	Latitude       *float64 `json:"latitude,omitempty"`
+	Longitude      *float64 `json:"longitude,omitempty"`
+	Address        string   `json:"address,omitempty"`
+	Distance       *float64 `db:"-" json:"distance,omitempty"`
+	ImageID        int64    `db:"image_id" json:"image_id,omitempty"`
+	Image          *Image   `db:"-" json:"image,omitempty"`
+}
+
+/*
+ * An uploaded image. Images that haven't been attached to an option have an
+ * option identifier of 0.
+ */
+type Image struct {
+	ID           int64  `json:"id"`
+	UserID       int64  `db:"user_id" json:"-"`
+	OptionID     int64  `db:"option_id" json:"-"`
+	BlobKey      string `db:"blob_key" json:"-"`
+	URL          string `json:"url"`
+	ThumbnailURL string `db:"thumbnail_url" json:"thumbnail_url"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	CreationDate int64  `db:"creation_date" json:"-"`
+}
+
+/* A movie as described by the movie metadata provider. */
+type Movie struct {
+	ID        string `json:"id"`
+	Title     string `json:"title"`
+	Year      int    `json:"year"`
+	PosterURL string `json:"poster_url"`
+	Runtime   int    `json:"runtime"`
+}
+
+/* A comment on a poll, optionally about one of its options. */
+type Comment struct {
+	ID           int64  `json:"id"`
+	PollID       int64  `db:"poll_id" json:"poll_id"`
+	OptionID     int64  `db:"option_id" json:"option_id,omitempty"`
+	UserID       int64  `db:"user_id" json:"user_id"`
+	Content      string `json:"content"`
+	CreationDate int64  `db:"creation_date" json:"creation_date"`
+	LastEdited   int64  `db:"last_edited" json:"last_edited,omitempty"`
+}
+
+/*
+ * An emoji reaction of a user on either an option or a comment. Reactions
+ * don't count as votes.
+ */
+type Reaction struct {
+	ID           int64  `json:"id"`
+	PollID       int64  `db:"poll_id" json:"poll_id"`
+	OptionID     int64  `db:"option_id" json:"option_id,omitempty"`
+	CommentID    int64  `db:"comment_id" json:"comment_id,omitempty"`
+	UserID       int64  `db:"user_id" json:"user_id"`
+	Emoji        string `json:"emoji"`
+	CreationDate int64  `db:"creation_date" json:"creation_date"`
+}
+
+/* The number of reactions with an emoji, and whether the requester's is one. */
+type ReactionCount struct {
+	OptionID  int64  `db:"option_id" json:"option_id,omitempty"`
+	CommentID int64  `db:"comment_id" json:"comment_id,omitempty"`
+	Emoji     string `json:"emoji"`
+	Count     int    `json:"count"`
+	Mine      bool   `json:"mine"`
 }
 
 type Vote struct {
@@ -71,10 +185,99 @@ type Vote struct {
 	CreationDate int64 `db:"creation_date" json:"creation_date"`
 }
 
+type Tally struct {
+	OptionID int64 `db:"option_id" json:"option_id"`
+	Votes    int   `json:"votes"`
+}
+
 type Participant struct {
-	ID     int64
-	UserID int64 `db:"user_id"`
-	PollID int64 `db:"poll_id"`
+	ID       int64
+	UserID   int64 `db:"user_id"`
+	PollID   int64 `db:"poll_id"`
+	Role     int
+	LastSeen int `db:"last_seen"`
+}
+
+type Group struct {
+	ID                int64  `json:"id"`
+	OwnerID           int64  `db:"owner_id" json:"owner_id"`
+	Name              string `json:"name"`
+	InviteToOpenPolls bool   `db:"invite_to_open_polls" json:"invite_to_open_polls"`
+}
+
+type GroupMember struct {
+	ID      int64
+	GroupID int64 `db:"group_id"`
+	UserID  int64 `db:"user_id"`
+}
+
+type InviteLink struct {
+	ID             int64  `json:"id"`
+	PollID         int64  `db:"poll_id" json:"poll_id"`
+	CreatorID      int64  `db:"creator_id" json:"creator_id"`
+	Token          string `json:"token"`
+	CreationDate   int64  `db:"creation_date" json:"creation_date"`
+	ExpirationDate int64  `db:"expiration_date" json:"expiration_date"`
+	MaxUses        int    `db:"max_uses" json:"max_uses"`
+	Uses           int    `json:"uses"`
+	Revoked        bool   `json:"revoked"`
+}
+
+type WebSession struct {
+	ID             int64
+	UserID         int64 `db:"user_id"`
+	Token          string
+	CSRFToken      string `db:"csrf_token"`
+	CreationDate   int64  `db:"creation_date"`
+	ExpirationDate int64  `db:"expiration_date"`
+}
+
+type PollTemplate struct {
+	ID                int64  `json:"id"`
+	CreatorID         int64  `db:"creator_id" json:"creator_id"`
+	QuestionType      int    `db:"question_type" json:"question_type"`
+	Title             string `json:"title"`
+	GroupID           int64  `db:"group_id" json:"group_id"`
+	AllowGuests       bool   `db:"allow_guests" json:"allow_guests"`
+	Anonymous         bool   `json:"anonymous"`
+	ResultsVisibility int    `db:"results_visibility" json:"results_visibility"`
+	OpenDuration      int64  `db:"open_duration" json:"open_duration"`
+	Recurrence        int    `json:"recurrence"`
+	CronRule          string `db:"cron_rule" json:"cron_rule"`
+	TimeZone          string `db:"time_zone" json:"time_zone"`
+	StartDate         int64  `db:"start_date" json:"start_date"`
+	NextRun           int64  `db:"next_run" json:"next_run"`
+	JobID             string `db:"job_id" json:"-"`
+}
+
+type TemplateOption struct {
+	ID         int64    `json:"id"`
+	TemplateID int64    `db:"template_id" json:"-"`
+	Value      string   `json:"value"`
+	MovieID    string   `db:"movie_id" json:"movie_id,omitempty"`
+	MovieTitle string   `db:"movie_title" json:"movie_title,omitempty"`
+	MovieYear  int      `db:"movie_year" json:"movie_year,omitempty"`
+	PosterURL  string   `db:"poster_url" json:"poster_url,omitempty"`
+	Runtime    int      `json:"runtime,omitempty"`
+	Latitude   *float64 `json:"latitude,omitempty"`
+	Longitude  *float64 `json:"longitude,omitempty"`
+	Address    string   `json:"address,omitempty"`
+}
+
+type TemplateParticipant struct {
+	ID         int64
+	TemplateID int64 `db:"template_id"`
+	UserID     int64 `db:"user_id"`
+	Role       int
+}
+
+type Job struct {
+	ID      int64
+	Type    string
+	Data    []byte
+	RunAt   int64 `db:"run_at"`
+	Retries int
+	Failed  bool
 }
 
 /* Partial Polly objects. */
@@ -83,6 +286,8 @@ type PublicUser struct {
 	ID          int64  `json:"id"`
 	DisplayName string `json:"display_name"`
 	ProfilePic  string `db:"profile_pic" json:"profile_pic"`
+	Guest       bool   `json:"guest"`
+	Role        int    `json:"role"`
 }
 
 type PollSnapshot struct {
@@ -90,41 +295,145 @@ type PollSnapshot struct {
 	ClosingDate    int64 `db:"closing_date" json:"closing_date"`
 	LastUpdated    int64 `db:"last_updated" json:"last_updated"`
 	SequenceNumber int   `db:"sequence_number" json:"sequence_number"`
+	Closed         bool  `db:"closed" json:"closed"`
+
+	/* Only set when listing the polls of a user. */
+	Title       string `db:"title" json:"title,omitempty"`
+	Voted       *bool  `db:"voted" json:"voted,omitempty"`
+	Unread      *bool  `db:"unread" json:"unread,omitempty"`
+	UnreadCount *int   `db:"unread_count" json:"unread_count,omitempty"`
 }
 
 type DeviceInfo struct {
 	DeviceType int    `db:"device_type"`
 	DeviceGUID string `db:"device_guid"`
+
+	/* The badge count of the device's user, if it was looked up. */
+	Badge *int `db:"badge"`
 }
 
 /* Polly API message objects */
 
 type PollMessage struct {
-	MetaData     Poll         `json:"meta_data"`
-	Question     Question     `json:"question"`
-	Options      []Option     `json:"options"`
-	Votes        []Vote       `json:"votes"`
-	Participants []PublicUser `json:"participants"`
+	MetaData      Poll            `json:"meta_data"`
+	Question      Question        `json:"question"`
+	Options       []Option        `json:"options"`
+	Votes         []Vote          `json:"votes"`
+	Tallies       []Tally         `json:"tallies"`
+	ResultsHidden bool            `json:"results_hidden"`
+	Participants  []PublicUser    `json:"participants"`
+	Reactions     []ReactionCount `json:"reactions"`
+}
+
+type PollTemplateMessage struct {
+	MetaData     PollTemplate     `json:"meta_data"`
+	Options      []TemplateOption `json:"options"`
+	Participants []PublicUser     `json:"participants"`
+}
+
+type PollTemplateListMessage struct {
+	Templates []PollTemplateMessage `json:"templates"`
 }
 
 type PollBulkMessage struct {
 	Polls []PollMessage `json:"polls"`
 }
 
+type GroupMessage struct {
+	MetaData Group        `json:"meta_data"`
+	Members  []PublicUser `json:"members"`
+}
+
+type GroupListMessage struct {
+	Groups []GroupMessage `json:"groups"`
+}
+
 type UserBulkMessage struct {
 	Users []PublicUser `json:"users"`
 }
 
 type VoteMessage struct {
-	Type  int    `json:"type"`
-	ID    int64  `json:"id"`
-	Value string `json:"value"`
+	Type      int      `json:"type"`
+	ID        int64    `json:"id"`
+	Value     string   `json:"value"`
+	MovieID   string   `json:"movie_id,omitempty"`
+	Latitude  *float64 `json:"latitude,omitempty"`
+	Longitude *float64 `json:"longitude,omitempty"`
+	Address   string   `json:"address,omitempty"`
+	ImageID   int64    `json:"image_id,omitempty"`
+}
+
+type CommentMessage struct {
+	ID       int64  `json:"id"`
+	PollID   int64  `json:"poll_id"`
+	OptionID int64  `json:"option_id"`
+	Content  string `json:"content"`
+}
+
+type CommentResponseMessage struct {
+	Comment *Comment     `json:"comment,omitempty"`
+	Poll    PollSnapshot `json:"poll"`
+}
+
+type CommentListMessage struct {
+	Comments   []Comment `json:"comments"`
+	Page       int       `json:"page"`
+	PageSize   int       `json:"page_size"`
+	NumResults int       `json:"num_results"`
+	Total      int64     `json:"total"`
+}
+
+type ReactionMessage struct {
+	OptionID  int64  `json:"option_id"`
+	CommentID int64  `json:"comment_id"`
+	Emoji     string `json:"emoji"`
+}
+
+type ReactionResponseMessage struct {
+	Reactions []ReactionCount `json:"reactions"`
+	Poll      PollSnapshot    `json:"poll"`
+}
+
+/*
+ * A question, option or comment matching a search, within the poll of the
+ * given title. The highlight is the matching text with the matches between
+ * <b> and </b>.
+ */
+type SearchResult struct {
+	PollID    int64   `db:"poll_id" json:"poll_id"`
+	Title     string  `json:"title"`
+	Kind      string  `json:"kind"`
+	SourceID  int64   `db:"source_id" json:"source_id"`
+	Highlight string  `json:"highlight"`
+	Rank      float64 `json:"rank"`
+}
+
+type SearchMessage struct {
+	Results    []SearchResult `json:"results"`
+	Page       int            `json:"page"`
+	PageSize   int            `json:"page_size"`
+	NumResults int            `json:"num_results"`
+}
+
+/* The sequence number of the poll up to which the user has seen it. */
+type ReadMessage struct {
+	PollID         int64 `json:"poll_id"`
+	SequenceNumber int   `json:"sequence_number"`
+}
+
+type BadgeMessage struct {
+	Badge int64 `json:"badge"`
+}
+
+type MovieSearchMessage struct {
+	Movies []Movie `json:"movies"`
 }
 
 type VoteResponseMessage struct {
-	Option *Option      `json:"option,omitempty"`
-	Vote   Vote         `json:"vote"`
-	Poll   PollSnapshot `json:"poll"`
+	Option  *Option      `json:"option,omitempty"`
+	Vote    Vote         `json:"vote"`
+	Poll    PollSnapshot `json:"poll"`
+	Tallies []Tally      `json:"tallies,omitempty"`
 }
 
 type UpdateUserMessage struct {
@@ -138,12 +447,58 @@ type AddUserMessage struct {
 	User   PublicUser `json:"user"`
 }
 
+type EditPollMessage struct {
+	PollID            int64   `json:"poll_id"`
+	Title             *string `json:"title"`
+	RemovedOptionIDs  []int64 `json:"removed_option_ids"`
+	ClosingDate       *int64  `json:"closing_date"`
+	ResultsVisibility *int    `json:"results_visibility"`
+}
+
+type ClosePollMessage struct {
+	PollID int64 `json:"poll_id"`
+}
+
+type ReopenPollMessage struct {
+	PollID      int64 `json:"poll_id"`
+	ClosingDate int64 `json:"closing_date"`
+}
+
+type NudgeMessage struct {
+	PollID int64 `json:"poll_id"`
+}
+
+type RoleMessage struct {
+	PollID int64 `json:"poll_id"`
+	UserID int64 `json:"user_id"`
+	Role   int   `json:"role"`
+}
+
+type CalendarTokenMessage struct {
+	Token string `json:"token"`
+}
+
+type InviteLinkListMessage struct {
+	InviteLinks []InviteLink `json:"invite_links"`
+}
+
+type RedeemInviteMessage struct {
+	Token string `json:"token"`
+}
+
+type GuestSettingsMessage struct {
+	PollID      int64 `json:"poll_id"`
+	AllowGuests bool  `json:"allow_guests"`
+}
+
 type PollListMessage struct {
 	Snapshots  []PollSnapshot `json:"polls"`
 	Page       int            `json:"page"`
 	PageSize   int            `json:"page_size"`
 	NumResults int            `json:"num_results"`
 	Total      int64          `json:"total"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+	Unread     int64          `json:"unread"`
 }
 
 type NotificationMessage struct {
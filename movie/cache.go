@@ -0,0 +1,98 @@
+package movie
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/roxot/polly"
+)
+
+const (
+	cSearchCacheSize = 1000
+	cLookupCacheSize = 10000
+)
+
+type sCachedSearch struct {
+	movies  []polly.Movie
+	expires time.Time
+}
+
+type sCachedLookup struct {
+	movie   *polly.Movie
+	expires time.Time
+}
+
+type sCachedProvider struct {
+	provider IProvider
+	ttl      time.Duration
+	mutex    sync.Mutex
+	searches map[string]sCachedSearch
+	lookups  map[string]sCachedLookup
+}
+
+/*
+ * Wraps the given provider in a cache that keeps results for the given
+ * duration. Movies that don't exist are remembered as well, so votes for them
+ * don't hit the provider every time. Errors are never cached.
+ */
+func NewCachedProvider(provider IProvider, ttl time.Duration) IProvider {
+	cached := sCachedProvider{}
+	cached.provider = provider
+	cached.ttl = ttl
+	cached.searches = make(map[string]sCachedSearch)
+	cached.lookups = make(map[string]sCachedLookup)
+	return &cached
+}
+
+func (cached *sCachedProvider) Search(query string) ([]polly.Movie, error) {
+	key := strings.ToLower(strings.TrimSpace(query))
+
+	cached.mutex.Lock()
+	search, ok := cached.searches[key]
+	cached.mutex.Unlock()
+	if ok && time.Now().Before(search.expires) {
+		return search.movies, nil
+	}
+
+	movies, err := cached.provider.Search(query)
+	if err != nil {
+		return nil, err
+	}
+
+	cached.mutex.Lock()
+	if len(cached.searches) >= cSearchCacheSize {
+		cached.searches = make(map[string]sCachedSearch)
+	}
+
+	cached.searches[key] = sCachedSearch{movies, time.Now().Add(cached.ttl)}
+	cached.mutex.Unlock()
+	return movies, nil
+}
+
+func (cached *sCachedProvider) Lookup(id string) (*polly.Movie, error) {
+	cached.mutex.Lock()
+	lookup, ok := cached.lookups[id]
+	cached.mutex.Unlock()
+	if ok && time.Now().Before(lookup.expires) {
+		if lookup.movie == nil {
+			return nil, ErrNotFound
+		}
+
+		return lookup.movie, nil
+	}
+
+	movie, err := cached.provider.Lookup(id)
+	if err != nil && err != ErrNotFound {
+		return nil, err
+	}
+
+	cached.mutex.Lock()
+	if len(cached.lookups) >= cLookupCacheSize {
+		cached.lookups = make(map[string]sCachedLookup)
+	}
+
+	cached.lookups[id] = sCachedLookup{movie, time.Now().Add(cached.ttl)}
+	cached.mutex.Unlock()
+	return movie, err
+}
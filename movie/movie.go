@@ -0,0 +1,24 @@
+package movie
+
+import (
+	"errors"
+
+	"github.com/roxot/polly"
+)
+
+const (
+	PROVIDER_STUB = "stub"
+	PROVIDER_OMDB = "omdb"
+)
+
+/* Returned when the provider doesn't know the requested movie. */
+var ErrNotFound = errors.New("Movie not found.")
+
+type IProvider interface {
+
+	/* Returns the movies matching the query, best match first. */
+	Search(query string) ([]polly.Movie, error)
+
+	/* Returns the movie with the given identifier or ErrNotFound. */
+	Lookup(id string) (*polly.Movie, error)
+}
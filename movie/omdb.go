@@ -0,0 +1,136 @@
+package movie
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/roxot/polly"
+)
+
+const (
+	cOMDbTimeout    = 5 * time.Second
+	cOMDbTrue       = "True"
+	cOMDbNotFound   = "N/A"
+	cOMDbMovieType  = "movie"
+	cOMDbRuntimeFmt = "%d min"
+)
+
+type sOMDbMovie struct {
+	IMDbID  string `json:"imdbID"`
+	Title   string
+	Year    string
+	Poster  string
+	Runtime string
+}
+
+type sOMDbResponse struct {
+	sOMDbMovie
+	Search   []sOMDbMovie
+	Response string
+	Error    string
+}
+
+type sOMDbProvider struct {
+	client  http.Client
+	baseURL string
+	apiKey  string
+}
+
+/*
+ * Creates a provider for an OMDb style API at the given URL. The search
+ * results of such an API don't include the runtime of the movies.
+ */
+func NewOMDbProvider(baseURL, apiKey string) IProvider {
+	provider := sOMDbProvider{}
+	provider.client = http.Client{Timeout: cOMDbTimeout}
+	provider.baseURL = baseURL
+	provider.apiKey = apiKey
+	return &provider
+}
+
+func (provider *sOMDbProvider) Search(query string) ([]polly.Movie, error) {
+	params := url.Values{}
+	params.Set("s", query)
+	params.Set("type", cOMDbMovieType)
+	response, err := provider.get(params)
+	if err != nil {
+		return nil, err
+	}
+
+	// an unsuccessful search means nothing matched
+	movies := make([]polly.Movie, 0, len(response.Search))
+	if response.Response != cOMDbTrue {
+		return movies, nil
+	}
+
+	for _, omdbMovie := range response.Search {
+		movies = append(movies, *newMovie(&omdbMovie))
+	}
+
+	return movies, nil
+}
+
+func (provider *sOMDbProvider) Lookup(id string) (*polly.Movie, error) {
+	params := url.Values{}
+	params.Set("i", id)
+	response, err := provider.get(params)
+	if err != nil {
+		return nil, err
+	} else if response.Response != cOMDbTrue {
+		return nil, ErrNotFound
+	}
+
+	return newMovie(&response.sOMDbMovie), nil
+}
+
+func (provider *sOMDbProvider) get(params url.Values) (*sOMDbResponse,
+	error) {
+
+	params.Set("apikey", provider.apiKey)
+	resp, err := provider.client.Get(provider.baseURL + "?" + params.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Movie provider responded with %d.",
+			resp.StatusCode)
+	}
+
+	var response sOMDbResponse
+	decoder := json.NewDecoder(resp.Body)
+	err = decoder.Decode(&response)
+	if err != nil {
+		return nil, err
+	}
+
+	return &response, nil
+}
+
+/* Converts the given OMDb movie, leaving out unknown values. */
+func newMovie(omdbMovie *sOMDbMovie) *polly.Movie {
+	movie := polly.Movie{}
+	movie.ID = omdbMovie.IMDbID
+	movie.Title = omdbMovie.Title
+
+	// years of series look like 2008–2013
+	if len(omdbMovie.Year) >= 4 {
+		movie.Year, _ = strconv.Atoi(omdbMovie.Year[:4])
+	}
+
+	if omdbMovie.Poster != cOMDbNotFound {
+		movie.PosterURL = omdbMovie.Poster
+	}
+
+	if strings.HasSuffix(omdbMovie.Runtime, " min") {
+		fmt.Sscanf(omdbMovie.Runtime, cOMDbRuntimeFmt, &movie.Runtime)
+	}
+
+	return &movie
+}
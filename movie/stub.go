@@ -0,0 +1,50 @@
+package movie
+
+import (
+	"strings"
+
+	"github.com/roxot/polly"
+)
+
+var vStubMovies = []polly.Movie{
+	{ID: "tt0133093", Title: "The Matrix", Year: 1999, Runtime: 136},
+	{ID: "tt0068646", Title: "The Godfather", Year: 1972, Runtime: 175},
+	{ID: "tt0468569", Title: "The Dark Knight", Year: 2008, Runtime: 152},
+	{ID: "tt0110912", Title: "Pulp Fiction", Year: 1994, Runtime: 154},
+	{ID: "tt1375666", Title: "Inception", Year: 2010, Runtime: 148},
+	{ID: "tt0816692", Title: "Interstellar", Year: 2014, Runtime: 169},
+	{ID: "tt0109830", Title: "Forrest Gump", Year: 1994, Runtime: 142},
+	{ID: "tt0114709", Title: "Toy Story", Year: 1995, Runtime: 81},
+}
+
+type sStubProvider struct{}
+
+/*
+ * Creates a provider serving a fixed list of movies, for running the server
+ * without access to a real movie API.
+ */
+func NewStubProvider() IProvider {
+	return &sStubProvider{}
+}
+
+func (provider *sStubProvider) Search(query string) ([]polly.Movie, error) {
+	query = strings.ToLower(query)
+	movies := make([]polly.Movie, 0)
+	for _, movie := range vStubMovies {
+		if strings.Contains(strings.ToLower(movie.Title), query) {
+			movies = append(movies, movie)
+		}
+	}
+
+	return movies, nil
+}
+
+func (provider *sStubProvider) Lookup(id string) (*polly.Movie, error) {
+	for _, movie := range vStubMovies {
+		if movie.ID == id {
+			return &movie, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
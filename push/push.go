@@ -32,17 +32,33 @@ const (
 type IPushClient interface {
 	StartErrorLogger(log.ILogger) error
 	NotifyForVote(db *database.Database, user *polly.PrivateUser,
-		optionTitle string, pollID int64, voteType int) error
+		optionTitle string, pollID int64, voteType int, anonymous,
+		resultsHidden bool) error
 	NotifyForNewPoll(db *database.Database, user *polly.PrivateUser,
 		pollID int64, pollTitle string) error
 	NotifyForClosedEvent(db *database.Database, pollID int64,
 		title string) error
 	NotifyForUndoneVote(db *database.Database, user *polly.PrivateUser,
-		optionTitle string, pollID int64) error
+		optionTitle string, pollID int64, anonymous, resultsHidden bool) error
 	NotifyForParticipantLeft(db *database.Database, user *polly.PrivateUser,
 		pollID int64, pollTitle string) error
 	NotifyForNewParticipant(db *database.Database, creator *polly.PrivateUser,
 		pollID int64, pollTitle string, newUser *polly.PrivateUser) error
+	NotifyForJoinedPoll(db *database.Database, user *polly.PrivateUser,
+		pollID int64, pollTitle string) error
+	NotifyForEditedPoll(db *database.Database, creator *polly.PrivateUser,
+		pollID int64, pollTitle string) error
+	NotifyForReopenedPoll(db *database.Database, creator *polly.PrivateUser,
+		pollID int64, pollTitle string) error
+	NotifyForChangedRole(db *database.Database, user *polly.PrivateUser,
+		pollID int64, pollTitle string, changedUser *polly.PrivateUser) error
+	NotifyForRemovedParticipant(db *database.Database,
+		user *polly.PrivateUser, pollID int64, pollTitle string,
+		removedUser *polly.PrivateUser) error
+	NotifyForReminder(db *database.Database, nudger *polly.PrivateUser,
+		pollID int64, pollTitle string) error
+	NotifyForComment(db *database.Database, user *polly.PrivateUser,
+		pollID int64, content string) error
 }
 
 type sPushClient struct {
@@ -118,22 +134,20 @@ func (pushClient *sPushClient) startNotificationHandling() {
 					fmt.Println("Notifying (and):",
 						notificationMsg.DeviceInfos[i].DeviceGUID)
 					pushClient.sendAndroidNotification(
-						notificationMsg.DeviceInfos[i].DeviceGUID,
-						notificationMsg)
+						&notificationMsg.DeviceInfos[i], notificationMsg)
 				} else {
 					fmt.Println("Notifying (ios):",
 						notificationMsg.DeviceInfos[i].DeviceGUID)
 					pushClient.sendIosNotification(
-						notificationMsg.DeviceInfos[i].DeviceGUID,
-						notificationMsg)
+						&notificationMsg.DeviceInfos[i], notificationMsg)
 				}
 			}
 		}
 	}()
 }
 
-func (pushClient *sPushClient) sendIosNotification(deviceGUID string,
-	notificationMsg *polly.NotificationMessage) {
+func (pushClient *sPushClient) sendIosNotification(
+	deviceInfo *polly.DeviceInfo, notificationMsg *polly.NotificationMessage) {
 
 	data, err := json.MarshalIndent(notificationMsg, "", "\t")
 	if err != nil {
@@ -143,21 +157,29 @@ func (pushClient *sPushClient) sendIosNotification(deviceGUID string,
 
 	payload := apns.NewPayload()
 	payload.APS.ContentAvailable = cIOSSilentNotification
+	if deviceInfo.Badge != nil {
+		payload.APS.Badge.Set(uint(*deviceInfo.Badge))
+	}
+
 	payload.SetCustomValue(polly.NOTIFICATION_INFO_FIELD, string(data))
 	notification := apns.NewNotification()
 	notification.Payload = payload
-	notification.DeviceToken = deviceGUID
+	notification.DeviceToken = deviceInfo.DeviceGUID
 	pushClient.iosClient.Send(notification)
 }
 
-func (pushClient *sPushClient) sendAndroidNotification(deviceGUID string,
-	notificationMsg *polly.NotificationMessage) {
+func (pushClient *sPushClient) sendAndroidNotification(
+	deviceInfo *polly.DeviceInfo, notificationMsg *polly.NotificationMessage) {
 
 	// construct the notifcation
 	data := map[string]interface{}{"poll_id": notificationMsg.PollID,
 		"type": notificationMsg.Type, "user": notificationMsg.User,
 		"title": notificationMsg.Title}
-	regIDs := []string{deviceGUID}
+	if deviceInfo.Badge != nil {
+		data["badge"] = *deviceInfo.Badge
+	}
+
+	regIDs := []string{deviceInfo.DeviceGUID}
 	msg := gcm.NewMessage(data, regIDs...)
 	msg.Priority = gcm.HighPriority
 
@@ -201,7 +223,8 @@ func (pushClient *sPushClient) NotifyForClosedEvent(db *database.Database,
 }
 
 func (pushClient *sPushClient) NotifyForVote(db *database.Database,
-	user *polly.PrivateUser, optionTitle string, pollID int64, voteType int) error {
+	user *polly.PrivateUser, optionTitle string, pollID int64, voteType int,
+	anonymous, resultsHidden bool) error {
 	// TODO user->voter, PrivateUser->PublicUser
 
 	// TODO assert votetype
@@ -222,9 +245,16 @@ func (pushClient *sPushClient) NotifyForVote(db *database.Database,
 	notificationMsg.DeviceInfos = deviceInfos
 	notificationMsg.PollID = pollID
 	notificationMsg.Type = voteType
-	notificationMsg.User = user.DisplayName
-	notificationMsg.UserID = user.ID
-	notificationMsg.Title = optionTitle
+
+	// don't reveal the vote while the results are hidden, nor who voted in
+	// anonymous polls
+	if !resultsHidden {
+		notificationMsg.Title = optionTitle
+	}
+	if !anonymous && !resultsHidden {
+		notificationMsg.User = user.DisplayName
+		notificationMsg.UserID = user.ID
+	}
 
 	// let the notification handler goroutine take care of the rest
 	pushClient.notificationChannel <- &notificationMsg
@@ -233,7 +263,8 @@ func (pushClient *sPushClient) NotifyForVote(db *database.Database,
 }
 
 func (pushClient *sPushClient) NotifyForUndoneVote(db *database.Database,
-	user *polly.PrivateUser, optionTitle string, pollID int64) error {
+	user *polly.PrivateUser, optionTitle string, pollID int64,
+	anonymous, resultsHidden bool) error {
 	// TODO user->voter, PrivateUser->PublicUser
 
 	// TODO assert votetype
@@ -254,9 +285,16 @@ func (pushClient *sPushClient) NotifyForUndoneVote(db *database.Database,
 	notificationMsg.DeviceInfos = deviceInfos
 	notificationMsg.PollID = pollID
 	notificationMsg.Type = polly.EVENT_TYPE_UNDONE_VOTE
-	notificationMsg.User = user.DisplayName
-	notificationMsg.UserID = user.ID
-	notificationMsg.Title = optionTitle
+
+	// don't reveal the vote while the results are hidden, nor who voted in
+	// anonymous polls
+	if !resultsHidden {
+		notificationMsg.Title = optionTitle
+	}
+	if !anonymous && !resultsHidden {
+		notificationMsg.User = user.DisplayName
+		notificationMsg.UserID = user.ID
+	}
 
 	// let the notification handler goroutine take care of the rest
 	pushClient.notificationChannel <- &notificationMsg
@@ -363,3 +401,230 @@ func (pushClient *sPushClient) NotifyForNewParticipant(db *database.Database,
 
 	return nil
 }
+
+func (pushClient *sPushClient) NotifyForJoinedPoll(db *database.Database,
+	user *polly.PrivateUser, pollID int64, pollTitle string) error {
+
+	// retrieve all poll participants except the one that joined
+	deviceInfos, err := db.GetDeviceInfosForPollExcludeCreator(pollID,
+		user.ID)
+	if err != nil {
+		return err
+	}
+
+	// don't notify for empty polls
+	if len(deviceInfos) == 0 {
+		return nil
+	}
+
+	// prepare notification
+	notificationMsg := polly.NotificationMessage{}
+	notificationMsg.DeviceInfos = deviceInfos
+	notificationMsg.PollID = pollID
+	notificationMsg.Type = polly.EVENT_TYPE_NEW_PARTICIPANT
+	notificationMsg.User = user.DisplayName
+	notificationMsg.UserID = user.ID
+	notificationMsg.Title = pollTitle
+
+	// let the notification handler goroutine take care of the rest
+	pushClient.notificationChannel <- &notificationMsg
+
+	return nil
+}
+
+func (pushClient *sPushClient) NotifyForEditedPoll(db *database.Database,
+	creator *polly.PrivateUser, pollID int64, pollTitle string) error {
+
+	return pushClient.notifyForCreatorEvent(db, creator, pollID, pollTitle,
+		polly.EVENT_TYPE_POLL_EDITED)
+}
+
+func (pushClient *sPushClient) NotifyForReopenedPoll(db *database.Database,
+	creator *polly.PrivateUser, pollID int64, pollTitle string) error {
+
+	return pushClient.notifyForCreatorEvent(db, creator, pollID, pollTitle,
+		polly.EVENT_TYPE_POLL_REOPENED)
+}
+
+/* Notifies all participants but the creator of a change the creator made. */
+func (pushClient *sPushClient) notifyForCreatorEvent(db *database.Database,
+	creator *polly.PrivateUser, pollID int64, pollTitle string,
+	eventType int) error {
+
+	// retrieve all poll participants
+	deviceInfos, err := db.GetDeviceInfosForPollExcludeCreator(pollID,
+		creator.ID)
+	if err != nil {
+		return err
+	}
+
+	// don't notify for empty polls
+	if len(deviceInfos) == 0 {
+		return nil
+	}
+
+	// prepare notification
+	notificationMsg := polly.NotificationMessage{}
+	notificationMsg.DeviceInfos = deviceInfos
+	notificationMsg.PollID = pollID
+	notificationMsg.Type = eventType
+	notificationMsg.User = creator.DisplayName
+	notificationMsg.UserID = creator.ID
+	notificationMsg.Title = pollTitle
+
+	// let the notification handler goroutine take care of the rest
+	pushClient.notificationChannel <- &notificationMsg
+
+	return nil
+}
+
+func (pushClient *sPushClient) NotifyForChangedRole(db *database.Database,
+	user *polly.PrivateUser, pollID int64, pollTitle string,
+	changedUser *polly.PrivateUser) error {
+
+	// retrieve all poll participants but the one who changed the role
+	deviceInfos, err := db.GetDeviceInfosForPollExcludeCreator(pollID,
+		user.ID)
+	if err != nil {
+		return err
+	}
+
+	// don't notify for empty polls
+	if len(deviceInfos) == 0 {
+		return nil
+	}
+
+	// prepare notification
+	notificationMsg := polly.NotificationMessage{}
+	notificationMsg.DeviceInfos = deviceInfos
+	notificationMsg.PollID = pollID
+	notificationMsg.Type = polly.EVENT_TYPE_ROLE_CHANGED
+	notificationMsg.User = changedUser.DisplayName
+	notificationMsg.UserID = changedUser.ID
+	notificationMsg.Title = pollTitle
+
+	// let the notification handler goroutine take care of the rest
+	pushClient.notificationChannel <- &notificationMsg
+
+	return nil
+}
+
+func (pushClient *sPushClient) NotifyForRemovedParticipant(
+	db *database.Database, user *polly.PrivateUser, pollID int64,
+	pollTitle string, removedUser *polly.PrivateUser) error {
+
+	// retrieve the remaining poll participants device infos
+	deviceInfos, err := db.GetDeviceInfosForPollExcludeCreator(pollID,
+		user.ID)
+	if err != nil {
+		return err
+	}
+
+	// the removed user is no longer a participant, nor might he or she exist
+	removedUserDeviceInfo := polly.DeviceInfo{
+		DeviceType: removedUser.DeviceType,
+		DeviceGUID: removedUser.DeviceGUID,
+	}
+
+	// the badge no longer counts the poll, so update it as well
+	badge, err := db.CountBadgeForUser(removedUser.ID)
+	if err != nil {
+		return err
+	}
+
+	removedUserBadge := int(badge)
+	removedUserDeviceInfo.Badge = &removedUserBadge
+
+	notificationMsg1 := polly.NotificationMessage{}
+	notificationMsg1.DeviceInfos = deviceInfos
+	notificationMsg1.PollID = pollID
+	notificationMsg1.Type = polly.EVENT_TYPE_PARTICIPANT_LEFT
+	notificationMsg1.User = removedUser.DisplayName
+	notificationMsg1.UserID = removedUser.ID
+	notificationMsg1.Title = pollTitle
+
+	notificationMsg2 := polly.NotificationMessage{}
+	notificationMsg2.DeviceInfos = []polly.DeviceInfo{removedUserDeviceInfo}
+	notificationMsg2.PollID = pollID
+	notificationMsg2.Type = polly.EVENT_TYPE_REMOVED_FROM_POLL
+	notificationMsg2.User = user.DisplayName
+	notificationMsg2.UserID = user.ID
+	notificationMsg2.Title = pollTitle
+
+	// let the notification handler goroutine take care of the rest
+	pushClient.notificationChannel <- &notificationMsg1
+	pushClient.notificationChannel <- &notificationMsg2
+
+	return nil
+}
+
+/*
+ * Reminds the participants that haven't voted yet of the given poll. The
+ * nudger is the user that asked for the reminder, it is nil for the reminders
+ * sent before the poll closes.
+ */
+func (pushClient *sPushClient) NotifyForReminder(db *database.Database,
+	nudger *polly.PrivateUser, pollID int64, pollTitle string) error {
+
+	// retrieve the participants without a vote
+	var nudgerID int64
+	if nudger != nil {
+		nudgerID = nudger.ID
+	}
+
+	deviceInfos, err := db.GetDeviceInfosForNonVoters(pollID, nudgerID)
+	if err != nil {
+		return err
+	}
+
+	// don't notify when everyone voted
+	if len(deviceInfos) == 0 {
+		return nil
+	}
+
+	// prepare notification
+	notificationMsg := polly.NotificationMessage{}
+	notificationMsg.DeviceInfos = deviceInfos
+	notificationMsg.PollID = pollID
+	notificationMsg.Type = polly.EVENT_TYPE_REMINDER
+	notificationMsg.Title = pollTitle
+	if nudger != nil {
+		notificationMsg.User = nudger.DisplayName
+		notificationMsg.UserID = nudger.ID
+	}
+
+	// let the notification handler goroutine take care of the rest
+	pushClient.notificationChannel <- &notificationMsg
+
+	return nil
+}
+
+/* Notifies the participants, except the commenter, of a new comment. */
+func (pushClient *sPushClient) NotifyForComment(db *database.Database,
+	user *polly.PrivateUser, pollID int64, content string) error {
+
+	// retrieve all poll participants
+	deviceInfos, err := db.GetDeviceInfosForPollExcludeCreator(pollID, user.ID)
+	if err != nil {
+		return err
+	}
+
+	// don't notify for empty polls
+	if len(deviceInfos) == 0 {
+		return nil
+	}
+
+	// prepare notification
+	notificationMsg := polly.NotificationMessage{}
+	notificationMsg.DeviceInfos = deviceInfos
+	notificationMsg.PollID = pollID
+	notificationMsg.Type = polly.EVENT_TYPE_NEW_COMMENT
+	notificationMsg.Title = content
+	notificationMsg.User = user.DisplayName
+	notificationMsg.UserID = user.ID
+
+	// let the notification handler goroutine take care of the rest
+	pushClient.notificationChannel <- &notificationMsg
+
+	return nil
+}
@@ -0,0 +1,215 @@
+package scheduler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/roxot/polly"
+	"github.com/roxot/polly/database"
+	"github.com/roxot/polly/log"
+
+	"gopkg.in/gorp.v1"
+)
+
+const (
+	cPostgresSchedulerTag = "POSTGRESSCHEDULER"
+	cNumWorkers           = 4
+	cPollInterval         = time.Second
+	cRetryBackoff         = 30 * time.Second
+)
+
+type sJobType struct {
+	retries uint
+	handler FHandler
+}
+
+type sPostgresScheduler struct {
+	db     *database.Database
+	logger log.ILogger
+	types  map[string]sJobType
+}
+
+/*
+ * Creates a scheduler that keeps its jobs in the jobs table. The workers lock
+ * the job they run, so multiple servers can share the table.
+ */
+func NewPostgresScheduler(db *database.Database,
+	logger log.ILogger) IScheduler {
+
+	scheduler := sPostgresScheduler{}
+	scheduler.db = db
+	scheduler.logger = logger
+	scheduler.types = make(map[string]sJobType)
+	return &scheduler
+}
+
+func (scheduler *sPostgresScheduler) RegisterType(name string, retries uint,
+	handler FHandler) error {
+
+	if _, ok := scheduler.types[name]; ok {
+		return fmt.Errorf("Job type %s already registered.", name)
+	}
+
+	scheduler.types[name] = sJobType{retries, handler}
+	return nil
+}
+
+func (scheduler *sPostgresScheduler) Start() error {
+	for i := 0; i < cNumWorkers; i++ {
+		go scheduler.work()
+	}
+
+	return nil
+}
+
+func (scheduler *sPostgresScheduler) Schedule(name string, runAt time.Time,
+	data interface{}) (string, error) {
+
+	job, err := scheduler.newJob(name, runAt, data)
+	if err != nil {
+		return "", err
+	}
+
+	err = scheduler.db.AddJob(job)
+	if err != nil {
+		return "", err
+	}
+
+	return strconv.FormatInt(job.ID, 10), nil
+}
+
+func (scheduler *sPostgresScheduler) ScheduleTX(name string, runAt time.Time,
+	data interface{}, tx *gorp.Transaction) (string, error) {
+
+	job, err := scheduler.newJob(name, runAt, data)
+	if err != nil {
+		return "", err
+	}
+
+	err = database.AddJobTX(job, tx)
+	if err != nil {
+		return "", err
+	}
+
+	return strconv.FormatInt(job.ID, 10), nil
+}
+
+/* Jobs scheduled with another backend are unknown, so they are ignored. */
+func (scheduler *sPostgresScheduler) Cancel(jobID string) error {
+	id, err := strconv.ParseInt(jobID, 10, 64)
+	if err != nil {
+		return nil
+	}
+
+	return scheduler.db.DeleteJob(id)
+}
+
+func (scheduler *sPostgresScheduler) newJob(name string, runAt time.Time,
+	data interface{}) (*polly.Job, error) {
+
+	jobType, ok := scheduler.types[name]
+	if !ok {
+		return nil, fmt.Errorf("Job type %s not registered.", name)
+	}
+
+	encodedData, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	job := polly.Job{}
+	job.Type = name
+	job.Data = encodedData
+	job.RunAt = runAt.UnixNano() / 1000000
+	job.Retries = int(jobType.retries)
+	return &job, nil
+}
+
+/* Keeps running due jobs, waits a while when there are none. */
+func (scheduler *sPostgresScheduler) work() {
+	for {
+		ran, err := scheduler.runDueJob()
+		if err != nil {
+			scheduler.logger.Log(cPostgresSchedulerTag, err.Error(), "::1")
+		}
+
+		if !ran || err != nil {
+			time.Sleep(cPollInterval)
+		}
+	}
+}
+
+/*
+ * Runs the job that is due the longest, if any. The job stays locked while
+ * its handler runs and is deleted once it succeeded. Failed jobs are retried
+ * after a backoff until they run out of retries, after which they are kept as
+ * failed.
+ * Returns whether a job ran.
+ */
+func (scheduler *sPostgresScheduler) runDueJob() (bool, error) {
+
+	// start the transaction
+	tx, err := scheduler.db.Begin()
+	if err != nil {
+		return false, err
+	}
+
+	// claim a due job
+	job, err := database.GetDueJobTX(time.Now().UnixNano()/1000000, tx)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return false, nil
+	} else if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	// run the job
+	jobType, ok := scheduler.types[job.Type]
+	if !ok {
+		err = fmt.Errorf("Job type %s not registered.", job.Type)
+	} else {
+		err = jobType.handler(job.Data)
+	}
+
+	// remove or retry the job
+	if err == nil {
+		err = database.DeleteJobTX(job.ID, tx)
+	} else {
+		scheduler.logger.Log(cPostgresSchedulerTag, fmt.Sprintf(
+			"Job %d (%s) failed: %s", job.ID, job.Type, err), "::1")
+
+		if ok && job.Retries > 0 {
+			runAt := time.Now().Add(retryBackoff(jobType, job))
+			err = database.UpdateJobRetriesTX(job.ID, job.Retries-1,
+				runAt.UnixNano()/1000000, tx)
+		} else {
+			err = database.UpdateJobFailedTX(job.ID, tx)
+		}
+	}
+
+	if err != nil {
+		tx.Rollback()
+		return true, err
+	}
+
+	// commit the transaction
+	return true, tx.Commit()
+}
+
+/*
+ * The wait before retrying the job doubles with every failed run. Jobs may
+ * have more retries left than their type allows if it was registered with
+ * fewer since.
+ */
+func retryBackoff(jobType sJobType, job *polly.Job) time.Duration {
+	failures := int(jobType.retries) - job.Retries
+	if failures < 0 {
+		failures = 0
+	}
+
+	return cRetryBackoff << uint(failures)
+}
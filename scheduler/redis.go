@@ -0,0 +1,108 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/albrow/jobs"
+	"gopkg.in/gorp.v1"
+)
+
+/*
+ * Appended to the names of the job types, jobs scheduled under the bare names
+ * are the legacy ones that carry gob encoded values.
+ */
+const cJSONTypeSuffix = "_JSON"
+
+type sRedisScheduler struct {
+	types map[string]*jobs.Type
+}
+
+/* Creates a scheduler that runs the jobs from Redis using albrow/jobs. */
+func NewRedisScheduler() IScheduler {
+	scheduler := sRedisScheduler{}
+	scheduler.types = make(map[string]*jobs.Type)
+	return &scheduler
+}
+
+func (scheduler *sRedisScheduler) RegisterType(name string, retries uint,
+	handler FHandler) error {
+
+	jobType, err := jobs.RegisterType(name+cJSONTypeSuffix, retries,
+		func(data []byte) error {
+			return handler(data)
+		})
+	if err != nil {
+		return err
+	}
+
+	scheduler.types[name] = jobType
+	return nil
+}
+
+/*
+ * Registers the handler of the jobs that were scheduled under the given name
+ * before job data was JSON encoded. albrow/jobs gob decodes their data into
+ * the argument type of the handler, so it takes the type that was scheduled
+ * back then.
+ */
+func RegisterLegacyRedisType(name string, retries uint,
+	handler interface{}) error {
+
+	_, err := jobs.RegisterType(name, retries, handler)
+	return err
+}
+
+func (scheduler *sRedisScheduler) Start() error {
+
+	// create a job pool TODO pass configuration
+	pool, err := jobs.NewPool(nil)
+	if err != nil {
+		return err
+	}
+
+	return pool.Start()
+}
+
+func (scheduler *sRedisScheduler) Schedule(name string, runAt time.Time,
+	data interface{}) (string, error) {
+
+	jobType, ok := scheduler.types[name]
+	if !ok {
+		return "", fmt.Errorf("Job type %s not registered.", name)
+	}
+
+	encodedData, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+
+	job, err := jobType.Schedule(0, runAt, encodedData)
+	if err != nil {
+		return "", err
+	}
+
+	return job.Id(), nil
+}
+
+/*
+ * Redis can't take part in the transaction, so the job is scheduled right
+ * away. Its handler has to cope with data that was rolled back.
+ */
+func (scheduler *sRedisScheduler) ScheduleTX(name string, runAt time.Time,
+	data interface{}, tx *gorp.Transaction) (string, error) {
+
+	return scheduler.Schedule(name, runAt, data)
+}
+
+func (scheduler *sRedisScheduler) Cancel(jobID string) error {
+	job, err := jobs.FindById(jobID)
+	if _, ok := err.(jobs.ErrorJobNotFound); ok {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	return job.Cancel()
+}
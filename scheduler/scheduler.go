@@ -0,0 +1,32 @@
+package scheduler
+
+import (
+	"time"
+
+	"gopkg.in/gorp.v1"
+)
+
+const (
+	BACKEND_REDIS    = "redis"
+	BACKEND_POSTGRES = "postgres"
+)
+
+/* Runs a job, the data is the JSON encoded value it was scheduled with. */
+type FHandler func(data []byte) error
+
+/*
+ * Runs jobs of registered types at a given time. Failing jobs are retried as
+ * often as their type allows. Job identifiers can be stored to cancel the job
+ * later on, cancelling a job that already ran is not an error.
+ */
+type IScheduler interface {
+	RegisterType(name string, retries uint, handler FHandler) error
+	Start() error
+	Schedule(name string, runAt time.Time, data interface{}) (string, error)
+
+	// schedules a job that only exists once the transaction commits, if the
+	// backend supports it
+	ScheduleTX(name string, runAt time.Time, data interface{},
+		tx *gorp.Transaction) (string, error)
+	Cancel(jobID string) error
+}